@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"todo-app/internal/storage"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// doctorResult is the outcome of a single environment check.
+// A failed critical check makes the doctor command fail,
+// a failed non-critical check is only reported as a warning.
+type doctorResult struct {
+	Name     string
+	OK       bool
+	Critical bool
+	Detail   string
+	Hint     string
+}
+
+// handleDoctor processes the doctor command to diagnose environment issues.
+// It checks that the data file is valid and writable, its directory,
+// the lock file, and the log directory,
+// and prints a pass/fail report with remediation hints.
+// Returns an error if any critical check fails.
+func handleDoctor(args []string) error {
+	logger.Debug("handleDoctor called with %d args", len(args))
+
+	doctorCmd := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	setupCommandConfig(doctorCmd)
+
+	if err := doctorCmd.Parse(args); err != nil {
+		printCommandUsage("doctor", doctorCmd, "diagnose environment issues")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	results := runDoctorChecks(tasksFile, filepath.Dir(logFile))
+
+	failed := 0
+	for _, result := range results {
+		status := "PASS"
+		if !result.OK && result.Critical {
+			status = "FAIL"
+			failed++
+		} else if !result.OK {
+			status = "WARN"
+		}
+		fmt.Printf("[%s] %s: %s\n", status, result.Name, result.Detail)
+		if !result.OK && result.Hint != "" {
+			fmt.Printf("       hint: %s\n", result.Hint)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d critical check(s) failed", failed)
+	}
+	logger.ConsoleSuccess("All critical checks passed")
+	return nil
+}
+
+// runDoctorChecks runs all environment checks for the given data file
+// and log directory and returns their results in report order.
+func runDoctorChecks(dataPath, logDir string) []doctorResult {
+	results := []doctorResult{checkDataFile(dataPath)}
+	results = append(results, checkFileWritable("data file writable", dataPath))
+	results = append(results, checkDirWritable("data directory writable", filepath.Dir(dataPath), true))
+	results = append(results, checkStaleLock(dataPath))
+	results = append(results, checkDirWritable("log directory writable", logDir, false))
+	return results
+}
+
+// checkDataFile verifies that the data file can be read, parsed, and
+// contains only valid tasks. A missing file is fine, it is created on first save.
+func checkDataFile(path string) doctorResult {
+	result := doctorResult{Name: "data file", Critical: true}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		result.OK = true
+		result.Detail = fmt.Sprintf("%s does not exist yet, it will be created on first save", path)
+		return result
+	} else if err != nil {
+		result.Detail = fmt.Sprintf("cannot access %s: %v", path, err)
+		result.Hint = "check that the path and its parent directories are accessible"
+		return result
+	}
+	if info.IsDir() {
+		result.Detail = fmt.Sprintf("%s is a directory", path)
+		result.Hint = "move the directory away so the data file can be created"
+		return result
+	}
+
+	tasks, err := dataStore.Load(path)
+	if err != nil {
+		result.Detail = err.Error()
+		result.Hint = fmt.Sprintf("fix the %s by hand or restore it with 'load' or 'restore-snapshot'", strings.ToUpper(dataStore.Format))
+		return result
+	}
+	if err := todo.ValidateTasks(tasks); err != nil {
+		result.Detail = fmt.Sprintf("%s contains invalid tasks: %s", path, strings.ReplaceAll(err.Error(), "\n", "; "))
+		result.Hint = "edit the listed tasks so IDs are unique and at least 1 and descriptions are not empty"
+		return result
+	}
+
+	result.OK = true
+	result.Detail = fmt.Sprintf("%s is readable and contains %d valid tasks", path, len(tasks))
+	return result
+}
+
+// checkFileWritable verifies that an existing file can be written, e.g. that
+// the data file is not read-only, without opening it for writing.
+// A missing file is fine, the directory checks cover creating it.
+func checkFileWritable(name, path string) doctorResult {
+	result := doctorResult{Name: name, Critical: true}
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		result.OK = true
+		result.Detail = fmt.Sprintf("%s does not exist yet", path)
+		return result
+	} else if err != nil {
+		result.Detail = fmt.Sprintf("cannot access %s: %v", path, err)
+		result.Hint = "check that the path and its parent directories are accessible"
+		return result
+	}
+	if info.IsDir() {
+		result.Detail = fmt.Sprintf("%s is a directory", path)
+		result.Hint = "move the directory away so the data file can be created"
+		return result
+	}
+
+	if err := fileWritable(path); err != nil {
+		result.Detail = fmt.Sprintf("cannot write to %s: %v", path, err)
+		result.Hint = fmt.Sprintf("make %s writable for the current user, e.g. chmod u+w", path)
+		return result
+	}
+
+	result.OK = true
+	result.Detail = fmt.Sprintf("%s is writable", path)
+	return result
+}
+
+// checkDirWritable verifies that files can be created in dir without
+// creating anything. A missing directory is fine if its nearest existing
+// ancestor is a writable directory, it is created on first save.
+func checkDirWritable(name, dir string, critical bool) doctorResult {
+	result := doctorResult{Name: name, Critical: critical}
+
+	existing := dir
+	for {
+		info, err := os.Stat(existing)
+		if err == nil && !info.IsDir() {
+			result.Detail = fmt.Sprintf("%s is not a directory", existing)
+			result.Hint = fmt.Sprintf("move %s away so %s can be created", existing, dir)
+			return result
+		} else if err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			result.Detail = fmt.Sprintf("cannot access %s: %v", existing, err)
+			result.Hint = "check that the path and its parent directories are accessible"
+			return result
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			result.Detail = fmt.Sprintf("no parent directory of %s exists", dir)
+			result.Hint = "create the directory"
+			return result
+		}
+		existing = parent
+	}
+
+	if err := dirWritable(existing); err != nil {
+		result.Detail = fmt.Sprintf("cannot write to %s: %v", existing, err)
+		result.Hint = "make the directory writable for the current user"
+		return result
+	}
+
+	result.OK = true
+	if existing != dir {
+		result.Detail = fmt.Sprintf("%s does not exist yet, it can be created in %s", dir, existing)
+	} else {
+		result.Detail = fmt.Sprintf("%s is writable", dir)
+	}
+	return result
+}
+
+// checkStaleLock reports a lock file left behind by a crashed process,
+// by the same owner PID check AcquireLock uses to reclaim it.
+// A lock of a running process is fine, it may be saving right now.
+func checkStaleLock(dataPath string) doctorResult {
+	result := doctorResult{Name: "lock file", Critical: true}
+	lockPath := storage.LockPath(dataPath)
+
+	stale, err := storage.StaleLock(dataPath)
+	if err != nil {
+		result.Detail = err.Error()
+		result.Hint = "check the permissions of the data directory"
+		return result
+	}
+	if stale {
+		result.Detail = fmt.Sprintf("stale lock %s left by a process that is gone", lockPath)
+		result.Hint = fmt.Sprintf("the next save reclaims it, or delete %s", lockPath)
+		return result
+	}
+
+	result.OK = true
+	result.Detail = fmt.Sprintf("no stale lock at %s", lockPath)
+	return result
+}
+
+// selfCheckLock acquires and immediately releases the lock of the data file
+// and logs the round-trip time, so slow filesystems show up in the debug log.
+// It runs at startup with the global --verbose flag.
+// Returns an error if the lock cannot be acquired or released.
+func selfCheckLock(dataPath string) error {
+	start := time.Now()
+	lock, err := storage.AcquireLock(dataPath)
+	if err != nil {
+		return fmt.Errorf("cannot acquire lock for %s: %w", dataPath, err)
+	}
+	if err := lock.Release(); err != nil {
+		return fmt.Errorf("cannot release lock for %s: %w", dataPath, err)
+	}
+	logger.Debug("Lock self-check: acquired and released %s in %s", storage.LockPath(dataPath), time.Since(start))
+	return nil
+}
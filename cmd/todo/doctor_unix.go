@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// accessWrite is the W_OK mode of access(2).
+const accessWrite = 0x2
+
+// dirWritable reports whether the current user may create files in dir,
+// using access(2) so nothing is created.
+func dirWritable(dir string) error {
+	return syscall.Access(dir, accessWrite)
+}
+
+// fileWritable reports whether the current user may write to the file at
+// path, using access(2) so it is not opened. A file without any write
+// permission bit is read-only even for root, whom access(2) lets write.
+func fileWritable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0222 == 0 {
+		return errors.New("file is read-only")
+	}
+	return syscall.Access(path, accessWrite)
+}
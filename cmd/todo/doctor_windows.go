@@ -0,0 +1,34 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// dirWritable reports whether files can be created in dir.
+// Windows has no access(2), so only the read-only attribute is checked.
+func dirWritable(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return errors.New("directory is read-only")
+	}
+	return nil
+}
+
+// fileWritable reports whether the file at path can be written,
+// by its read-only attribute like dirWritable.
+func fileWritable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return errors.New("file is read-only")
+	}
+	return nil
+}
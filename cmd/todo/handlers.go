@@ -1,77 +1,403 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"todo-app/internal/storage"
 	"todo-app/internal/todo"
 
 	"github.com/ZeRg0912/logger"
+	"gopkg.in/yaml.v3"
 )
 
-// handleAdd processes the add command to create a new task.
-// It expects a --desc flag with the task description.
+// handleAdd processes the add command to create one or more tasks.
+// It expects a --desc flag with the task description, which may be repeated,
+// or a --descs list separated by commas or newlines, and accepts an optional
+// --ref, --priority (low, medium or high; medium by default), --due date
+// and repeatable --tag that apply to every created task.
+// Supports --no-dup flag to skip descriptions that already exist, ignoring case;
+// duplicates are reported, not treated as errors.
+// Supports --template flag to start every task from a saved template;
+// an explicit --priority overrides the template's and --tag adds to its tags.
+// Descriptions that fail validation are reported and skipped;
+// returns an error only if no task could be created.
 // Returns the updated task slice.
 func handleAdd(tasks []todo.Task, args []string) ([]todo.Task, error) {
 	logger.Debug("handleAdd called with %d args", len(args))
 
 	addCmd := flag.NewFlagSet("add", flag.ContinueOnError)
-	desc := addCmd.String("desc", "", "Task description")
+	var descFlags stringList
+	addCmd.Var(&descFlags, "desc", "Task description (repeat to add several tasks)")
+	descs := addCmd.String("descs", "", "Task descriptions separated by commas or newlines")
+	ref := addCmd.String("ref", "", "External reference, e.g. a ticket URL or issue key")
+	priority := addCmd.String("priority", todo.PriorityMedium, "Task priority: low, medium or high")
+	due := addCmd.String("due", "", "Due date: 2024-06-01 or 2024-06-01T15:00")
+	var tags stringList
+	addCmd.Var(&tags, "tag", "Task tag, e.g. a project name (repeat for several tags)")
+	templateName := addCmd.String("template", "", "Name of a saved template to apply")
+	noDup := addCmd.Bool("no-dup", false, "Skip descriptions that already exist (ignoring case)")
 	setupCommandConfig(addCmd)
 
 	err := addCmd.Parse(args)
 	if err != nil {
 		printCommandUsage("add", addCmd, "add a new task")
-		return nil, fmt.Errorf("invalid arguments: %w", err)
+		return nil, usageErrorf("invalid arguments: %w", err)
 	}
 
-	if *desc == "" {
+	if *descs == "" {
+		if err := requireFlags(addCmd, "add a new task", "desc"); err != nil {
+			return nil, err
+		}
+	}
+
+	var descValues []string
+	for _, value := range descFlags {
+		// Fix PowerShell double equals issue: --desc=="text" becomes --desc="=text"
+		if len(value) > 0 && value[0] == '=' {
+			value = value[1:]
+			logger.Debug("Removed leading '=' from description (PowerShell double equals fix)")
+		}
+		descValues = append(descValues, value)
+	}
+	for _, value := range strings.FieldsFunc(*descs, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if value = strings.TrimSpace(value); value != "" {
+			descValues = append(descValues, value)
+		}
+	}
+
+	if err := todo.ValidateRef(*ref); err != nil {
 		printCommandUsage("add", addCmd, "add a new task")
-		return nil, fmt.Errorf("task description cannot be empty: use --desc flag")
+		return nil, usageErrorf("invalid --ref: %w", err)
 	}
+	if err := todo.ValidatePriority(*priority); err != nil {
+		printCommandUsage("add", addCmd, "add a new task")
+		return nil, usageErrorf("invalid --priority: %w", err)
+	}
+	var dueDate *time.Time
+	if *due != "" {
+		parsed, err := todo.ParseDueDate(*due)
+		if err != nil {
+			printCommandUsage("add", addCmd, "add a new task")
+			return nil, usageErrorf("invalid --due: %w", err)
+		}
+		dueDate = &parsed
+	}
+	var tmpl todo.Template
+	if *templateName != "" {
+		tmpl, err = storage.LoadTemplate(storage.TemplatesPath(tasksFile), *templateName)
+		if errors.Is(err, storage.ErrTemplateNotFound) {
+			printCommandUsage("add", addCmd, "add a new task")
+			return nil, usageErrorf("invalid --template: %w", err)
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot load template: %w", err)
+		}
+	}
+	priorityExplicit := false
+	addCmd.Visit(func(f *flag.Flag) {
+		if f.Name == "priority" {
+			priorityExplicit = true
+		}
+	})
 
-	// Fix PowerShell double equals issue: --desc=="text" becomes --desc="=text"
-	descValue := *desc
-	if len(descValue) > 0 && descValue[0] == '=' {
-		descValue = descValue[1:]
-		logger.Debug("Removed leading '=' from description (PowerShell double equals fix)")
+	addMany := todo.AddMany
+	if *noDup {
+		addMany = todo.AddManyUnique
+	}
+	newTasks, created, errs := addMany(tasks, descValues, idStrategy)
+	duplicates := 0
+	for _, err := range errs {
+		if errors.Is(err, todo.ErrDuplicateTask) {
+			duplicates++
+			logger.ConsoleInfo("Skipped %v", err)
+		}
+	}
+	if len(created) == 0 {
+		if duplicates == len(errs) {
+			logger.Info("No tasks added, all %d descriptions already exist", duplicates)
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot add task: %w", errors.Join(errs...))
+	}
+	for _, err := range errs {
+		if !errors.Is(err, todo.ErrDuplicateTask) {
+			consoleError("Cannot add task: %v", err)
+		}
 	}
 
-	newTasks, err := todo.Add(tasks, descValue)
-	if err != nil {
-		return nil, fmt.Errorf("cannot add task: %w", err)
+	// The new tasks get their metadata through one TaskList, so adding
+	// many tasks doesn't copy and scan the whole slice per task and field
+	list := todo.NewTaskList(newTasks)
+	for _, task := range created {
+		if _, err := strconv.Atoi(strings.TrimSpace(task.Description)); err == nil {
+			logger.ConsoleInfo("Description '%s' looks like an ID: use --match=%s, not --id, to refer to it by description", task.Description, strings.TrimSpace(task.Description))
+		}
+		merged := todo.ApplyTemplate(task, tmpl)
+		taskPriority := merged.Priority
+		if priorityExplicit {
+			taskPriority = *priority
+		}
+		if err := list.SetPriority(task.ID, taskPriority); err != nil {
+			return nil, fmt.Errorf("cannot set task priority: %w", err)
+		}
+		if *ref != "" {
+			if err := list.SetRef(task.ID, *ref); err != nil {
+				return nil, fmt.Errorf("cannot set task reference: %w", err)
+			}
+		}
+		if dueDate != nil {
+			if err := list.SetDueDate(task.ID, dueDate); err != nil {
+				return nil, fmt.Errorf("cannot set task due date: %w", err)
+			}
+		}
+		if taskTags := append(merged.Tags, tags...); len(taskTags) > 0 {
+			if err := list.SetTags(task.ID, taskTags); err != nil {
+				return nil, fmt.Errorf("cannot set task tags: %w", err)
+			}
+		}
+		logger.ConsoleSuccess("Task added with ID %d: %s", task.ID, task.Description)
+	}
+	if len(descValues) > 1 {
+		logger.ConsoleHelpf("Added %d of %d tasks", len(created), len(descValues))
 	}
-	logger.ConsoleSuccess("Task added: %s", descValue)
-	return newTasks, nil
+	return list.Tasks(), nil
+}
+
+// listEnvelope wraps list output with the context it was produced in,
+// for consumers of list --output=json|yaml --verbose.
+type listEnvelope struct {
+	Filter string      `json:"filter" yaml:"filter"`
+	Count  int         `json:"count" yaml:"count"`
+	Tasks  []todo.Task `json:"tasks" yaml:"tasks"`
 }
 
+// listFilters are the --filter values accepted by list and count, see todo.List.
+var listFilters = map[string]bool{"all": true, "done": true, "pending": true, "untagged": true}
+
 // handleList processes the list command to display tasks.
-// Supports --filter flag with values: all, done, pending.
-// Tasks are displayed with status emojis and IDs.
+// Supports --filter flag with values: all, done, pending, untagged.
+// Supports --after and --before flags to show only an inclusive range of task IDs.
+// Supports --overdue flag to show only pending tasks past their due date.
+// Supports --count-by flag to print only per-group task counts by status,
+// priority or tag; a task with several tags counts once under each of them.
+// Supports --since-last-run flag to show only tasks added since the previous list.
+// Supports --max-age flag to mark pending tasks older than that many days
+// (30 by default) as stale, based on CreatedAt.
+// Supports --done-suffix flag to mark completed tasks without changing them.
+// Supports --done-glyph and --pending-glyph flags to customize status markers.
+// Supports --width flag to truncate lines, defaulting to the terminal width.
+// Supports --compact flag to print all task IDs on one status-bar friendly line.
+// Supports --output flag with values: text, json, yaml, csv, tsv, ids-csv.
+// Supports --no-header flag to omit the header line of csv, tsv and text output.
+// Supports --verbose flag (or its alias --envelope) to wrap json/yaml output
+// with the filter and count. Global flags only come before the command name,
+// so this --verbose never reaches the global one.
+// Supports --sort flag with keys order (the default, the stored order),
+// id, description, status, random, and --seed flag to make --sort=random reproducible.
+// Pinned tasks are always listed first, regardless of --sort.
+// Tasks are displayed with status markers and IDs; emojis are only
+// printed when stdout is a terminal.
 func handleList(tasks []todo.Task, args []string) error {
 	logger.Debug("handleList called with %d args", len(args))
 
 	listCmd := flag.NewFlagSet("list", flag.ContinueOnError)
-	filter := listCmd.String("filter", "all", "Task filter: all, done, pending")
+	filter := listCmd.String("filter", "all", "Task filter: all, done, pending, untagged")
+	tag := listCmd.String("tag", "", "Show only tasks with this tag")
+	after := listCmd.Int("after", 0, "Show only tasks with this ID or higher")
+	before := listCmd.Int("before", 0, "Show only tasks with this ID or lower")
+	overdue := listCmd.Bool("overdue", false, "Show only pending tasks past their due date")
+	countBy := listCmd.String("count-by", "", "Print task counts grouped by: status, priority, tag")
+	output := listCmd.String("output", "text", "Output format: text, json, yaml, csv, tsv, ids-csv")
+	sinceLastRun := listCmd.Bool("since-last-run", false, "Show only tasks added since the previous list run")
+	doneSuffix := listCmd.String("done-suffix", "", "Text appended to completed task descriptions")
+	doneGlyph := listCmd.String("done-glyph", defaultGlyphs.Done, "Marker shown for completed tasks")
+	pendingGlyph := listCmd.String("pending-glyph", defaultGlyphs.Pending, "Marker shown for pending tasks")
+	width := listCmd.Int("width", 0, "Maximum line width (default: terminal width, 80 when piped)")
+	compact := listCmd.Bool("compact", false, "Print pending and done task IDs on a single line, or JSON without indentation (default for json when piped)")
+	verbose := listCmd.Bool("verbose", false, "Wrap json/yaml output in an object with filter and count")
+	listCmd.BoolVar(verbose, "envelope", false, "Alias for --verbose")
+	noHeader := listCmd.Bool("no-header", false, "Omit the header line of csv, tsv and text output")
+	sortKey := listCmd.String("sort", "order", "Sort by: order (stored, see move), id, description, status, random")
+	seed := listCmd.Int64("seed", 0, "Seed for --sort=random (default: time-based)")
+	noSummary := listCmd.Bool("no-summary", false, "Omit the summary line after text output")
+	maxAge := listCmd.Int("max-age", int(todo.DefaultMaxAge/(24*time.Hour)), "Mark pending tasks older than this many days as stale, 0 disables")
+	full := addFullFlag(listCmd)
 	setupCommandConfig(listCmd)
 
 	err := listCmd.Parse(args)
 	if err != nil {
 		printCommandUsage("list", listCmd, "list tasks")
-		return fmt.Errorf("invalid arguments: %w", err)
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if !listFilters[*filter] {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("invalid filter value '%s'", *filter)
+	}
+
+	validOutputs := map[string]bool{"text": true, "json": true, "yaml": true, "csv": true, "tsv": true, "ids-csv": true}
+	if !validOutputs[*output] {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("invalid output value '%s'", *output)
+	}
+	if *compact && *output != "text" && *output != "json" {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("--compact only supports text or json output, got '%s'", *output)
+	}
+	// JSON is pretty-printed for people at a terminal and compact for pipes,
+	// unless --compact is given explicitly, e.g. --compact=false
+	compactJSON := !stdoutIsTerminal()
+	listCmd.Visit(func(f *flag.Flag) {
+		if f.Name == "compact" {
+			compactJSON = *compact
+		}
+	})
+	if *verbose && *output != "json" && *output != "yaml" {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("--verbose requires --output=json or --output=yaml")
 	}
 
-	validFilters := map[string]bool{"all": true, "done": true, "pending": true}
-	if !validFilters[*filter] {
+	if *width < 0 {
 		printCommandUsage("list", listCmd, "list tasks")
-		return fmt.Errorf("invalid filter value '%s'", *filter)
+		return usageErrorf("width cannot be negative, got %d", *width)
+	}
+	if *width == 0 {
+		*width = terminalWidth()
+	}
+
+	if *maxAge < 0 {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("--max-age cannot be negative, got %d", *maxAge)
+	}
+
+	if *after < 0 || *before < 0 {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("--after and --before cannot be negative, got %d and %d", *after, *before)
+	}
+	if *after > 0 && *before > 0 && *before < *after {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("--before (%d) cannot be less than --after (%d)", *before, *after)
+	}
+
+	if *countBy != "" {
+		if err := todo.ValidateGroupKey(*countBy); err != nil {
+			printCommandUsage("list", listCmd, "list tasks")
+			return usageErrorf("%w", err)
+		}
+	}
+
+	if err := todo.ValidateSortKey(*sortKey); err != nil {
+		printCommandUsage("list", listCmd, "list tasks")
+		return usageErrorf("%w", err)
+	}
+	seedSet := false
+	listCmd.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSet = true
+		}
+	})
+	if !seedSet {
+		*seed = time.Now().UnixNano()
+	}
+
+	listed := todo.List(tasks, *filter)
+	if *tag != "" {
+		listed = todo.FilterByTag(listed, *tag)
+	}
+	if *after > 0 || *before > 0 {
+		listed = todo.Filter(listed, todo.IDRange(*after, *before))
+	}
+	if *overdue {
+		listed = todo.Filter(listed, todo.Overdue())
+	}
+	sortedTasks, err := todo.Sort(listed, *sortKey, *seed)
+	if err != nil {
+		return fmt.Errorf("cannot sort tasks: %w", err)
+	}
+	filteredTasks := todo.PinnedFirst(sortedTasks)
+
+	if *sinceLastRun {
+		filteredTasks, err = sinceLastListRun(filteredTasks)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *countBy != "" {
+		return printCounts(filteredTasks, *countBy, *output, compactJSON)
+	}
+
+	if *output == "ids-csv" {
+		logger.Info("Printing %d task IDs as CSV with filter '%s'", len(filteredTasks), *filter)
+		fmt.Println(formatIDsCSV(filteredTasks))
+		return nil
+	}
+
+	if *output == "csv" {
+		logger.Info("Printing %d tasks as CSV with filter '%s'", len(filteredTasks), *filter)
+		if *noHeader {
+			return storage.SaveCSVStreamNoHeader(os.Stdout, filteredTasks)
+		}
+		return storage.SaveCSVStream(os.Stdout, filteredTasks)
+	}
+
+	if *output == "tsv" {
+		logger.Info("Printing %d tasks as TSV with filter '%s'", len(filteredTasks), *filter)
+		if *noHeader {
+			return storage.SaveTSVStreamNoHeader(os.Stdout, filteredTasks)
+		}
+		return storage.SaveTSVStream(os.Stdout, filteredTasks)
+	}
+
+	if *output != "text" && filteredTasks == nil {
+		filteredTasks = []todo.Task{}
+	}
+
+	var structured any = filteredTasks
+	if *verbose {
+		structured = listEnvelope{Filter: *filter, Count: len(filteredTasks), Tasks: filteredTasks}
+	}
+
+	if *output == "yaml" {
+		logger.Info("Printing %d tasks as YAML with filter '%s'", len(filteredTasks), *filter)
+		return printYAML(structured)
+	}
+
+	if *output == "json" {
+		logger.Info("Printing %d tasks as JSON with filter '%s'", len(filteredTasks), *filter)
+		if compactJSON && !*verbose && len(filteredTasks) > storage.JSONStreamThreshold {
+			if err := storage.WriteJSONStream(os.Stdout, filteredTasks); err != nil {
+				return fmt.Errorf("cannot write JSON output: %w", err)
+			}
+			fmt.Println()
+			return nil
+		}
+		return printJSON(structured, compactJSON)
+	}
+
+	opts := renderOptions{
+		Glyphs:     GlyphSet{Done: *doneGlyph, Pending: *pendingGlyph, Overdue: outputGlyphs().Overdue},
+		DoneSuffix: *doneSuffix,
+		Width:      *width,
+		Full:       *full,
+		Now:        time.Now(),
+		MaxAge:     time.Duration(*maxAge) * 24 * time.Hour,
+	}
+
+	if *compact {
+		logger.Info("Printing %d tasks in compact form with filter '%s'", len(filteredTasks), *filter)
+		fmt.Println(opts.fit(formatCompact(filteredTasks)))
+		return nil
 	}
 
-	filteredTasks := todo.List(tasks, *filter)
 	if len(filteredTasks) == 0 {
 		logger.Info("No tasks found with filter '%s'", *filter)
 		logger.ConsoleHelp("No tasks found")
@@ -79,109 +405,815 @@ func handleList(tasks []todo.Task, args []string) error {
 	}
 
 	logger.Info("Displaying %d tasks with filter '%s'", len(filteredTasks), *filter)
-	logger.ConsoleHelpf("Task list (%s):", *filter)
+	if !*noHeader {
+		logger.ConsoleHelpf("Task list (%s):", *filter)
+	}
 	for _, task := range filteredTasks {
-		status := "[ ]"
-		if task.Done {
-			status = "[X]"
-		}
-		logger.ConsoleHelpf("%s [ID:%d] %s", status, task.ID, task.Description)
+		logger.ConsoleHelp(formatTaskLine(task, opts))
+	}
+	if !*noSummary {
+		stats := todo.Stats(tasks)
+		logger.ConsoleHelpf("Showing %d of %d tasks (%d done, %d pending)", len(filteredTasks), stats.Total, stats.Done, stats.Pending)
 	}
 	return nil
 }
 
+// sinceLastListRun filters tasks to those created since the previous
+// list --since-last-run and records this run as the new marker.
+// The state is loaded and saved under the data file lock, so concurrent
+// runs don't overwrite each other's marker.
+// Returns an error if the lock cannot be acquired.
+func sinceLastListRun(tasks []todo.Task) ([]todo.Task, error) {
+	lock, err := storage.AcquireLock(tasksFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot acquire lock for %s: %w", tasksFile, err)
+	}
+	defer lock.Release()
+
+	statePath := storage.StatePath(tasksFile)
+	state, err := storage.LoadState(statePath)
+	if err != nil {
+		logger.Warn("Cannot load list state: %v", err)
+	}
+	logger.Debug("Showing tasks added since %s", state.LastRun.Format(time.RFC3339))
+	since := todo.Since(tasks, state.LastRun)
+
+	if err := storage.SaveState(statePath, storage.ListState{LastRun: time.Now()}); err != nil {
+		logger.Warn("Cannot save list state: %v", err)
+	}
+	return since, nil
+}
+
+// printCounts displays the number of tasks in each group for the given key.
+// Groups are printed as "group: count" lines sorted by group name,
+// or as a single JSON object when output is "json", indented unless compact is set.
+func printCounts(tasks []todo.Task, key, output string, compact bool) error {
+	groups, err := todo.GroupBy(tasks, key)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]int, len(groups))
+	names := make([]string, 0, len(groups))
+	for name, group := range groups {
+		counts[name] = len(group)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	logger.Info("Displaying task counts grouped by '%s'", key)
+	switch output {
+	case "json":
+		return printJSON(counts, compact)
+	case "yaml":
+		return printYAML(counts)
+	}
+
+	for _, name := range names {
+		logger.ConsoleHelpf("%s: %d", name, counts[name])
+	}
+	return nil
+}
+
+// printJSON writes v to stdout as JSON without any log decoration,
+// so the output can be piped into other tools.
+// The JSON is indented unless compact is set.
+func printJSON(v any, compact bool) error {
+	var data []byte
+	var err error
+	if compact {
+		data, err = json.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("cannot marshal output to JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printYAML writes v to stdout as YAML without any log decoration.
+func printYAML(v any) error {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("cannot marshal output to YAML: %w", err)
+	}
+	fmt.Print(string(data))
+	return nil
+}
+
 // handleComplete processes the complete command to mark a task as done.
-// It expects a --id flag with the task ID to complete.
+// It expects a --id flag with the task ID to complete, or "last",
+// or a --match flag to fuzzy-match a pending task by description.
+// --id always refers to task IDs and --match always to descriptions,
+// so a task described as "42" is completed with --match=42, never --id=42.
+// Completing a task that is already done is a no-op, unless --reopen-if-done
+// is set: then the task is reopened, which makes complete a toggle.
+// After completing, prints how many pending tasks remain.
 // Returns the updated task slice.
 func handleComplete(tasks []todo.Task, args []string) ([]todo.Task, error) {
 	logger.Debug("handleComplete called with %d args", len(args))
 
 	completeCmd := flag.NewFlagSet("complete", flag.ContinueOnError)
-	id := completeCmd.Int("id", 0, "Task ID to mark as completed")
+	idFlag := completeCmd.String("id", "", "Task ID to mark as completed, or 'last' for the newest task")
+	match := completeCmd.String("match", "", "Complete the pending task best matching this description")
+	reopen := completeCmd.Bool("reopen-if-done", false, "Reopen the task instead if it is already completed")
 	setupCommandConfig(completeCmd)
 
 	err := completeCmd.Parse(args)
 	if err != nil {
 		printCommandUsage("complete", completeCmd, "mark task as completed")
-		return nil, fmt.Errorf("invalid arguments: %w", err)
+		return nil, usageErrorf("invalid arguments: %w", err)
 	}
 
-	if *id == 0 {
-		printCommandUsage("complete", completeCmd, "mark task as completed")
-		return nil, fmt.Errorf("task ID is required and must be greater than 0")
+	var id int
+	if *match != "" {
+		if *idFlag != "" {
+			printCommandUsage("complete", completeCmd, "mark task as completed")
+			return nil, usageErrorf("--id and --match cannot be used together")
+		}
+		task, err := todo.MatchPending(tasks, *match)
+		if err != nil {
+			return nil, fmt.Errorf("cannot match task: %w", err)
+		}
+		logger.Debug("Matched '%s' to task %d: %s", *match, task.ID, task.Description)
+		id = task.ID
+	} else {
+		if err := requireFlags(completeCmd, "mark task as completed", "id"); err != nil {
+			return nil, err
+		}
+		id, err = resolveIDFlag(tasks, completeCmd, "mark task as completed", *idFlag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if *reopen {
+		for _, task := range tasks {
+			if task.ID == id && task.Done {
+				resultTasks, err := todo.Uncomplete(tasks, id)
+				if err != nil {
+					return nil, fmt.Errorf("cannot reopen task %d: %w", id, err)
+				}
+				logger.ConsoleSuccess("Task %d was already completed, reopened it", id)
+				return resultTasks, nil
+			}
+		}
+	}
+
+	resultTasks, err := todo.Complete(tasks, id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot complete task %d: %w", id, err)
+	}
+
+	remaining := len(todo.List(resultTasks, "pending"))
+	logger.ConsoleSuccess("Task %d marked as completed, %d tasks remaining", id, remaining)
+	return resultTasks, nil
+}
+
+// handleUncomplete processes the uncomplete command to reopen a completed task.
+// It expects a --id flag with the task ID to mark as pending, or "last".
+// Returns the updated task slice.
+func handleUncomplete(tasks []todo.Task, args []string) ([]todo.Task, error) {
+	logger.Debug("handleUncomplete called with %d args", len(args))
+
+	uncompleteCmd := flag.NewFlagSet("uncomplete", flag.ContinueOnError)
+	idFlag := uncompleteCmd.String("id", "", "Task ID to mark as pending, or 'last' for the newest task")
+	setupCommandConfig(uncompleteCmd)
+
+	err := uncompleteCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("uncomplete", uncompleteCmd, "mark a completed task as pending")
+		return nil, usageErrorf("invalid arguments: %w", err)
+	}
+
+	if err := requireFlags(uncompleteCmd, "mark a completed task as pending", "id"); err != nil {
+		return nil, err
+	}
+	id, err := resolveIDFlag(tasks, uncompleteCmd, "mark a completed task as pending", *idFlag)
+	if err != nil {
+		return nil, err
 	}
 
-	resultTasks, err := todo.Complete(tasks, *id)
+	resultTasks, err := todo.Uncomplete(tasks, id)
 	if err != nil {
-		return nil, fmt.Errorf("cannot complete task %d: %w", *id, err)
+		return nil, fmt.Errorf("cannot uncomplete task %d: %w", id, err)
 	}
 
-	logger.ConsoleSuccess("Task %d marked as completed", *id)
+	logger.ConsoleSuccess("Task %d marked as pending", id)
 	return resultTasks, nil
 }
 
+// bulkDeleteConfirmLimit is the largest number of tasks
+// delete --completed-before removes without --yes.
+const bulkDeleteConfirmLimit = 10
+
 // handleDelete processes the delete command to remove a task.
-// It expects a --id flag with the task ID to delete.
+// It expects a --id flag with the task ID to delete, or "last".
+// Supports --completed-before flag to instead remove all done tasks completed
+// before a date; removing more than bulkDeleteConfirmLimit tasks requires --yes.
 // Returns the updated task slice.
 func handleDelete(tasks []todo.Task, args []string) ([]todo.Task, error) {
 	logger.Debug("handleDelete called with %d args", len(args))
 
 	deleteCmd := flag.NewFlagSet("delete", flag.ContinueOnError)
-	id := deleteCmd.Int("id", 0, "Task ID to delete")
+	idFlag := deleteCmd.String("id", "", "Task ID to delete, or 'last' for the newest task")
+	completedBefore := deleteCmd.String("completed-before", "", "Delete done tasks completed before this date (YYYY-MM-DD or YYYY-MM-DDTHH:MM)")
+	yes := deleteCmd.Bool("yes", false, fmt.Sprintf("Confirm deleting more than %d tasks with --completed-before", bulkDeleteConfirmLimit))
 	setupCommandConfig(deleteCmd)
 
 	err := deleteCmd.Parse(args)
 	if err != nil {
 		printCommandUsage("delete", deleteCmd, "delete a task")
-		return nil, fmt.Errorf("invalid arguments: %w", err)
+		return nil, usageErrorf("invalid arguments: %w", err)
 	}
 
-	if *id == 0 {
-		printCommandUsage("delete", deleteCmd, "delete a task")
-		return nil, fmt.Errorf("task ID is required and must be greater than 0")
+	if *completedBefore != "" {
+		if *idFlag != "" {
+			printCommandUsage("delete", deleteCmd, "delete a task")
+			return nil, usageErrorf("--id and --completed-before cannot be used together")
+		}
+		before, err := parseDateBound(*completedBefore)
+		if err != nil {
+			printCommandUsage("delete", deleteCmd, "delete a task")
+			return nil, usageErrorf("invalid --completed-before: %w", err)
+		}
+		return deleteCompletedBefore(tasks, before, *completedBefore, *yes)
+	}
+
+	if err := requireFlags(deleteCmd, "delete a task", "id"); err != nil {
+		return nil, err
+	}
+
+	id, err := resolveIDFlag(tasks, deleteCmd, "delete a task", *idFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	resultTasks, err := todo.Delete(tasks, id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot delete task %d: %w", id, err)
+	}
+
+	logger.ConsoleSuccess("Task %d deleted", id)
+	return resultTasks, nil
+}
+
+// deleteCompletedBefore removes done tasks completed before the given time
+// and reports how many were deleted. A batch larger than
+// bulkDeleteConfirmLimit is only reported unless confirm is set.
+// Returns nil tasks when nothing is deleted.
+func deleteCompletedBefore(tasks []todo.Task, before time.Time, value string, confirm bool) ([]todo.Task, error) {
+	remaining, removed := todo.DeleteCompletedBefore(tasks, before)
+	if removed == 0 {
+		logger.ConsoleHelpf("No tasks completed before %s", value)
+		return nil, nil
+	}
+
+	if removed > bulkDeleteConfirmLimit && !confirm {
+		logger.ConsoleInfo("Deleting removes %d tasks completed before %s. Re-run with --yes to confirm", removed, value)
+		return nil, nil
+	}
+
+	logger.ConsoleSuccess("Deleted %d tasks completed before %s, %d tasks remain", removed, value, len(remaining))
+	return remaining, nil
+}
+
+// parseDateBound parses a date like "2024-06-01" or "2024-06-01T15:00"
+// in the local time zone. Unlike todo.ParseDueDate, a date without a time
+// means the start of that day, so "before 2024-06-01" excludes the whole day.
+func parseDateBound(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation(todo.DueDateTimeLayout, value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(todo.DueDateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' (use %s or %s)", value, todo.DueDateLayout, todo.DueDateTimeLayout)
+	}
+	return t, nil
+}
+
+// handleMove processes the move command to change the position of a task.
+// It expects an --id flag and a --to flag with the 1-based target position.
+// Positions outside the list are an error, they are not clamped to the ends.
+// The new order shows in list, exports and the data file.
+func handleMove(tasks []todo.Task, args []string) ([]todo.Task, error) {
+	logger.Debug("handleMove called with %d args", len(args))
+
+	moveCmd := flag.NewFlagSet("move", flag.ContinueOnError)
+	idFlag := moveCmd.String("id", "", "Task ID to move, or 'last' for the newest task")
+	to := moveCmd.Int("to", 0, "New 1-based position of the task")
+	setupCommandConfig(moveCmd)
+
+	err := moveCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("move", moveCmd, "move a task to another position")
+		return nil, usageErrorf("invalid arguments: %w", err)
 	}
 
-	resultTasks, err := todo.Delete(tasks, *id)
+	if err := requireFlags(moveCmd, "move a task to another position", "id", "to"); err != nil {
+		return nil, err
+	}
+
+	id, err := resolveIDFlag(tasks, moveCmd, "move a task to another position", *idFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	resultTasks, err := todo.Move(tasks, id, *to)
+	if errors.Is(err, todo.ErrInvalidPosition) {
+		printCommandUsage("move", moveCmd, "move a task to another position")
+		return nil, usageErrorf("cannot move task %d: %w", id, err)
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot move task %d: %w", id, err)
+	}
+
+	logger.ConsoleSuccess("Task %d moved to position %d", id, *to)
+	return resultTasks, nil
+}
+
+// handleClear processes the clear command to remove all completed tasks.
+// It requires --force because it removes many tasks at once (undo can
+// revert it, except with --append-only); without it only a warning with
+// the number of affected tasks is printed.
+// Returns the remaining tasks, or nil if nothing was removed.
+func handleClear(tasks []todo.Task, args []string) ([]todo.Task, error) {
+	logger.Debug("handleClear called with %d args", len(args))
+
+	clearCmd := flag.NewFlagSet("clear", flag.ContinueOnError)
+	force := clearCmd.Bool("force", false, "Confirm removing all completed tasks")
+	setupCommandConfig(clearCmd)
+
+	err := clearCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("clear", clearCmd, "remove all completed tasks")
+		return nil, usageErrorf("invalid arguments: %w", err)
+	}
+
+	remaining, removed := todo.ClearCompleted(tasks)
+	if removed == 0 {
+		logger.ConsoleHelp("No completed tasks to clear")
+		return nil, nil
+	}
+
+	if !*force {
+		logger.ConsoleInfo("Clearing removes %d completed tasks. Re-run with --force to confirm", removed)
+		return nil, nil
+	}
+
+	logger.ConsoleSuccess("Cleared %d completed tasks, %d tasks remain", removed, len(remaining))
+	return remaining, nil
+}
+
+// handlePin processes the pin command to keep a task at the top of list output.
+// It expects a --id flag with the task ID to pin, or "last".
+// Returns the updated task slice.
+func handlePin(tasks []todo.Task, args []string) ([]todo.Task, error) {
+	return handleSetPinned(tasks, args, "pin", true)
+}
+
+// handleUnpin processes the unpin command to return a task to its normal position.
+// It expects a --id flag with the task ID to unpin, or "last".
+// Returns the updated task slice.
+func handleUnpin(tasks []todo.Task, args []string) ([]todo.Task, error) {
+	return handleSetPinned(tasks, args, "unpin", false)
+}
+
+// handleSetPinned implements the pin and unpin commands.
+func handleSetPinned(tasks []todo.Task, args []string, name string, pinned bool) ([]todo.Task, error) {
+	logger.Debug("handleSetPinned called for %s with %d args", name, len(args))
+
+	description, status := "pin a task to the top of the list", "pinned"
+	if !pinned {
+		description, status = "unpin a task", "unpinned"
+	}
+
+	pinCmd := flag.NewFlagSet(name, flag.ContinueOnError)
+	idFlag := pinCmd.String("id", "", "Task ID, or 'last' for the newest task")
+	setupCommandConfig(pinCmd)
+
+	err := pinCmd.Parse(args)
 	if err != nil {
-		return nil, fmt.Errorf("cannot delete task %d: %w", *id, err)
+		printCommandUsage(name, pinCmd, description)
+		return nil, usageErrorf("invalid arguments: %w", err)
 	}
 
-	logger.ConsoleSuccess("Task %d deleted", *id)
+	if err := requireFlags(pinCmd, description, "id"); err != nil {
+		return nil, err
+	}
+
+	id, err := resolveIDFlag(tasks, pinCmd, description, *idFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	resultTasks, err := todo.SetPinned(tasks, id, pinned)
+	if err != nil {
+		return nil, fmt.Errorf("cannot %s task %d: %w", name, id, err)
+	}
+
+	logger.ConsoleSuccess("Task %d %s", id, status)
 	return resultTasks, nil
 }
 
+// handleTagAdd processes the tag-add command to tag every task matching a filter.
+// It expects a --tag flag and supports --filter with the same values as list.
+// Returns the updated task slice, or nil if no task changed.
+func handleTagAdd(tasks []todo.Task, args []string) ([]todo.Task, error) {
+	return handleBulkTag(tasks, args, "tag-add", true)
+}
+
+// handleTagRemove processes the tag-remove command to remove a tag from
+// every task matching a filter.
+// It expects a --tag flag and supports --filter with the same values as list.
+// Returns the updated task slice, or nil if no task changed.
+func handleTagRemove(tasks []todo.Task, args []string) ([]todo.Task, error) {
+	return handleBulkTag(tasks, args, "tag-remove", false)
+}
+
+// handleBulkTag implements the tag-add and tag-remove commands.
+// Tasks are selected with todo.List and changed with todo.AddTag or todo.RemoveTag.
+func handleBulkTag(tasks []todo.Task, args []string, name string, add bool) ([]todo.Task, error) {
+	logger.Debug("handleBulkTag called for %s with %d args", name, len(args))
+
+	description := "add a tag to all tasks matching a filter"
+	if !add {
+		description = "remove a tag from all tasks matching a filter"
+	}
+
+	tagCmd := flag.NewFlagSet(name, flag.ContinueOnError)
+	filter := tagCmd.String("filter", "all", "Task filter: all, done, pending, untagged")
+	tagFlag := tagCmd.String("tag", "", "Tag to add or remove")
+	setupCommandConfig(tagCmd)
+
+	err := tagCmd.Parse(args)
+	if err != nil {
+		printCommandUsage(name, tagCmd, description)
+		return nil, usageErrorf("invalid arguments: %w", err)
+	}
+
+	if err := requireFlags(tagCmd, description, "tag"); err != nil {
+		return nil, err
+	}
+	if !listFilters[*filter] {
+		printCommandUsage(name, tagCmd, description)
+		return nil, usageErrorf("invalid filter value '%s'", *filter)
+	}
+	tags := todo.NormalizeTags([]string{*tagFlag})
+	if len(tags) != 1 {
+		printCommandUsage(name, tagCmd, description)
+		return nil, usageErrorf("--tag must be a single non-empty tag, got '%s'", *tagFlag)
+	}
+	tag := tags[0]
+
+	matched := todo.List(tasks, *filter)
+	ids := make([]int, len(matched))
+	for i, task := range matched {
+		ids[i] = task.ID
+	}
+
+	var resultTasks []todo.Task
+	var changed int
+	if add {
+		resultTasks, changed = todo.AddTag(tasks, ids, tag)
+		logger.ConsoleSuccess("Tagged %d of %d matching tasks with '%s'", changed, len(matched), tag)
+	} else {
+		resultTasks, changed = todo.RemoveTag(tasks, ids, tag)
+		logger.ConsoleSuccess("Removed tag '%s' from %d of %d matching tasks", tag, changed, len(matched))
+	}
+	logger.Info("%s '%s' with filter '%s': %d of %d tasks changed", name, tag, *filter, changed, len(matched))
+	if changed == 0 {
+		return nil, nil
+	}
+	return resultTasks, nil
+}
+
+// handleCount processes the count command to print the number of tasks.
+// Supports --filter flag with the same values as list.
+// Prints only the number, e.g. for shell prompts; 0 for an empty list.
+func handleCount(tasks []todo.Task, args []string) error {
+	logger.Debug("handleCount called with %d args", len(args))
+
+	countCmd := flag.NewFlagSet("count", flag.ContinueOnError)
+	filter := countCmd.String("filter", "all", "Task filter: all, done, pending, untagged")
+	setupCommandConfig(countCmd)
+
+	err := countCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("count", countCmd, "print the number of tasks")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if !listFilters[*filter] {
+		printCommandUsage("count", countCmd, "print the number of tasks")
+		return usageErrorf("invalid filter value '%s'", *filter)
+	}
+
+	count := len(todo.List(tasks, *filter))
+	logger.Info("Counted %d tasks with filter '%s'", count, *filter)
+	fmt.Println(count)
+	return nil
+}
+
+// handleNext processes the next command to recommend what to work on.
+// It prints up to --count pending tasks (1 by default) in the order of
+// todo.Next: priority, then due date, then ID.
+// Returns a usage error if --count is less than 1.
+func handleNext(tasks []todo.Task, args []string) error {
+	logger.Debug("handleNext called with %d args", len(args))
+
+	nextCmd := flag.NewFlagSet("next", flag.ContinueOnError)
+	count := nextCmd.Int("count", 1, "Number of tasks to recommend")
+	full := addFullFlag(nextCmd)
+	setupCommandConfig(nextCmd)
+
+	err := nextCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("next", nextCmd, "recommend the next pending tasks")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if *count < 1 {
+		printCommandUsage("next", nextCmd, "recommend the next pending tasks")
+		return usageErrorf("count must be at least 1, got %d", *count)
+	}
+
+	next := todo.Next(tasks, *count)
+	if len(next) == 0 {
+		logger.Info("No pending tasks to recommend")
+		logger.ConsoleHelp("No pending tasks")
+		return nil
+	}
+
+	logger.Info("Recommending %d of %d requested tasks", len(next), *count)
+	opts := renderOptions{
+		Glyphs: outputGlyphs(),
+		Width:  terminalWidth(),
+		Full:   *full,
+		Now:    time.Now(),
+	}
+	for _, task := range next {
+		logger.ConsoleHelp(formatTaskLine(task, opts))
+	}
+	return nil
+}
+
+// handleSearch processes the search command to find tasks by keyword.
+// It expects a --query flag matched case-insensitively against descriptions,
+// or as a regular expression with --regex.
+// Supports --filter flag with the same values as list to search only
+// done, pending or untagged tasks.
+// Prints matches in the same format as the text list.
+// Returns a usage error if the filter or the regular expression is invalid.
+func handleSearch(tasks []todo.Task, args []string) error {
+	logger.Debug("handleSearch called with %d args", len(args))
+
+	searchCmd := flag.NewFlagSet("search", flag.ContinueOnError)
+	query := searchCmd.String("query", "", "Text to look for in task descriptions")
+	regex := searchCmd.Bool("regex", false, "Treat --query as a regular expression")
+	filter := searchCmd.String("filter", "all", "Task filter: all, done, pending, untagged")
+	full := addFullFlag(searchCmd)
+	setupCommandConfig(searchCmd)
+
+	err := searchCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("search", searchCmd, "search tasks by description")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if err := requireFlags(searchCmd, "search tasks by description", "query"); err != nil {
+		return err
+	}
+
+	if !listFilters[*filter] {
+		printCommandUsage("search", searchCmd, "search tasks by description")
+		return usageErrorf("invalid filter value '%s'", *filter)
+	}
+	candidates := todo.List(tasks, *filter)
+
+	var matches []todo.Task
+	if *regex {
+		matches, err = todo.SearchRegex(candidates, *query)
+		if err != nil {
+			printCommandUsage("search", searchCmd, "search tasks by description")
+			return usageErrorf("%w", err)
+		}
+	} else {
+		matches = todo.Search(candidates, *query)
+	}
+
+	if len(matches) == 0 {
+		logger.Info("No tasks match '%s'", *query)
+		logger.ConsoleHelp("No tasks match")
+		return nil
+	}
+
+	logger.Info("Search '%s' matched %d tasks", *query, len(matches))
+	opts := renderOptions{
+		Glyphs: outputGlyphs(),
+		Width:  terminalWidth(),
+		Full:   *full,
+		Now:    time.Now(),
+	}
+	for _, task := range matches {
+		logger.ConsoleHelp(formatTaskLine(task, opts))
+	}
+	return nil
+}
+
+// reportFile holds the task counts of one data file in report output.
+type reportFile struct {
+	File string `json:"file"`
+	todo.TaskStats
+}
+
+// statusReport is the report command output for --output=json.
+// Skipped lists the matching files that could not be loaded.
+type statusReport struct {
+	Files   []reportFile   `json:"files"`
+	Skipped []string       `json:"skipped,omitempty"`
+	Total   todo.TaskStats `json:"total"`
+}
+
+// handleReport processes the report command to summarize several data files.
+// It expects a --glob flag, e.g. "projects/*.json", and prints the total,
+// done and pending task counts of every matching file and of all files together.
+// Files that cannot be loaded or contain invalid tasks are skipped with a warning.
+// Sidecar files such as tasks.json.state.json and templates.json are
+// ignored, see storage.IsSidecar.
+// Supports --output flag with values: text, json.
+// Returns a usage error if the pattern is malformed.
+func handleReport(args []string) error {
+	logger.Debug("handleReport called with %d args", len(args))
+
+	reportCmd := flag.NewFlagSet("report", flag.ContinueOnError)
+	glob := reportCmd.String("glob", "", "Pattern of task files to summarize, e.g. \"*.json\"")
+	output := reportCmd.String("output", "text", "Output format: text, json")
+	setupCommandConfig(reportCmd)
+
+	err := reportCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("report", reportCmd, "summarize tasks across data files")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if err := requireFlags(reportCmd, "summarize tasks across data files", "glob"); err != nil {
+		return err
+	}
+	if *output != "text" && *output != "json" {
+		printCommandUsage("report", reportCmd, "summarize tasks across data files")
+		return usageErrorf("invalid output format '%s'", *output)
+	}
+
+	files, err := filepath.Glob(*glob)
+	if err != nil {
+		printCommandUsage("report", reportCmd, "summarize tasks across data files")
+		return usageErrorf("invalid --glob '%s': %w", *glob, err)
+	}
+
+	report := statusReport{Files: []reportFile{}}
+	for _, file := range files {
+		if storage.IsSidecar(file) {
+			logger.Debug("Ignoring sidecar file %s in report", file)
+			continue
+		}
+		tasks, err := storage.LoadJSON(file)
+		if err == nil {
+			err = todo.ValidateTasks(tasks)
+		}
+		if err != nil {
+			logger.Warn("Skipping %s in report: %v", file, err)
+			logger.ConsoleInfo("Skipping %s: %s", file, strings.ReplaceAll(err.Error(), "\n", "; "))
+			report.Skipped = append(report.Skipped, file)
+			continue
+		}
+		stats := todo.Stats(tasks)
+		report.Files = append(report.Files, reportFile{File: file, TaskStats: stats})
+		report.Total = report.Total.Add(stats)
+	}
+	logger.Info("Report over '%s': %d files, %d skipped", *glob, len(report.Files), len(report.Skipped))
+
+	if *output == "json" {
+		return printJSON(report, false)
+	}
+
+	if len(report.Files) == 0 {
+		logger.ConsoleHelp("No task files found")
+		return nil
+	}
+	for _, file := range report.Files {
+		logger.ConsoleHelpf("%s: %d total, %d done, %d pending", file.File, file.Total, file.Done, file.Pending)
+	}
+	logger.ConsoleHelpf("Total (%d files): %d total, %d done, %d pending", len(report.Files), report.Total.Total, report.Total.Done, report.Total.Pending)
+	return nil
+}
+
+// handleQuery processes the query command to evaluate a jq-style expression.
+// It expects an --expr flag, e.g. '.[] | select(.done==false) | .id'.
+// Prints one result value per line with no decoration for scripting.
+func handleQuery(tasks []todo.Task, args []string) error {
+	logger.Debug("handleQuery called with %d args", len(args))
+
+	queryCmd := flag.NewFlagSet("query", flag.ContinueOnError)
+	expr := queryCmd.String("expr", "", "Query expression: .[] | select(.field==value) | .field")
+	setupCommandConfig(queryCmd)
+
+	err := queryCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("query", queryCmd, "query tasks with a jq-style expression")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if err := requireFlags(queryCmd, "query tasks with a jq-style expression", "expr"); err != nil {
+		return err
+	}
+
+	results, err := todo.Query(tasks, *expr)
+	if err != nil {
+		printCommandUsage("query", queryCmd, "query tasks with a jq-style expression")
+		return usageErrorf("invalid query: %w", err)
+	}
+
+	logger.Info("Query '%s' returned %d results", *expr, len(results))
+	for _, line := range results {
+		fmt.Println(line)
+	}
+	return nil
+}
+
 // handleExport processes the export command to save tasks to a file.
-// Supports --format flag (json or csv) and --out flag for output file.
+// Supports --format flag (json, yaml, csv, tsv or export-only html) and --out flag for output file.
+// Supports --max-cell flag to truncate long CSV/TSV text fields.
+// Supports --checksum flag to write a <file>.sha256 sidecar for load --verify.
+// Supports --delimiter flag to separate CSV fields by another single character.
+// Supports --dry-run flag to print the resolved path, format and task count without writing.
 // Automatically adds file extension if not specified.
 func handleExport(tasks []todo.Task, args []string) error {
 	logger.Debug("handleExport called with %d args", len(args))
 
 	exportCmd := flag.NewFlagSet("export", flag.ContinueOnError)
-	format := exportCmd.String("format", "json", "Export format: json or csv")
+	format := exportCmd.String("format", "json", "Export format: json, yaml, csv, tsv or html")
 	outFile := exportCmd.String("out", "tasks_export", "Output file")
+	maxCell := exportCmd.Int("max-cell", 0, "Truncate CSV/TSV text fields to this many characters (0: no limit)")
+	checksum := exportCmd.Bool("checksum", false, "Write a SHA-256 sidecar file next to the export")
+	delimiter := exportCmd.String("delimiter", ",", "CSV field delimiter, a single character")
+	dryRun := exportCmd.Bool("dry-run", false, "Print what would be exported without writing any file")
 	setupCommandConfig(exportCmd)
 
 	err := exportCmd.Parse(args)
 	if err != nil {
 		printCommandUsage("export", exportCmd, "export tasks to file")
-		return fmt.Errorf("invalid arguments: %w", err)
+		return usageErrorf("invalid arguments: %w", err)
 	}
 
-	validFormats := map[string]bool{"json": true, "csv": true}
+	validFormats := map[string]bool{"json": true, "yaml": true, "csv": true, "tsv": true, "html": true}
 	if !validFormats[*format] {
 		printCommandUsage("export", exportCmd, "export tasks to file")
-		return fmt.Errorf("invalid format '%s'", *format)
+		return usageErrorf("invalid format '%s'", *format)
+	}
+
+	if *maxCell < 0 {
+		printCommandUsage("export", exportCmd, "export tasks to file")
+		return usageErrorf("max-cell cannot be negative, got %d", *maxCell)
+	}
+	if *maxCell > 0 && *format != "csv" && *format != "tsv" {
+		printCommandUsage("export", exportCmd, "export tasks to file")
+		return usageErrorf("--max-cell only applies to csv and tsv export, got format '%s'", *format)
+	}
+
+	comma, err := storage.ParseDelimiter(*delimiter)
+	if err != nil {
+		printCommandUsage("export", exportCmd, "export tasks to file")
+		return usageErrorf("invalid --delimiter: %w", err)
+	}
+	if comma != ',' && *format != "csv" {
+		printCommandUsage("export", exportCmd, "export tasks to file")
+		return usageErrorf("--delimiter only applies to csv export, got format '%s'", *format)
 	}
 
 	if !strings.HasSuffix(*outFile, "."+*format) {
 		*outFile = *outFile + "." + *format
 	}
 
+	if *dryRun {
+		logger.Info("Dry run: would export %d tasks to %s (%s)", len(tasks), *outFile, *format)
+		logger.ConsoleHelpf("Would export %d tasks to %s (%s)", len(tasks), *outFile, *format)
+		return nil
+	}
+
 	switch *format {
 	case "json":
 		err = storage.SaveJSON(*outFile, tasks)
+	case "yaml":
+		err = storage.SaveYAML(*outFile, tasks)
 	case "csv":
-		err = storage.SaveCSV(*outFile, tasks)
+		err = storage.SaveCSVDelimited(*outFile, truncateCells(tasks, *maxCell), comma)
+	case "tsv":
+		err = storage.SaveTSV(*outFile, truncateCells(tasks, *maxCell))
+	case "html":
+		err = storage.SaveHTML(*outFile, tasks)
 	}
 
 	if err != nil {
@@ -190,65 +1222,394 @@ func handleExport(tasks []todo.Task, args []string) error {
 
 	logger.Info("Tasks exported to %s", *outFile)
 	logger.ConsoleHelpf("Tasks exported to %s", *outFile)
+
+	if *checksum {
+		if err := storage.WriteChecksum(*outFile); err != nil {
+			return fmt.Errorf("export error: %w", err)
+		}
+		logger.ConsoleHelpf("Checksum written to %s", storage.ChecksumPath(*outFile))
+	}
 	return nil
 }
 
 // handleLoad processes the load command to import tasks from a file.
 // It expects a --file flag with the path to import from.
-// Supports JSON and CSV formats based on file extension.
-// Returns the imported tasks slice and error if any.
-func handleLoad(args []string) ([]todo.Task, error) {
+// Supports JSON, YAML (.yaml or .yml), CSV and TSV formats based on file extension,
+// or forced with the --format flag regardless of extension.
+// By default the imported tasks replace tasks; with --append they are
+// merged into tasks by todo.Merge, with new IDs and without duplicates.
+// With --replace-ids they are all appended by todo.AppendRenumbered, numbered
+// sequentially after the highest current ID, ignoring the IDs in the file.
+// Supports --delimiter flag for CSV files separated by another single character.
+// Malformed or invalid CSV and TSV records are skipped and reported, with
+// --strict the first one aborts the import with its error instead.
+// With --verify the file is checked against its .sha256 sidecar, if present,
+// and nothing is imported on a mismatch.
+// Returns the resulting tasks slice, an ImportResult with imported and
+// skipped record counts, and error if any.
+func handleLoad(tasks []todo.Task, args []string) ([]todo.Task, storage.ImportResult, error) {
 	logger.Debug("handleLoad called with %d args", len(args))
 
 	loadCmd := flag.NewFlagSet("load", flag.ContinueOnError)
 	file := loadCmd.String("file", "", "File to import from")
+	format := loadCmd.String("format", "", "Force import format: json, yaml, csv or tsv (default: by extension)")
+	appendTasks := loadCmd.Bool("append", false, "Merge imported tasks into the current ones (default: replace all tasks)")
+	verify := loadCmd.Bool("verify", false, "Check the file against its .sha256 sidecar before importing")
+	replaceIDs := loadCmd.Bool("replace-ids", false, "Append all imported tasks with new sequential IDs after the current highest ID")
+	delimiter := loadCmd.String("delimiter", ",", "CSV field delimiter, a single character")
+	strict := loadCmd.Bool("strict", false, "Abort on the first malformed or invalid CSV or TSV record instead of skipping it")
 	setupCommandConfig(loadCmd)
 
-	if len(args) == 0 {
-		return nil, fmt.Errorf("load command requires --file flag: specify file to import")
-	}
-
 	err := loadCmd.Parse(args)
 	if err != nil {
-		return nil, fmt.Errorf("invalid arguments: %w", err)
+		printCommandUsage("load", loadCmd, "import tasks from file")
+		return nil, storage.ImportResult{}, usageErrorf("invalid arguments: %w", err)
 	}
 
-	if *file == "" {
-		return nil, fmt.Errorf("import file is required")
+	if err := requireFlags(loadCmd, "import tasks from file", "file"); err != nil {
+		return nil, storage.ImportResult{}, err
+	}
+	if *appendTasks && *replaceIDs {
+		printCommandUsage("load", loadCmd, "import tasks from file")
+		return nil, storage.ImportResult{}, usageErrorf("--append and --replace-ids cannot be combined")
+	}
+	comma, err := storage.ParseDelimiter(*delimiter)
+	if err != nil {
+		printCommandUsage("load", loadCmd, "import tasks from file")
+		return nil, storage.ImportResult{}, usageErrorf("invalid --delimiter: %w", err)
 	}
 
-	if _, err := os.Stat(*file); os.IsNotExist(err) {
+	if *format != "" {
+		validFormats := map[string]bool{"json": true, "yaml": true, "csv": true, "tsv": true}
+		if !validFormats[*format] {
+			printCommandUsage("load", loadCmd, "import tasks from file")
+			return nil, storage.ImportResult{}, usageErrorf("invalid format '%s'", *format)
+		}
+		// A forced format reads the file exactly as given, without extension sniffing
+		if _, err := os.Stat(*file); err != nil {
+			return nil, storage.ImportResult{}, fmt.Errorf("file does not exist: %s", *file)
+		}
+	} else if _, err := os.Stat(*file); os.IsNotExist(err) {
 		if _, err := os.Stat(*file + ".csv"); err == nil {
 			*file = *file + ".csv"
 		} else if _, err := os.Stat(*file + ".json"); err == nil {
 			*file = *file + ".json"
 		} else {
-			return nil, fmt.Errorf("file does not exist: %s", *file)
+			return nil, storage.ImportResult{}, fmt.Errorf("file does not exist: %s", *file)
+		}
+	}
+
+	if *verify {
+		verified, err := storage.VerifyChecksum(*file)
+		if err != nil {
+			return nil, storage.ImportResult{}, fmt.Errorf("verify error: %w", err)
+		}
+		if verified {
+			logger.ConsoleHelpf("Checksum of %s verified", *file)
+		} else {
+			logger.Warn("No checksum sidecar for %s, importing unverified", *file)
+			logger.ConsoleInfo("No checksum file %s, skipping verification", storage.ChecksumPath(*file))
 		}
 	}
 
-	// Determine format by file extension
-	ext := strings.ToLower(filepath.Ext(*file))
+	// Determine format by file extension unless it was forced
+	ext := "." + *format
+	if *format == "" {
+		ext = strings.ToLower(filepath.Ext(*file))
+	}
+	if comma != ',' && ext != ".csv" {
+		printCommandUsage("load", loadCmd, "import tasks from file")
+		return nil, storage.ImportResult{}, usageErrorf("--delimiter only applies to csv files, got format '%s'", ext)
+	}
 	var importedTasks []todo.Task
+	var result storage.ImportResult
 
 	logger.Info("Starting import from file: %s (format: %s)", *file, ext)
 
 	switch ext {
 	case ".json":
 		importedTasks, err = storage.LoadJSON(*file)
+		result.Imported = len(importedTasks)
+	case ".yaml", ".yml":
+		importedTasks, err = storage.LoadYAML(*file)
+		result.Imported = len(importedTasks)
 	case ".csv":
-		importedTasks, err = storage.LoadCSV(*file)
+		importedTasks, result, err = storage.LoadCSVDelimited(*file, comma, *strict)
+	case ".tsv":
+		if *strict {
+			importedTasks, result, err = storage.LoadCSVDelimited(*file, '\t', true)
+		} else {
+			importedTasks, result, err = storage.LoadTSVWithResult(*file)
+		}
 	default:
-		return nil, fmt.Errorf("unsupported file format: %s", ext)
+		return nil, storage.ImportResult{}, fmt.Errorf("unsupported file format: %s", ext)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("import error: %w", err)
+		return nil, storage.ImportResult{}, fmt.Errorf("import error: %w", err)
 	}
 
-	logger.Info("Successfully imported %d tasks from %s", len(importedTasks), *file)
-	logger.ConsoleHelpf("Successfully imported %d tasks from %s", len(importedTasks), *file)
-	return importedTasks, nil
+	if *appendTasks {
+		merged, duplicates := todo.Merge(tasks, importedTasks, idStrategy)
+		logger.Info("Merged %d tasks from %s into %d existing tasks, skipped %d duplicates", len(importedTasks)-duplicates, *file, len(tasks), duplicates)
+		if duplicates > 0 {
+			logger.ConsoleHelpf("Skipped %d tasks that already exist", duplicates)
+		}
+		importedTasks = merged
+	} else if *replaceIDs {
+		logger.Info("Appended %d tasks from %s with new IDs after %d existing tasks", len(importedTasks), *file, len(tasks))
+		importedTasks = todo.AppendRenumbered(tasks, importedTasks)
+	}
+
+	logger.Info("Successfully imported %d tasks from %s", result.Imported, *file)
+	if result.Skipped > 0 {
+		logger.ConsoleHelpf("Imported %d tasks from %s, skipped %d invalid records:", result.Imported, *file, result.Skipped)
+		for _, message := range result.Errors {
+			logger.ConsoleHelpf("  %s", message)
+		}
+	} else {
+		logger.ConsoleHelpf("Successfully imported %d tasks from %s", result.Imported, *file)
+	}
+	return importedTasks, result, nil
+}
+
+// handleSnapshot processes the snapshot command to save a named copy of the tasks.
+// It expects a --name flag; the copy is stored as snapshots/<name>.json.
+func handleSnapshot(tasks []todo.Task, args []string) error {
+	logger.Debug("handleSnapshot called with %d args", len(args))
+
+	snapshotCmd := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	name := snapshotCmd.String("name", "", "Snapshot name")
+	setupCommandConfig(snapshotCmd)
+
+	err := snapshotCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("snapshot", snapshotCmd, "save a named snapshot of tasks")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if err := requireFlags(snapshotCmd, "save a named snapshot of tasks", "name"); err != nil {
+		return err
+	}
+	if err := storage.ValidateSnapshotName(*name); err != nil {
+		printCommandUsage("snapshot", snapshotCmd, "save a named snapshot of tasks")
+		return usageErrorf("%w", err)
+	}
+
+	path, err := storage.SaveSnapshot(storage.SnapshotDir(tasksFile), *name, tasks)
+	if err != nil {
+		return fmt.Errorf("snapshot error: %w", err)
+	}
+
+	logger.ConsoleSuccess("Snapshot '%s' saved with %d tasks to %s", *name, len(tasks), path)
+	return nil
+}
+
+// handleRestoreSnapshot processes the restore-snapshot command to load a named snapshot.
+// It expects a --name flag and requires --force because it replaces all current tasks.
+// Returns the restored tasks, or nil if the restore was not confirmed.
+func handleRestoreSnapshot(args []string) ([]todo.Task, error) {
+	logger.Debug("handleRestoreSnapshot called with %d args", len(args))
+
+	restoreCmd := flag.NewFlagSet("restore-snapshot", flag.ContinueOnError)
+	name := restoreCmd.String("name", "", "Snapshot name")
+	force := restoreCmd.Bool("force", false, "Confirm replacing all current tasks")
+	setupCommandConfig(restoreCmd)
+
+	err := restoreCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("restore-snapshot", restoreCmd, "restore tasks from a named snapshot")
+		return nil, usageErrorf("invalid arguments: %w", err)
+	}
+
+	if err := requireFlags(restoreCmd, "restore tasks from a named snapshot", "name"); err != nil {
+		return nil, err
+	}
+
+	tasks, err := storage.LoadSnapshot(storage.SnapshotDir(tasksFile), *name)
+	if err != nil {
+		return nil, fmt.Errorf("restore error: %w", err)
+	}
+
+	if !*force {
+		logger.ConsoleInfo("Restoring snapshot '%s' replaces all current tasks with %d tasks. Re-run with --force to confirm", *name, len(tasks))
+		return nil, nil
+	}
+
+	logger.ConsoleSuccess("Restored %d tasks from snapshot '%s'", len(tasks), *name)
+	return tasks, nil
+}
+
+// handleSnapshots processes the snapshots command to list available snapshots.
+func handleSnapshots(args []string) error {
+	logger.Debug("handleSnapshots called with %d args", len(args))
+
+	snapshotsCmd := flag.NewFlagSet("snapshots", flag.ContinueOnError)
+	setupCommandConfig(snapshotsCmd)
+
+	err := snapshotsCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("snapshots", snapshotsCmd, "list saved snapshots")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	names, err := storage.ListSnapshots(storage.SnapshotDir(tasksFile))
+	if err != nil {
+		return fmt.Errorf("cannot list snapshots: %w", err)
+	}
+
+	if len(names) == 0 {
+		logger.ConsoleHelp("No snapshots found")
+		return nil
+	}
+
+	logger.ConsoleHelpf("Snapshots (%d):", len(names))
+	for _, name := range names {
+		logger.ConsoleHelpf("  %s", name)
+	}
+	return nil
+}
+
+// handleTemplate processes the template command to manage task templates.
+// Subcommands:
+//   - save: store a template from --name, --priority and comma-separated --tags,
+//     replacing a template with the same name
+//   - list: print all saved templates
+//
+// Templates are kept in templates.json next to the data file
+// and applied with add --template.
+func handleTemplate(args []string) error {
+	logger.Debug("handleTemplate called with %d args", len(args))
+
+	if len(args) == 0 {
+		logger.ConsoleHelp("Usage: <app> template save|list [flags]")
+		return usageErrorf("missing template subcommand: save or list")
+	}
+
+	path := storage.TemplatesPath(tasksFile)
+	switch args[0] {
+	case "save":
+		saveCmd := flag.NewFlagSet("template save", flag.ContinueOnError)
+		name := saveCmd.String("name", "", "Template name")
+		priority := saveCmd.String("priority", "", "Priority of new tasks: low, medium or high")
+		tags := saveCmd.String("tags", "", "Tags of new tasks separated by commas")
+		setupCommandConfig(saveCmd)
+
+		if err := saveCmd.Parse(args[1:]); err != nil {
+			printCommandUsage("template save", saveCmd, "save a task template")
+			return usageErrorf("invalid arguments: %w", err)
+		}
+		if err := requireFlags(saveCmd, "save a task template", "name"); err != nil {
+			return err
+		}
+
+		tmpl := todo.Template{
+			Name:     *name,
+			Priority: *priority,
+			Tags:     todo.NormalizeTags(strings.Split(*tags, ",")),
+		}
+		if err := todo.ValidateTemplate(tmpl); err != nil {
+			printCommandUsage("template save", saveCmd, "save a task template")
+			return usageErrorf("invalid template: %w", err)
+		}
+		if err := storage.SaveTemplate(path, tmpl); err != nil {
+			return fmt.Errorf("template error: %w", err)
+		}
+		logger.ConsoleSuccess("Template '%s' saved to %s", tmpl.Name, path)
+		return nil
+	case "list":
+		templates, err := storage.LoadTemplates(path)
+		if err != nil {
+			return fmt.Errorf("cannot list templates: %w", err)
+		}
+		if len(templates) == 0 {
+			logger.ConsoleHelp("No templates found")
+			return nil
+		}
+		logger.ConsoleHelpf("Templates (%d):", len(templates))
+		for _, tmpl := range templates {
+			priority := tmpl.Priority
+			if priority == "" {
+				priority = "-"
+			}
+			logger.ConsoleHelpf("  %s (priority: %s, tags: %s)", tmpl.Name, priority, strings.Join(tmpl.Tags, ", "))
+		}
+		return nil
+	default:
+		logger.ConsoleHelp("Usage: <app> template save|list [flags]")
+		return usageErrorf("unknown template subcommand '%s': use save or list", args[0])
+	}
+}
+
+// usageError reports invalid command line usage, such as unknown flags,
+// missing required flags, or invalid flag values.
+// It lets run() exit with exitUsage instead of the generic error code.
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// consoleError shows an error to the user with logger.ConsoleError.
+// With --log-errors-only it is only written to the log file.
+func consoleError(format string, v ...any) {
+	if logErrorsOnly {
+		logger.Error(format, v...)
+		return
+	}
+	logger.ConsoleError(format, v...)
+}
+
+// usageErrorf formats an error message and marks it as a usage error.
+func usageErrorf(format string, a ...any) error {
+	return &usageError{err: fmt.Errorf(format, a...)}
+}
+
+// stringList is a flag.Value that collects every occurrence of a repeated flag.
+type stringList []string
+
+// String returns the collected values separated by commas.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set appends a value of the flag.
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// requireFlags checks that every named flag was given a non-default value.
+// If a flag is missing it prints the command usage and returns a usage error,
+// so all commands report missing required flags the same way.
+func requireFlags(cmd *flag.FlagSet, description string, names ...string) error {
+	for _, name := range names {
+		f := cmd.Lookup(name)
+		if f == nil || f.Value.String() == f.DefValue {
+			printCommandUsage(cmd.Name(), cmd, description)
+			return usageErrorf("missing required flag --%s", name)
+		}
+	}
+	return nil
+}
+
+// resolveIDFlag resolves an --id flag value that may be a number or "last".
+// Malformed IDs print the command usage and return a usage error.
+func resolveIDFlag(tasks []todo.Task, cmd *flag.FlagSet, description, value string) (int, error) {
+	id, err := todo.ResolveID(tasks, value)
+	if errors.Is(err, todo.ErrInvalidID) {
+		printCommandUsage(cmd.Name(), cmd, description)
+		return 0, usageErrorf("%w", err)
+	} else if err != nil {
+		return 0, fmt.Errorf("cannot resolve task ID: %w", err)
+	}
+	return id, nil
 }
 
 // printCommandUsage displays formatted help for a specific command.
@@ -267,7 +1628,21 @@ func printCommandUsage(cmd string, flags *flag.FlagSet, description string) {
 	} else if cmd == "export" {
 		exampleFlag = "--format=csv|json --out=backup"
 	} else if cmd == "load" {
-		exampleFlag = "--file=tasks.csv | tasks.json"
+		exampleFlag = "--file=tasks.csv | tasks.json | tasks.dat --format=json"
+	} else if cmd == "snapshot" || cmd == "restore-snapshot" {
+		exampleFlag = "--name=sprint1"
+	} else if cmd == "query" {
+		exampleFlag = "--expr='.[] | select(.done==false) | .id'"
+	} else if cmd == "next" {
+		exampleFlag = "--count=3"
+	} else if cmd == "search" {
+		exampleFlag = "--query=milk"
+	} else if cmd == "clear" {
+		exampleFlag = "--force"
+	} else if cmd == "report" {
+		exampleFlag = "--glob=\"projects/*.json\""
+	} else if cmd == "template save" {
+		exampleFlag = "--name=bug --tags=bug,urgent --priority=high"
 	}
 
 	message := fmt.Sprintf(
@@ -282,28 +1657,191 @@ func printCommandUsage(cmd string, flags *flag.FlagSet, description string) {
 	logger.ConsoleHelp(message)
 }
 
+// versionInfo is the build information printed by the version command.
+type versionInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Date    string `json:"date"`
+}
+
+// handleVersion processes the version command to print build information.
+// Supports --output flag with values: text, json.
+// Returns a usage error if the output format is unknown.
+func handleVersion(args []string) error {
+	logger.Debug("handleVersion called with %d args", len(args))
+
+	versionCmd := flag.NewFlagSet("version", flag.ContinueOnError)
+	output := versionCmd.String("output", "text", "Output format: text, json")
+	setupCommandConfig(versionCmd)
+
+	err := versionCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("version", versionCmd, "print build information")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if *output != "text" && *output != "json" {
+		printCommandUsage("version", versionCmd, "print build information")
+		return usageErrorf("invalid output format '%s'", *output)
+	}
+
+	info := versionInfo{Version: Version, Commit: Commit, Date: Date}
+	if *output == "json" {
+		return printJSON(info, false)
+	}
+	logger.ConsoleHelpf("todo %s (commit %s, built %s)", info.Version, info.Commit, info.Date)
+	return nil
+}
+
+// handleUndo processes the undo command to revert the last change.
+// It swaps the data file with the backup saved before the last change,
+// so running undo again redoes the change.
+// Without a backup there is nothing to undo, which is not an error.
+// With --append-only it is rejected: the event log is the history
+// and is never rewritten, so there is no backup to swap with.
+func handleUndo(args []string, appendOnly bool) error {
+	logger.Debug("handleUndo called with %d args", len(args))
+
+	undoCmd := flag.NewFlagSet("undo", flag.ContinueOnError)
+	setupCommandConfig(undoCmd)
+
+	err := undoCmd.Parse(args)
+	if err != nil {
+		printCommandUsage("undo", undoCmd, "revert the last change")
+		return usageErrorf("invalid arguments: %w", err)
+	}
+
+	if appendOnly {
+		return usageErrorf("undo cannot be used with --append-only, the event log is never rewritten")
+	}
+
+	before, beforeErr := dataStore.Load(tasksFile)
+
+	swapped, err := storage.SwapBackup(tasksFile)
+	if err != nil {
+		return fmt.Errorf("cannot undo: %w", err)
+	}
+	if !swapped {
+		logger.ConsoleInfo("Nothing to undo")
+		return nil
+	}
+
+	restored, err := dataStore.Load(tasksFile)
+	if err != nil {
+		return fmt.Errorf("restored backup of %s cannot be loaded, run undo again to go back: %w", tasksFile, err)
+	}
+	if beforeErr != nil {
+		logger.ConsoleSuccess("Restored %d tasks in %s", len(restored), tasksFile)
+	} else {
+		logger.ConsoleSuccess("Restored %d tasks in %s (was %d), run undo again to redo", len(restored), tasksFile, len(before))
+	}
+	return nil
+}
+
 // printUsage displays the main help message with all available commands.
 // It provides an overview of the application and usage examples.
 func printUsage() {
 	fmt.Println("To-Do Manager - command line task management")
-	fmt.Println("Usage: <app_name> <command> [arguments]")
+	fmt.Println("Usage: <app_name> [global flags] <command> [arguments]")
 	fmt.Println()
 	fmt.Println("Available commands:")
 	fmt.Println("-  add --desc=\"description\"          - add a new task")
+	fmt.Println("-  add --desc=\"...\" --ref=URL        - add a task linked to a ticket")
+	fmt.Println("-  add --desc=\"A\" --desc=\"B\"         - add several tasks (or --descs=\"A,B\")")
+	fmt.Println("-  add --desc=\"...\" --priority=high  - add a task with priority low|medium|high")
+	fmt.Println("-  add --desc=\"...\" --due=2024-06-01 - add a task with a due date (or 2024-06-01T15:00)")
+	fmt.Println("-  add --desc=\"...\" --tag=work       - add a tagged task (repeat --tag for several)")
+	fmt.Println("-  add --desc=\"...\" --template=bug   - add a task with a saved template's fields")
+	fmt.Println("-  add --desc=\"...\" --no-dup         - skip the task if the description already exists")
 	fmt.Println("-  list [--filter=all|done|pending]    - list tasks")
-	fmt.Println("-  complete --id=ID                    - mark task as completed")
-	fmt.Println("-  delete --id=ID                      - delete a task")
+	fmt.Println("-  list --filter=untagged              - list tasks without tags")
+	fmt.Println("-  list --tag=work                     - list tasks with a tag")
+	fmt.Println("-  list --overdue                      - list pending tasks past their due date")
+	fmt.Println("-  list --after=10 --before=20         - list tasks with IDs 10 to 20 (either bound optional)")
+	fmt.Println("-  list --count-by=status|priority|tag - count tasks per group")
+	fmt.Println("-  list --output=json|yaml --verbose   - list with filter and count metadata")
+	fmt.Println("-  list --output=csv|tsv [--no-header] - print tasks as CSV/TSV, optionally without header")
+	fmt.Println("-  list --output=ids-csv               - print matching IDs as 1,4,7")
+	fmt.Println("-  list --sort=random [--seed=N]       - list in shuffled order (also id, order, description, status)")
+	fmt.Println("-  list --compact                      - one-line summary of pending and done IDs")
+	fmt.Println("-  list --output=json --compact[=false] - JSON without indentation (default when piped)")
+	fmt.Println("-  list --no-summary                   - omit the \"Showing X of Y tasks\" line")
+	fmt.Println("-  list --full                         - print complete descriptions, never truncate")
+	fmt.Println("-  list --since-last-run               - list tasks added since last list")
+	fmt.Println("-  list --max-age=DAYS                 - mark pending tasks older than DAYS (default 30) as stale")
+	fmt.Println("-  count [--filter=all|done|pending]   - print only the number of tasks")
+	fmt.Println("-  complete --id=ID|last               - mark task as completed")
+	fmt.Println("-  complete --match=\"text\"             - complete the best fuzzy match")
+	fmt.Println("-  complete --id=ID --reopen-if-done   - toggle: reopen the task if it is already done")
+	fmt.Println("-  uncomplete --id=ID|last             - reopen a completed task")
+	fmt.Println("-  next [--count=N]                    - show the N most urgent pending tasks")
+	fmt.Println("-  search --query=text [--regex]       - find tasks by description")
+	fmt.Println("-  search --query=text --filter=done   - search only done, pending or untagged tasks")
+	fmt.Println("-  delete --id=ID|last                 - delete a task")
+	fmt.Println("-  delete --completed-before=DATE      - delete done tasks completed before DATE, --yes for more than 10")
+	fmt.Println("-  move --id=ID|last --to=N            - move a task to position N in list output")
+	fmt.Println("-  clear --force                       - remove all completed tasks")
+	fmt.Println("-  pin --id=ID|last                    - keep a task at the top of the list")
+	fmt.Println("-  unpin --id=ID|last                  - unpin a task")
+	fmt.Println("-  tag-add --tag=T [--filter=pending]  - add a tag to every task matching the filter")
+	fmt.Println("-  tag-remove --tag=T [--filter=done]  - remove a tag from every task matching the filter")
+	fmt.Println("-  query --expr=expression             - query tasks (jq-style subset)")
 	fmt.Println("-  export --format=json|csv --out=file - export tasks")
-	fmt.Println("-  load --file=file                    - import tasks from file")
+	fmt.Println("-  export --format=tsv --out=file      - export tab-separated tasks")
+	fmt.Println("-  export --format=yaml --out=file     - export tasks as YAML")
+	fmt.Println("-  export --format=csv --max-cell=N    - export CSV with long fields truncated")
+	fmt.Println("-  export --format=csv --delimiter=\";\" - export CSV separated by another character")
+	fmt.Println("-  export --format=html --out=file     - export a shareable HTML page")
+	fmt.Println("-  export --out=file --dry-run         - show path, format and task count without writing")
+	fmt.Println("-  export --out=file --checksum        - also write a file.sha256 sidecar")
+	fmt.Println("-  load --file=file                    - import tasks from file, replacing all tasks")
+	fmt.Println("-  load --file=file --append           - merge imported tasks, skipping duplicates")
+	fmt.Println("-  load --file=file --replace-ids      - append all imported tasks with fresh sequential IDs")
+	fmt.Println("-  load --file=file --format=json|csv  - import ignoring file extension (also yaml, tsv)")
+	fmt.Println("-  load --file=file.csv --delimiter=\";\" - import CSV separated by another character")
+	fmt.Println("-  load --file=file --verify           - check the .sha256 sidecar before importing")
+	fmt.Println("-  report --glob=\"*.json\"              - per-file and total task counts (--output=json)")
+	fmt.Println("-  snapshot --name=NAME                - save a named snapshot of tasks")
+	fmt.Println("-  restore-snapshot --name=NAME        - restore a snapshot (needs --force)")
+	fmt.Println("-  snapshots                           - list saved snapshots")
+	fmt.Println("-  template save --name=NAME           - save a template (--priority, --tags=a,b)")
+	fmt.Println("-  template list                       - list saved templates")
+	fmt.Println("-  undo                                - revert the last change (run again to redo, not with --append-only)")
+	fmt.Println("-  doctor                              - diagnose data file, lock and log problems")
+	fmt.Println("-  version [--output=json]             - print version, commit and build date")
 	fmt.Println("-  help                                - show this help message")
 	fmt.Println()
+	fmt.Println("Aliases:")
+	aliases := make([]string, 0, len(commandAliases))
+	for alias := range commandAliases {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		fmt.Printf("-  %-34s - %s\n", alias, commandAliases[alias])
+	}
+	fmt.Println()
+	fmt.Println("Global flags:")
+	fmt.Println("-  --append-only                       - store changes in an append-only event log")
+	fmt.Println("-  --verbose                           - log a lock self-check timing at startup")
+	fmt.Println("-  --strict-ids                        - renumber tasks to contiguous IDs on save")
+	fmt.Println("-  --reuse-ids                         - give new tasks the lowest free ID")
+	fmt.Println("-  --lock-dir=DIR                      - create lock files in DIR, not next to the data file")
+	fmt.Println("-  --file=PATH                         - tasks file (default: $TODO_FILE, then tasks.json)")
+	fmt.Println("-  --data-format=json|yaml|csv|tsv     - data file format (default: $TODO_DATA_FORMAT, then json)")
+	fmt.Println("-  --log-errors-only                   - log errors to the file only, not to the console")
+	fmt.Println()
 	fmt.Println("Examples:")
 	fmt.Println("  <app_name> add --desc=\"Buy milk\"")
 	fmt.Println("  <app_name> list --filter=pending")
+	fmt.Println("  <app_name> list --count-by=status --output=json")
 	fmt.Println("  <app_name> complete --id=3")
 	fmt.Println("  <app_name> delete --id=3")
+	fmt.Println("  <app_name> query --expr='.[] | select(.done==false) | .id'")
 	fmt.Println("  <app_name> export --format=csv --out=backup")
 	fmt.Println("  <app_name> load --file=tasks.csv")
+	fmt.Println("  <app_name> snapshot --name=sprint1")
+	fmt.Println("  <app_name> --append-only add --desc=\"Buy milk\"")
 	fmt.Println("  <app_name> help")
 }
 
@@ -0,0 +1,2899 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"todo-app/internal/storage"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// captureOutput runs fn and returns everything it printed to stdout.
+func captureOutput(t *testing.T, fn func()) string {
+	t.Helper()
+
+	orig := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	fn()
+
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read captured output: %v", err)
+	}
+	return string(out)
+}
+
+// chdirTemp switches the working directory to a fresh temporary directory
+// for the duration of the test, so handlers can create their files freely.
+func chdirTemp(t *testing.T) {
+	t.Helper()
+
+	orig, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
+	t.Cleanup(func() { os.Chdir(orig) })
+}
+
+func TestListSinceLastRun(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 5, Description: "Old task", Done: false, CreatedAt: time.Now().Add(-time.Hour)},
+	}
+
+	// A plain list doesn't record a marker
+	captureOutput(t, func() {
+		if err := handleList(tasks, nil); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if _, err := os.Stat(storage.StatePath(tasksFile)); !os.IsNotExist(err) {
+		t.Errorf("Plain list should not write the state file, stat error: %v", err)
+	}
+
+	// First run sees every task and records the marker
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--since-last-run"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Old task") {
+		t.Errorf("First run should show existing task, got: %s", out)
+	}
+
+	// A reused lower ID is still new, since it is decided by creation time
+	tasks = append(tasks, todo.Task{ID: 1, Description: "New task", Done: false, CreatedAt: time.Now()})
+
+	// Second run shows only the task added in between
+	out = captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--since-last-run"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "New task") {
+		t.Errorf("Second run should show new task, got: %s", out)
+	}
+	if strings.Contains(out, "Old task") {
+		t.Errorf("Second run should not show old task, got: %s", out)
+	}
+
+	// Third run without changes shows nothing new
+	out = captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--since-last-run"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "No tasks found") {
+		t.Errorf("Third run should report no new tasks, got: %s", out)
+	}
+}
+
+func TestListSinceLastRunCSV(t *testing.T) {
+	chdirTemp(t)
+
+	store, err := storage.NewStore("csv")
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	reload := func(tasks []todo.Task) []todo.Task {
+		if err := store.Save("tasks.csv", tasks); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+		loaded, err := store.Load("tasks.csv")
+		if err != nil {
+			t.Fatalf("Load failed: %v", err)
+		}
+		return loaded
+	}
+	listNew := func(tasks []todo.Task) string {
+		return captureOutput(t, func() {
+			if err := handleList(tasks, []string{"--since-last-run", "--output=ids-csv"}); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+	}
+
+	tasks := reload([]todo.Task{{ID: 1, Description: "Old task", CreatedAt: time.Now().Add(-time.Hour)}})
+	listNew(tasks)
+	state, err := storage.LoadState(storage.StatePath(tasksFile))
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+
+	// A task added in the same second as the last run, just after it,
+	// is new once and only once after a CSV round trip
+	tasks = reload(append(tasks, todo.Task{ID: 2, Description: "New task", CreatedAt: state.LastRun.Add(time.Millisecond)}))
+	time.Sleep(2 * time.Millisecond)
+	if out := strings.TrimSpace(listNew(tasks)); out != "2" {
+		t.Errorf("Expected task 2 to be new after a CSV round trip, got %q", out)
+	}
+	if out := strings.TrimSpace(listNew(reload(tasks))); out != "" {
+		t.Errorf("Expected no new tasks on the next run, got %q", out)
+	}
+}
+
+func TestRequiredFlagsMissing(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+	}
+
+	commands := map[string]func() error{
+		"add": func() error {
+			_, err := handleAdd(tasks, []string{})
+			return err
+		},
+		"complete": func() error {
+			_, err := handleComplete(tasks, []string{})
+			return err
+		},
+		"uncomplete": func() error {
+			_, err := handleUncomplete(tasks, []string{})
+			return err
+		},
+		"delete": func() error {
+			_, err := handleDelete(tasks, []string{})
+			return err
+		},
+		"load": func() error {
+			_, _, err := handleLoad(nil, []string{})
+			return err
+		},
+	}
+
+	for name, fn := range commands {
+		var err error
+		out := captureOutput(t, func() { err = fn() })
+
+		var usageErr *usageError
+		if !errors.As(err, &usageErr) {
+			t.Errorf("%s: expected usage error, got %v", name, err)
+		}
+		if exitCode(err) != exitUsage {
+			t.Errorf("%s: expected exit code %d, got %d", name, exitUsage, exitCode(err))
+		}
+		if !strings.Contains(out, "Usage: <app> "+name) {
+			t.Errorf("%s: expected command usage to be printed, got: %s", name, out)
+		}
+	}
+}
+
+func TestOptionalFlagsCommandsWithoutArgs(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+	}
+
+	captureOutput(t, func() {
+		if err := handleList(tasks, []string{}); err != nil {
+			t.Errorf("list without args should succeed, got %v", err)
+		}
+		if err := handleExport(tasks, []string{}); err != nil {
+			t.Errorf("export without args should succeed, got %v", err)
+		}
+	})
+}
+
+func TestLoadWithFormatOverride(t *testing.T) {
+	chdirTemp(t)
+
+	data := `[{"id":1,"description":"From dat file","done":true}]`
+	if err := os.WriteFile("tasks.dat", []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	// Without --format the extension is unsupported
+	captureOutput(t, func() {
+		if _, _, err := handleLoad(nil, []string{"--file=tasks.dat"}); err == nil {
+			t.Error("Expected error for unsupported extension without --format")
+		}
+	})
+
+	// With --format=json the content is parsed as JSON
+	var loaded []todo.Task
+	captureOutput(t, func() {
+		var err error
+		loaded, _, err = handleLoad(nil, []string{"--file=tasks.dat", "--format=json"})
+		if err != nil {
+			t.Fatalf("handleLoad failed: %v", err)
+		}
+	})
+	if len(loaded) != 1 || loaded[0].Description != "From dat file" || !loaded[0].Done {
+		t.Errorf("Unexpected tasks loaded: %+v", loaded)
+	}
+
+	// Invalid format value is a usage error
+	var err error
+	captureOutput(t, func() {
+		_, _, err = handleLoad(nil, []string{"--file=tasks.dat", "--format=xml"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for invalid format, got %v", err)
+	}
+}
+
+func TestFormatTaskLineGlyphs(t *testing.T) {
+	pending := todo.Task{ID: 1, Description: "Task 1", Done: false}
+	done := todo.Task{ID: 2, Description: "Task 2", Done: true}
+
+	// Default glyphs keep the classic markers
+	opts := renderOptions{Glyphs: defaultGlyphs}
+	if line := formatTaskLine(pending, opts); line != "[ ] [ID:1] Task 1" {
+		t.Errorf("Unexpected default pending line: %q", line)
+	}
+	if line := formatTaskLine(done, opts); line != "[X] [ID:2] Task 2" {
+		t.Errorf("Unexpected default done line: %q", line)
+	}
+
+	// Custom glyph set replaces both markers
+	opts = renderOptions{Glyphs: GlyphSet{Done: "+", Pending: "-"}}
+	if line := formatTaskLine(pending, opts); line != "- [ID:1] Task 1" {
+		t.Errorf("Unexpected custom pending line: %q", line)
+	}
+	if line := formatTaskLine(done, opts); line != "+ [ID:2] Task 2" {
+		t.Errorf("Unexpected custom done line: %q", line)
+	}
+}
+
+func TestListIDRange(t *testing.T) {
+	chdirTemp(t)
+
+	var tasks []todo.Task
+	for i := 1; i <= 5; i++ {
+		tasks = append(tasks, todo.Task{ID: i, Description: fmt.Sprintf("Task %d", i), Done: i == 3})
+	}
+	list := func(args ...string) (string, error) {
+		var err error
+		out := captureOutput(t, func() { err = handleList(tasks, append(args, "--output=ids-csv")) })
+		return strings.TrimSpace(out), err
+	}
+
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"--after=2", "--before=4"}, "2,3,4"},
+		{[]string{"--after=4"}, "4,5"},
+		{[]string{"--before=2"}, "1,2"},
+		{[]string{"--after=3", "--before=3"}, "3"},
+		{[]string{"--after=2", "--before=4", "--filter=pending"}, "2,4"},
+	} {
+		out, err := list(tc.args...)
+		if err != nil {
+			t.Fatalf("handleList %v failed: %v", tc.args, err)
+		}
+		if out != tc.expected {
+			t.Errorf("%v: expected %s, got %s", tc.args, tc.expected, out)
+		}
+	}
+
+	if _, err := list("--after=4", "--before=2"); exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for --before < --after, got %v", err)
+	}
+}
+
+func TestVersionCommand(t *testing.T) {
+	oldVersion, oldCommit, oldDate := Version, Commit, Date
+	defer func() { Version, Commit, Date = oldVersion, oldCommit, oldDate }()
+	Version, Commit, Date = "1.2.3", "abc1234", "2024-05-01"
+
+	var err error
+	out := captureOutput(t, func() { err = handleVersion(nil) })
+	if err != nil {
+		t.Fatalf("handleVersion failed: %v", err)
+	}
+	if !strings.Contains(out, "1.2.3") || !strings.Contains(out, "abc1234") || !strings.Contains(out, "2024-05-01") {
+		t.Errorf("Expected injected build info in output, got: %q", out)
+	}
+
+	out = captureOutput(t, func() { err = handleVersion([]string{"--output=json"}) })
+	if err != nil {
+		t.Fatalf("handleVersion --output=json failed: %v", err)
+	}
+	var info versionInfo
+	if err := json.Unmarshal([]byte(out), &info); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", out, err)
+	}
+	if info != (versionInfo{Version: "1.2.3", Commit: "abc1234", Date: "2024-05-01"}) {
+		t.Errorf("Unexpected version info: %+v", info)
+	}
+
+	if err := handleVersion([]string{"--output=xml"}); exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for unknown output, got %v", err)
+	}
+}
+
+func TestOverdueMarkerByTerminal(t *testing.T) {
+	chdirTemp(t)
+
+	origTerminal := stdoutIsTerminal
+	defer func() { stdoutIsTerminal = origTerminal }()
+
+	due := time.Date(2000, 1, 1, 23, 59, 59, 0, time.Local)
+	tasks := []todo.Task{{ID: 1, Description: "Late", DueDate: &due}}
+	list := func() string {
+		return captureOutput(t, func() {
+			if err := handleList(tasks, []string{"--width=200", "--no-summary", "--no-header"}); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+	}
+
+	stdoutIsTerminal = func() bool { return true }
+	if out := strings.TrimSpace(list()); out != "[ ] [ID:1] Late ⚠️ OVERDUE" {
+		t.Errorf("Expected emoji marker on a terminal, got: %q", out)
+	}
+
+	stdoutIsTerminal = func() bool { return false }
+	if out := strings.TrimSpace(list()); out != "[ ] [ID:1] Late OVERDUE" {
+		t.Errorf("Expected plain marker when piped, got: %q", out)
+	}
+
+	// Custom glyph sets without an overdue marker fall back to the plain one
+	line := formatTaskLine(tasks[0], renderOptions{Glyphs: GlyphSet{Done: "+", Pending: "-"}, Now: time.Now()})
+	if line != "- [ID:1] Late OVERDUE" {
+		t.Errorf("Unexpected line with custom glyphs: %q", line)
+	}
+}
+
+func TestListWithCustomGlyphs(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--done-glyph=DONE", "--pending-glyph=TODO"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "TODO [ID:1] Task 1") || !strings.Contains(out, "DONE [ID:2] Task 2") {
+		t.Errorf("Custom glyphs not rendered, got: %s", out)
+	}
+}
+
+func TestAddReportsNewID(t *testing.T) {
+	var tasks []todo.Task
+	out := captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd([]todo.Task{}, []string{"--desc=Buy milk"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 task, got %d", len(tasks))
+	}
+	if !strings.Contains(out, "Task added with ID 1: Buy milk") {
+		t.Errorf("Expected success message with new ID, got: %s", out)
+	}
+
+	// The next task gets the following ID
+	out = captureOutput(t, func() {
+		if _, err := handleAdd(tasks, []string{"--desc=Learn Go"}); err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "with ID 2") {
+		t.Errorf("Expected success message with ID 2, got: %s", out)
+	}
+}
+
+func TestListOutputJSON(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Done: false},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=json", "--filter=pending"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+
+	// Stdout holds only the JSON array, no log decoration
+	var decoded []todo.Task
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("Output is not a JSON array: %v\n%s", err, out)
+	}
+	if len(decoded) != 2 || decoded[0].ID != 1 || decoded[1].ID != 3 {
+		t.Errorf("Expected pending tasks 1 and 3, got %+v", decoded)
+	}
+
+	// An empty result is an empty JSON array, not null
+	out = captureOutput(t, func() {
+		if err := handleList(nil, []string{"--output=json"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("Expected [] for empty result, got %q", out)
+	}
+}
+
+func TestListOutputYAML(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task: with colon", Done: true},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=yaml"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+
+	var decoded []todo.Task
+	if err := yaml.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("Output is not valid YAML: %v\n%s", err, out)
+	}
+	if len(decoded) != 2 || decoded[1].Description != "Task: with colon" || !decoded[1].Done {
+		t.Errorf("Unexpected decoded tasks: %+v", decoded)
+	}
+
+	// An empty result is an empty YAML sequence
+	out = captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=yaml", "--filter=done", "--after=3"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("Expected [] for empty result, got %q", out)
+	}
+}
+
+func TestListWidthDetection(t *testing.T) {
+	chdirTemp(t)
+
+	origWidth := terminalWidth
+	terminalWidth = func() int { return 20 }
+	defer func() { terminalWidth = origWidth }()
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "A rather long task description", Done: false},
+	}
+
+	// Detected width truncates the line
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "[ ] [ID:1] A rather…\n") {
+		t.Errorf("Expected line truncated to 20 runes, got: %q", out)
+	}
+
+	// Explicit --width overrides the detected width
+	out = captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--width=100"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "A rather long task description") {
+		t.Errorf("Expected full description with --width=100, got: %q", out)
+	}
+}
+
+func TestListFull(t *testing.T) {
+	chdirTemp(t)
+
+	origWidth := terminalWidth
+	terminalWidth = func() int { return 20 }
+	defer func() { terminalWidth = origWidth }()
+
+	long := strings.Repeat("Очень длинное описание задачи, ", 10) + "конец"
+	tasks := []todo.Task{{ID: 1, Description: long, Done: false}}
+
+	list := func(args ...string) string {
+		return captureOutput(t, func() {
+			if err := handleList(tasks, args); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+	}
+
+	// --full wins over both the detected and an explicit width
+	for _, args := range [][]string{{"--full"}, {"--full", "--width=10"}} {
+		out := list(args...)
+		if !strings.Contains(out, "[ ] [ID:1] "+long+"\n") {
+			t.Errorf("Expected complete description with %v, got: %q", args, out)
+		}
+	}
+
+	out := list("--full", "--compact", "--width=5")
+	if strings.TrimSpace(out) != "Pending: 1  Done: -" {
+		t.Errorf("Expected untruncated compact line, got: %q", out)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	cases := []struct {
+		in       string
+		width    int
+		expected string
+	}{
+		{"short", 10, "short"},
+		{"exactly10!", 10, "exactly10!"},
+		{"longer than ten", 10, "longer th…"},
+		{"Задача на русском", 8, "Задача …"},
+		{"anything", 0, "anything"},
+	}
+	for _, c := range cases {
+		if got := truncate(c.in, c.width); got != c.expected {
+			t.Errorf("truncate(%q, %d) = %q, expected %q", c.in, c.width, got, c.expected)
+		}
+	}
+}
+
+func TestCompleteAndDeleteLast(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: false},
+	}
+
+	out := captureOutput(t, func() {
+		var err error
+		tasks, err = handleComplete(tasks, []string{"--id=last"})
+		if err != nil {
+			t.Fatalf("handleComplete failed: %v", err)
+		}
+	})
+	if !tasks[1].Done || tasks[0].Done {
+		t.Errorf("Expected only task 2 to be completed, got %+v", tasks)
+	}
+	if !strings.Contains(out, "Task 2 marked as completed") {
+		t.Errorf("Unexpected output: %s", out)
+	}
+
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleDelete(tasks, []string{"--id=last"})
+		if err != nil {
+			t.Fatalf("handleDelete failed: %v", err)
+		}
+	})
+	if len(tasks) != 1 || tasks[0].ID != 1 {
+		t.Errorf("Expected only task 1 to remain, got %+v", tasks)
+	}
+
+	// "last" on an empty list is a regular error, not a usage error
+	var err error
+	captureOutput(t, func() {
+		_, err = handleComplete([]todo.Task{}, []string{"--id=last"})
+	})
+	if err == nil || exitCode(err) != exitError {
+		t.Errorf("Expected non-usage error for 'last' on empty list, got %v", err)
+	}
+}
+
+func TestSnapshotAndRestore(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+	}
+
+	captureOutput(t, func() {
+		if err := handleSnapshot(tasks, []string{"--name=sprint1"}); err != nil {
+			t.Fatalf("handleSnapshot failed: %v", err)
+		}
+	})
+
+	// Without --force nothing is restored
+	var restored []todo.Task
+	out := captureOutput(t, func() {
+		var err error
+		restored, err = handleRestoreSnapshot([]string{"--name=sprint1"})
+		if err != nil {
+			t.Fatalf("handleRestoreSnapshot failed: %v", err)
+		}
+	})
+	if restored != nil {
+		t.Errorf("Restore without --force should not return tasks, got %+v", restored)
+	}
+	if !strings.Contains(out, "--force") {
+		t.Errorf("Expected confirmation hint, got: %s", out)
+	}
+
+	captureOutput(t, func() {
+		var err error
+		restored, err = handleRestoreSnapshot([]string{"--name=sprint1", "--force"})
+		if err != nil {
+			t.Fatalf("handleRestoreSnapshot failed: %v", err)
+		}
+	})
+	if len(restored) != 1 || restored[0].Description != "Task 1" {
+		t.Errorf("Unexpected restored tasks: %+v", restored)
+	}
+
+	out = captureOutput(t, func() {
+		if err := handleSnapshots([]string{}); err != nil {
+			t.Fatalf("handleSnapshots failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "sprint1") {
+		t.Errorf("Expected snapshot in list, got: %s", out)
+	}
+}
+
+func TestAddWithRef(t *testing.T) {
+	var tasks []todo.Task
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd([]todo.Task{}, []string{"--desc=Fix login", "--ref=https://tracker.example.com/issues/42"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if len(tasks) != 1 || tasks[0].Ref != "https://tracker.example.com/issues/42" {
+		t.Fatalf("Expected task with ref, got %+v", tasks)
+	}
+
+	line := formatTaskLine(tasks[0], renderOptions{Glyphs: defaultGlyphs})
+	expected := "[ ] [ID:1] Fix login (ref: https://tracker.example.com/issues/42)"
+	if line != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, line)
+	}
+
+	// Malformed URLs are usage errors
+	var err error
+	captureOutput(t, func() {
+		_, err = handleAdd([]todo.Task{}, []string{"--desc=Broken", "--ref=https://"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for malformed ref, got %v", err)
+	}
+}
+
+func TestAddWithPriority(t *testing.T) {
+	var tasks []todo.Task
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd([]todo.Task{}, []string{"--desc=Default"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+		tasks, err = handleAdd(tasks, []string{"--desc=Urgent", "--priority=high"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if tasks[0].Priority != todo.PriorityMedium || tasks[1].Priority != todo.PriorityHigh {
+		t.Fatalf("Expected medium and high priority, got %+v", tasks)
+	}
+
+	line := formatTaskLine(tasks[1], renderOptions{Glyphs: defaultGlyphs})
+	if expected := "[ ] [ID:2] Urgent (priority: high)"; line != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, line)
+	}
+
+	var err error
+	captureOutput(t, func() {
+		_, err = handleAdd(tasks, []string{"--desc=Broken", "--priority=urgent"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for unknown priority, got %v", err)
+	}
+}
+
+func TestAddWithDueDate(t *testing.T) {
+	chdirTemp(t)
+
+	origTerminal := stdoutIsTerminal
+	defer func() { stdoutIsTerminal = origTerminal }()
+	stdoutIsTerminal = func() bool { return true }
+
+	var tasks []todo.Task
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd([]todo.Task{}, []string{"--desc=Late", "--due=2000-01-01"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+		tasks, err = handleAdd(tasks, []string{"--desc=Later", "--due=2999-01-01T09:30"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+		tasks, err = handleAdd(tasks, []string{"--desc=Whenever"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if tasks[0].DueDate == nil || tasks[1].DueDate == nil || tasks[2].DueDate != nil {
+		t.Fatalf("Expected due dates on the first two tasks only, got %+v", tasks)
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--width=200"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "[ID:1] Late ⚠️ OVERDUE") {
+		t.Errorf("Expected overdue marker on task 1, got:\n%s", out)
+	}
+	if strings.Count(out, "OVERDUE") != 1 {
+		t.Errorf("Expected only task 1 to be overdue, got:\n%s", out)
+	}
+
+	var err error
+	captureOutput(t, func() {
+		_, err = handleAdd(tasks, []string{"--desc=Broken", "--due=next week"})
+	})
+	if exitCode(err) != exitUsage || !errors.Is(err, todo.ErrInvalidDueDate) {
+		t.Errorf("Expected usage error for invalid due date, got %v", err)
+	}
+}
+
+func TestAddMultipleDescriptions(t *testing.T) {
+	var tasks []todo.Task
+	out := captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd([]todo.Task{}, []string{"--desc=First", "--desc=Second", "--descs=Third, Fourth\nFifth", "--priority=high"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if len(tasks) != 5 || tasks[2].Description != "Third" || tasks[4].Description != "Fifth" {
+		t.Fatalf("Expected 5 tasks, got %+v", tasks)
+	}
+	for _, task := range tasks {
+		if task.Priority != todo.PriorityHigh {
+			t.Errorf("Expected every task to get high priority, got %+v", task)
+		}
+	}
+	if !strings.Contains(out, "Added 5 of 5 tasks") {
+		t.Errorf("Expected creation summary, got:\n%s", out)
+	}
+
+	// Invalid descriptions are reported, valid ones are still added
+	tooLong := strings.Repeat("a", todo.MaxDescriptionLength+1)
+	out = captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd(tasks, []string{"--desc=Sixth", "--desc=" + tooLong, "--desc=Seventh"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if len(tasks) != 7 || tasks[5].ID != 6 || tasks[6].Description != "Seventh" {
+		t.Errorf("Expected tasks 6 and 7 to be added, got %+v", tasks[5:])
+	}
+	if !strings.Contains(out, "Added 2 of 3 tasks") {
+		t.Errorf("Expected partial creation summary, got:\n%s", out)
+	}
+
+	// No valid description at all is an error
+	var err error
+	captureOutput(t, func() {
+		_, err = handleAdd(tasks, []string{"--desc=" + tooLong, "--descs=, ,"})
+	})
+	if !errors.Is(err, todo.ErrDescriptionTooLong) {
+		t.Errorf("Expected ErrDescriptionTooLong, got %v", err)
+	}
+}
+
+func TestAddMultipleWithMetadata(t *testing.T) {
+	existing := []todo.Task{{ID: 1, Description: "Existing", Priority: todo.PriorityLow}}
+
+	var tasks []todo.Task
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd(existing, []string{"--descs=Second,Third,Fourth", "--priority=high", "--ref=JIRA-7", "--due=2024-06-01", "--tag=Work"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if len(tasks) != 4 || tasks[0].Priority != todo.PriorityLow || tasks[0].Ref != "" || tasks[0].Tags != nil {
+		t.Fatalf("Expected the existing task unchanged and 3 new tasks, got %+v", tasks)
+	}
+	for _, task := range tasks[1:] {
+		if task.Priority != todo.PriorityHigh || task.Ref != "JIRA-7" || task.DueDate == nil || strings.Join(task.Tags, ",") != "work" {
+			t.Errorf("Expected every new task to get all metadata, got %+v", task)
+		}
+	}
+	if tasks[1].DueDate == tasks[2].DueDate {
+		t.Error("Expected each task to get its own due date copy")
+	}
+	if existing[0].Priority != todo.PriorityLow || len(existing) != 1 {
+		t.Errorf("Expected the input slice to stay unchanged, got %+v", existing)
+	}
+}
+
+func TestAddAndListByTag(t *testing.T) {
+	chdirTemp(t)
+
+	var tasks []todo.Task
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd([]todo.Task{}, []string{"--desc=Report", "--tag=Work", "--tag=urgent", "--tag=WORK"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+		tasks, err = handleAdd(tasks, []string{"--desc=Groceries", "--tag=home"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if strings.Join(tasks[0].Tags, ",") != "work,urgent" {
+		t.Errorf("Expected normalized tags work,urgent, got %v", tasks[0].Tags)
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--tag=work", "--width=200", "--no-summary"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "[ID:1] Report (tags: work, urgent)") || strings.Contains(out, "Groceries") {
+		t.Errorf("Expected only the work task, got:\n%s", out)
+	}
+}
+
+func TestTemplateSaveAndAdd(t *testing.T) {
+	chdirTemp(t)
+
+	var tasks []todo.Task
+	out := captureOutput(t, func() {
+		if err := handleTemplate([]string{"save", "--name=bug", "--tags=bug,Urgent", "--priority=high"}); err != nil {
+			t.Fatalf("handleTemplate save failed: %v", err)
+		}
+		var err error
+		tasks, err = handleAdd([]todo.Task{}, []string{"--desc=Crash on start", "--template=bug", "--tag=ui"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+		tasks, err = handleAdd(tasks, []string{"--desc=Typo", "--template=bug", "--priority=low"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+		if err := handleTemplate([]string{"list"}); err != nil {
+			t.Fatalf("handleTemplate list failed: %v", err)
+		}
+	})
+
+	if tasks[0].Priority != todo.PriorityHigh || strings.Join(tasks[0].Tags, ",") != "bug,urgent,ui" {
+		t.Errorf("Expected template fields plus --tag, got %+v", tasks[0])
+	}
+	if tasks[1].Priority != todo.PriorityLow {
+		t.Errorf("Expected explicit --priority to override the template, got %s", tasks[1].Priority)
+	}
+	if !strings.Contains(out, "bug (priority: high, tags: bug, urgent)") {
+		t.Errorf("Expected template in list output, got:\n%s", out)
+	}
+
+	var err error
+	captureOutput(t, func() {
+		_, err = handleAdd(tasks, []string{"--desc=Other", "--template=missing"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for missing template, got %v", err)
+	}
+
+	// A templates file that cannot be read is not a usage error
+	if err := os.WriteFile(storage.TemplatesPath(tasksFile), []byte("not json"), 0644); err != nil {
+		t.Fatalf("Cannot write templates file: %v", err)
+	}
+	captureOutput(t, func() {
+		_, err = handleAdd(tasks, []string{"--desc=Other", "--template=bug"})
+	})
+	if err == nil || exitCode(err) != exitError {
+		t.Errorf("Expected a general error for a corrupt templates file, got %v", err)
+	}
+	if err := os.WriteFile(storage.TemplatesPath(tasksFile), []byte("[]"), 0644); err != nil {
+		t.Fatalf("Cannot write templates file: %v", err)
+	}
+	captureOutput(t, func() {
+		err = handleTemplate([]string{"save", "--name=bad", "--priority=urgent"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for invalid priority, got %v", err)
+	}
+}
+
+func TestFormatCompact(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Done: true},
+		{ID: 4, Description: "Task 4", Done: false},
+		{ID: 7, Description: "Task 7", Done: false},
+	}
+
+	expected := "Pending: 1,4,7  Done: 2,3"
+	if got := formatCompact(tasks); got != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, got)
+	}
+
+	if got := formatCompact(tasks[1:3]); got != "Pending: -  Done: 2,3" {
+		t.Errorf("Expected empty pending group, got '%s'", got)
+	}
+	if got := formatCompact(nil); got != "Pending: -  Done: -" {
+		t.Errorf("Expected empty summary, got '%s'", got)
+	}
+
+	chdirTemp(t)
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--compact", "--width=200"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != expected {
+		t.Errorf("Expected compact list '%s', got '%s'", expected, out)
+	}
+}
+
+func TestListJSONCompactByTerminal(t *testing.T) {
+	chdirTemp(t)
+
+	origTerminal := stdoutIsTerminal
+	defer func() { stdoutIsTerminal = origTerminal }()
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+	}
+	list := func(args ...string) string {
+		return captureOutput(t, func() {
+			if err := handleList(tasks, args); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+	}
+	const compactJSON = `[{"id":1,"description":"Task 1"},{"id":2,"description":"Task 2","done":true}]`
+
+	// A terminal gets indented JSON unless --compact is given
+	stdoutIsTerminal = func() bool { return true }
+	if out := list("--output=json"); !strings.HasPrefix(out, "[\n  {\n    \"id\": 1,") {
+		t.Errorf("Expected indented JSON on a terminal, got:\n%s", out)
+	}
+	if out := strings.TrimSpace(list("--output=json", "--compact")); out != compactJSON {
+		t.Errorf("Expected compact JSON with --compact, got:\n%s", out)
+	}
+
+	// A pipe gets compact JSON unless --compact=false is given
+	stdoutIsTerminal = func() bool { return false }
+	if out := strings.TrimSpace(list("--output=json")); out != compactJSON {
+		t.Errorf("Expected compact JSON when piped, got:\n%s", out)
+	}
+	if out := list("--output=json", "--compact=false"); !strings.Contains(out, "\n  {") {
+		t.Errorf("Expected indented JSON with --compact=false, got:\n%s", out)
+	}
+
+	// Both forms decode to the same tasks
+	var pretty, compact []todo.Task
+	if err := json.Unmarshal([]byte(list("--output=json", "--compact=false")), &pretty); err != nil {
+		t.Fatalf("Invalid indented JSON: %v", err)
+	}
+	if err := json.Unmarshal([]byte(list("--output=json")), &compact); err != nil {
+		t.Fatalf("Invalid compact JSON: %v", err)
+	}
+	if len(pretty) != 2 || len(compact) != 2 || pretty[1].Description != compact[1].Description {
+		t.Errorf("Expected the same tasks, got %+v and %+v", pretty, compact)
+	}
+}
+
+func TestResolveCommandAliases(t *testing.T) {
+	tests := map[string]string{
+		"ls":       "list",
+		"rm":       "delete",
+		"mk":       "add",
+		"new":      "add",
+		"done":     "complete",
+		"list":     "list",
+		"unknown":  "unknown",
+		"snapshot": "snapshot",
+	}
+	for name, expected := range tests {
+		if got := resolveCommand(name); got != expected {
+			t.Errorf("resolveCommand(%q): expected '%s', got '%s'", name, expected, got)
+		}
+	}
+
+	// Help lists every alias next to its canonical command
+	out := captureOutput(t, printUsage)
+	for alias, canonical := range commandAliases {
+		if !strings.Contains(out, alias) || !strings.Contains(out, canonical) {
+			t.Errorf("Expected help to mention alias %s -> %s", alias, canonical)
+		}
+	}
+}
+
+func TestListVerboseJSON(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Done: false},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=json", "--filter=pending", "--verbose"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+
+	var decoded struct {
+		Filter string      `json:"filter"`
+		Count  int         `json:"count"`
+		Tasks  []todo.Task `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("Output is not a JSON object: %v\n%s", err, out)
+	}
+	if decoded.Filter != "pending" || decoded.Count != 2 || len(decoded.Tasks) != 2 {
+		t.Errorf("Unexpected envelope: %+v", decoded)
+	}
+
+	// Without --verbose the output stays a bare array
+	out = captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=json"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.HasPrefix(strings.TrimSpace(out), "[") {
+		t.Errorf("Expected bare JSON array, got: %s", out)
+	}
+
+	// --envelope is an alias of --verbose
+	out = captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=json", "--envelope"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if !strings.HasPrefix(strings.TrimSpace(out), "{") {
+		t.Errorf("Expected --envelope to wrap the output, got: %s", out)
+	}
+
+	// --verbose makes no sense for text output
+	var err error
+	captureOutput(t, func() {
+		err = handleList(tasks, []string{"--verbose"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for --verbose with text output, got %v", err)
+	}
+}
+
+func TestListVerboseCommandLine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	mustRunHelper(t, dir, nil, "add", "--desc=Task 1")
+
+	// A --verbose after the command name belongs to list, not to the global flags
+	out := mustRunHelper(t, dir, nil, "list", "--output=json", "--verbose")
+	var decoded struct {
+		Filter string      `json:"filter"`
+		Count  int         `json:"count"`
+		Tasks  []todo.Task `json:"tasks"`
+	}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("Output is not a JSON object: %v\n%s", err, out)
+	}
+	if decoded.Filter != "all" || decoded.Count != 1 || len(decoded.Tasks) != 1 {
+		t.Errorf("Unexpected envelope: %+v", decoded)
+	}
+
+	// Before the command name it is still the global flag
+	out = mustRunHelper(t, dir, nil, "--verbose", "list", "--output=json")
+	if !strings.HasPrefix(strings.TrimSpace(out), "[") {
+		t.Errorf("Expected bare JSON array with the global --verbose, got: %s", out)
+	}
+}
+
+func TestListOutputIDsCSV(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 4, Description: "Task 4", Done: false},
+		{ID: 7, Description: "Task 7", Done: false},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=ids-csv", "--filter=pending"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if out != "1,4,7\n" {
+		t.Errorf("Expected '1,4,7\\n', got %q", out)
+	}
+
+	if got := formatIDsCSV(nil); got != "" {
+		t.Errorf("Expected empty line for no tasks, got %q", got)
+	}
+}
+
+func TestDoctorChecks(t *testing.T) {
+	failedChecks := func(results []doctorResult) []string {
+		var names []string
+		for _, result := range results {
+			if !result.OK {
+				names = append(names, result.Name)
+			}
+		}
+		return names
+	}
+
+	// Healthy environment: missing data file is fine
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "tasks.json")
+	logDir := filepath.Join(dir, "logs")
+	if failed := failedChecks(runDoctorChecks(dataPath, logDir)); len(failed) != 0 {
+		t.Errorf("Expected all checks to pass, failed: %v", failed)
+	}
+	if _, err := os.Stat(logDir); !os.IsNotExist(err) {
+		t.Errorf("Expected doctor not to create the log directory, got %v", err)
+	}
+
+	// Corrupt data file
+	if err := os.WriteFile(dataPath, []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+	if failed := failedChecks(runDoctorChecks(dataPath, logDir)); len(failed) != 1 || failed[0] != "data file" {
+		t.Errorf("Expected only data file check to fail for corrupt JSON, failed: %v", failed)
+	}
+
+	// Valid JSON with invalid tasks
+	if err := os.WriteFile(dataPath, []byte(`[{"id":1,"description":"a"},{"id":1,"description":"b"}]`), 0644); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+	results := runDoctorChecks(dataPath, logDir)
+	if results[0].OK || !strings.Contains(results[0].Detail, "duplicate ID 1") {
+		t.Errorf("Expected duplicate ID to be reported, got %+v", results[0])
+	}
+	os.Remove(dataPath)
+
+	// Data file path is a directory
+	if err := os.Mkdir(dataPath, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if results := runDoctorChecks(dataPath, logDir); results[0].OK {
+		t.Errorf("Expected data file check to fail for a directory, got %+v", results[0])
+	}
+	os.Remove(dataPath)
+
+	// Stale lock left behind by a crashed process
+	lockPath := dataPath + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	if failed := failedChecks(runDoctorChecks(dataPath, logDir)); len(failed) != 1 || failed[0] != "lock file" {
+		t.Errorf("Expected only lock check to fail, failed: %v", failed)
+	}
+
+	// An old lock whose owner is still running is not stale
+	if err := os.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	if failed := failedChecks(runDoctorChecks(dataPath, logDir)); len(failed) != 0 {
+		t.Errorf("Expected a lock of a running process to pass, failed: %v", failed)
+	}
+	os.Remove(lockPath)
+
+	// Unwritable log directory: a regular file is in the way
+	blocked := filepath.Join(dir, "blocked")
+	if err := os.WriteFile(blocked, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	results = runDoctorChecks(dataPath, filepath.Join(blocked, "logs"))
+	if failed := failedChecks(results); len(failed) != 1 || failed[0] != "log directory writable" {
+		t.Errorf("Expected only log directory check to fail, failed: %v", failed)
+	}
+	if results[4].Critical {
+		t.Error("Log directory check should not be critical")
+	}
+
+	// Unwritable data directory
+	results = runDoctorChecks(filepath.Join(blocked, "tasks.json"), logDir)
+	if results[2].OK || !results[2].Critical {
+		t.Errorf("Expected critical data directory failure, got %+v", results[2])
+	}
+
+	// Read-only data file
+	if err := os.WriteFile(dataPath, []byte("[]"), 0444); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+	results = runDoctorChecks(dataPath, logDir)
+	if failed := failedChecks(results); len(failed) != 1 || failed[0] != "data file writable" || !results[1].Critical {
+		t.Errorf("Expected only the critical data file writable check to fail, failed: %v", failed)
+	}
+	os.Chmod(dataPath, 0644)
+	if failed := failedChecks(runDoctorChecks(dataPath, logDir)); len(failed) != 0 {
+		t.Errorf("Expected a writable data file to pass, failed: %v", failed)
+	}
+}
+
+func TestUncompleteCommand(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: true},
+		{ID: 2, Description: "Task 2", Done: false},
+	}
+
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleUncomplete(tasks, []string{"--id=1"})
+		if err != nil {
+			t.Fatalf("handleUncomplete failed: %v", err)
+		}
+		tasks, err = handleUncomplete(tasks, []string{"--id=last"})
+		if err != nil {
+			t.Fatalf("handleUncomplete failed for pending task: %v", err)
+		}
+	})
+	if tasks[0].Done || tasks[1].Done {
+		t.Errorf("Expected both tasks to be pending, got %+v", tasks)
+	}
+
+	var err error
+	captureOutput(t, func() {
+		_, err = handleUncomplete(tasks, []string{"--id=99"})
+	})
+	if !errors.Is(err, todo.ErrTaskNotFound) || exitCode(err) != exitError {
+		t.Errorf("Expected task not found error, got %v", err)
+	}
+}
+
+func TestNextCommand(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Low", Priority: todo.PriorityLow},
+		{ID: 2, Description: "High", Priority: todo.PriorityHigh},
+		{ID: 3, Description: "Done", Priority: todo.PriorityHigh, Done: true},
+	}
+
+	next := func(args ...string) (string, error) {
+		var err error
+		out := captureOutput(t, func() { err = handleNext(tasks, args) })
+		return out, err
+	}
+
+	out, err := next()
+	if err != nil {
+		t.Fatalf("handleNext failed: %v", err)
+	}
+	if strings.TrimSpace(out) != "[ ] [ID:2] High (priority: high)" {
+		t.Errorf("Expected only task 2, got:\n%s", out)
+	}
+
+	out, err = next("--count=5")
+	if err != nil {
+		t.Fatalf("handleNext failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], "[ID:1]") {
+		t.Errorf("Expected pending tasks 2 and 1, got:\n%s", out)
+	}
+
+	if _, err := next("--count=0"); exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for --count=0, got %v", err)
+	}
+}
+
+func TestSearchCommand(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Buy milk"},
+		{ID: 2, Description: "Call mom", Done: true},
+	}
+
+	search := func(args ...string) (string, error) {
+		var err error
+		out := captureOutput(t, func() { err = handleSearch(tasks, args) })
+		return out, err
+	}
+
+	out, err := search("--query=MILK")
+	if err != nil {
+		t.Fatalf("handleSearch failed: %v", err)
+	}
+	if strings.TrimSpace(out) != "[ ] [ID:1] Buy milk" {
+		t.Errorf("Expected only task 1, got:\n%s", out)
+	}
+
+	out, err = search("--query=^call", "--regex")
+	if err != nil {
+		t.Fatalf("handleSearch failed: %v", err)
+	}
+	if !strings.Contains(out, "No tasks match") {
+		t.Errorf("Expected case-sensitive regex to match nothing, got:\n%s", out)
+	}
+
+	out, err = search("--query=(?i)^call", "--regex")
+	if err != nil {
+		t.Fatalf("handleSearch failed: %v", err)
+	}
+	if !strings.Contains(out, "[ID:2] Call mom") {
+		t.Errorf("Expected task 2, got:\n%s", out)
+	}
+
+	// --filter excludes done or pending matches, also with --regex
+	tasks = append(tasks, todo.Task{ID: 3, Description: "Buy milk again", Done: true})
+	out, err = search("--query=milk", "--filter=pending")
+	if err != nil || strings.TrimSpace(out) != "[ ] [ID:1] Buy milk" {
+		t.Errorf("Expected only pending task 1, got %v:\n%s", err, out)
+	}
+	out, err = search("--query=milk", "--filter=done", "--regex")
+	if err != nil || strings.Contains(out, "[ID:1]") || !strings.Contains(out, "[ID:3] Buy milk again") {
+		t.Errorf("Expected only done task 3, got %v:\n%s", err, out)
+	}
+	if _, err := search("--query=milk", "--filter=unknown"); exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for an invalid filter, got %v", err)
+	}
+
+	if _, err := search("--query=(mom", "--regex"); exitCode(err) != exitUsage || !errors.Is(err, todo.ErrInvalidPattern) {
+		t.Errorf("Expected usage error for invalid pattern, got %v", err)
+	}
+	if _, err := search(); exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error without --query, got %v", err)
+	}
+}
+
+func TestClearCommand(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Done", Done: true},
+		{ID: 2, Description: "Pending"},
+	}
+
+	var result []todo.Task
+	var err error
+	out := captureOutput(t, func() { result, err = handleClear(tasks, nil) })
+	if err != nil || result != nil {
+		t.Errorf("Expected no change without --force, got %+v, %v", result, err)
+	}
+	if !strings.Contains(out, "Re-run with --force") {
+		t.Errorf("Expected confirmation warning, got:\n%s", out)
+	}
+
+	out = captureOutput(t, func() { result, err = handleClear(tasks, []string{"--force"}) })
+	if err != nil || len(result) != 1 || result[0].ID != 2 {
+		t.Errorf("Expected only task 2 to remain, got %+v, %v", result, err)
+	}
+	if !strings.Contains(out, "Cleared 1 completed tasks") {
+		t.Errorf("Expected success message, got:\n%s", out)
+	}
+
+	out = captureOutput(t, func() { result, err = handleClear(result, []string{"--force"}) })
+	if err != nil || result != nil || !strings.Contains(out, "No completed tasks to clear") {
+		t.Errorf("Expected nothing to clear, got %+v, %v:\n%s", result, err, out)
+	}
+}
+
+func TestDeleteCompletedBefore(t *testing.T) {
+	completed := func(day int, hour int) *time.Time {
+		at := time.Date(2024, 6, day, hour, 0, 0, 0, time.Local)
+		return &at
+	}
+	tasks := []todo.Task{
+		{ID: 1, Description: "Done on May 31", Done: true, CompletedAt: completed(0, 23)},
+		{ID: 2, Description: "Done on June 1", Done: true, CompletedAt: completed(1, 0)},
+		{ID: 3, Description: "Pending"},
+	}
+	deleteTasks := func(tasks []todo.Task, args ...string) ([]todo.Task, string, error) {
+		var result []todo.Task
+		var err error
+		out := captureOutput(t, func() { result, err = handleDelete(tasks, args) })
+		return result, out, err
+	}
+
+	// Only the task completed before the start of June 1 is deleted
+	result, out, err := deleteTasks(tasks, "--completed-before=2024-06-01")
+	if err != nil || len(result) != 2 || result[0].ID != 2 || result[1].ID != 3 {
+		t.Errorf("Expected tasks 2 and 3 to remain, got %+v, %v", result, err)
+	}
+	if !strings.Contains(out, "Deleted 1 tasks completed before 2024-06-01, 2 tasks remain") {
+		t.Errorf("Expected deleted count, got:\n%s", out)
+	}
+
+	// A time moves the bound within the day
+	if result, _, err = deleteTasks(tasks, "--completed-before=2024-06-01T01:00"); err != nil || len(result) != 1 || result[0].ID != 3 {
+		t.Errorf("Expected only task 3 to remain, got %+v, %v", result, err)
+	}
+
+	if result, out, err = deleteTasks(tasks, "--completed-before=2024-05-01"); err != nil || result != nil || !strings.Contains(out, "No tasks completed before 2024-05-01") {
+		t.Errorf("Expected nothing to delete, got %+v, %v:\n%s", result, err, out)
+	}
+
+	// Batches larger than the limit require --yes
+	var many []todo.Task
+	for id := 1; id <= bulkDeleteConfirmLimit+1; id++ {
+		many = append(many, todo.Task{ID: id, Description: fmt.Sprintf("Task %d", id), Done: true, CompletedAt: completed(0, 12)})
+	}
+	result, out, err = deleteTasks(many, "--completed-before=2024-06-01")
+	if err != nil || result != nil || !strings.Contains(out, "Re-run with --yes") {
+		t.Errorf("Expected confirmation warning without --yes, got %+v, %v:\n%s", result, err, out)
+	}
+	if result, _, err = deleteTasks(many, "--completed-before=2024-06-01", "--yes"); err != nil || result == nil || len(result) != 0 {
+		t.Errorf("Expected all tasks deleted with --yes, got %+v, %v", result, err)
+	}
+
+	for _, args := range [][]string{
+		{"--completed-before=June"},
+		{"--completed-before=2024-06-01", "--id=1"},
+	} {
+		if _, _, err := deleteTasks(tasks, args...); exitCode(err) != exitUsage {
+			t.Errorf("Expected usage error for %v, got %v", args, err)
+		}
+	}
+}
+
+func TestCountCommand(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Tags: []string{"work"}},
+	}
+	count := func(tasks []todo.Task, args ...string) (string, error) {
+		var err error
+		out := captureOutput(t, func() { err = handleCount(tasks, args) })
+		return out, err
+	}
+
+	for filter, expected := range map[string]string{"all": "3\n", "pending": "2\n", "done": "1\n", "untagged": "2\n"} {
+		out, err := count(tasks, "--filter="+filter)
+		if err != nil {
+			t.Fatalf("handleCount --filter=%s failed: %v", filter, err)
+		}
+		if out != expected {
+			t.Errorf("--filter=%s: expected %q, got %q", filter, expected, out)
+		}
+	}
+
+	if out, err := count(nil); err != nil || out != "0\n" {
+		t.Errorf("Expected 0 for an empty list, got %q, %v", out, err)
+	}
+	if _, err := count(tasks, "--filter=unknown"); exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for unknown filter, got %v", err)
+	}
+}
+
+func TestMoveCommand(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3"},
+	}
+
+	var moved []todo.Task
+	var err error
+	captureOutput(t, func() { moved, err = handleMove(tasks, []string{"--id=3", "--to=1"}) })
+	if err != nil {
+		t.Fatalf("handleMove failed: %v", err)
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(moved, []string{"--output=ids-csv"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "3,1,2" {
+		t.Errorf("Expected stored order 3,1,2, got %q", out)
+	}
+
+	// Out-of-range positions are usage errors, not clamped
+	for _, args := range [][]string{{"--id=1", "--to=4"}, {"--id=1"}, {"--id=1", "--to=-1"}} {
+		captureOutput(t, func() { moved, err = handleMove(tasks, args) })
+		if exitCode(err) != exitUsage || moved != nil {
+			t.Errorf("handleMove %v: expected usage error, got %v", args, err)
+		}
+	}
+}
+
+func TestMoveAppendOnly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	mustRunHelper(t, dir, nil, "--append-only", "add", "--desc=Task 1", "--desc=Task 2", "--desc=Task 3")
+	mustRunHelper(t, dir, nil, "--append-only", "move", "--id=3", "--to=1")
+
+	// The new order is replayed from the event log
+	if out := mustRunHelper(t, dir, nil, "--append-only", "list", "--output=ids-csv"); strings.TrimSpace(out) != "3,1,2" {
+		t.Errorf("Expected order 3,1,2 after move with --append-only, got %q", out)
+	}
+}
+
+func TestCompleteRemainingCount(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3"},
+		{ID: 4, Description: "Task 4"},
+	}
+
+	out := captureOutput(t, func() {
+		if _, err := handleComplete(tasks, []string{"--id=3"}); err != nil {
+			t.Fatalf("handleComplete failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Task 3 marked as completed, 2 tasks remaining") {
+		t.Errorf("Expected remaining pending count, got: %s", out)
+	}
+}
+
+func TestCompleteReopenIfDone(t *testing.T) {
+	complete := func(args ...string) []todo.Task {
+		tasks := []todo.Task{
+			{ID: 1, Description: "Pending"},
+			{ID: 2, Description: "Done", Done: true},
+		}
+		var result []todo.Task
+		captureOutput(t, func() {
+			var err error
+			result, err = handleComplete(tasks, args)
+			if err != nil {
+				t.Fatalf("handleComplete %v failed: %v", args, err)
+			}
+		})
+		return result
+	}
+
+	// Without the flag completing a done task is a no-op
+	if result := complete("--id=2"); !result[1].Done {
+		t.Errorf("Expected task 2 to stay done, got %+v", result[1])
+	}
+
+	// With the flag a done task is reopened, a pending one is completed
+	if result := complete("--id=2", "--reopen-if-done"); result[1].Done {
+		t.Errorf("Expected task 2 to be reopened, got %+v", result[1])
+	}
+	if result := complete("--id=1", "--reopen-if-done"); !result[0].Done {
+		t.Errorf("Expected task 1 to be completed, got %+v", result[0])
+	}
+}
+
+func TestCompleteByMatch(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Buy milk", Done: false},
+		{ID: 2, Description: "Call mom", Done: false},
+	}
+
+	var result []todo.Task
+	out := captureOutput(t, func() {
+		var err error
+		result, err = handleComplete(tasks, []string{"--match=buy mlk"})
+		if err != nil {
+			t.Fatalf("handleComplete failed: %v", err)
+		}
+	})
+	if !result[0].Done || result[1].Done {
+		t.Errorf("Expected only task 1 to be completed, got %+v", result)
+	}
+	if !strings.Contains(out, "Task 1 marked as completed") {
+		t.Errorf("Expected success message for task 1, got: %s", out)
+	}
+
+	var err error
+	captureOutput(t, func() {
+		_, err = handleComplete(tasks, []string{"--id=1", "--match=milk"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for --id with --match, got %v", err)
+	}
+
+	captureOutput(t, func() {
+		_, err = handleComplete(tasks, []string{"--match=nothing like it"})
+	})
+	if !errors.Is(err, todo.ErrNoMatch) || exitCode(err) != exitError {
+		t.Errorf("Expected ErrNoMatch runtime error, got %v", err)
+	}
+}
+
+func TestBulkTag(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Tags: []string{"home"}},
+	}
+
+	var result []todo.Task
+	out := captureOutput(t, func() {
+		var err error
+		result, err = handleTagAdd(tasks, []string{"--filter=pending", "--tag=Urgent"})
+		if err != nil {
+			t.Fatalf("handleTagAdd failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Tagged 2 of 2 matching tasks with 'urgent'") {
+		t.Errorf("Expected count of tagged tasks, got:\n%s", out)
+	}
+	if !result[0].HasTag("urgent") || !result[2].HasTag("urgent") || !result[2].HasTag("home") || result[1].Tags != nil {
+		t.Errorf("Expected only pending tasks tagged, got %+v", result)
+	}
+
+	// Nothing changes, so nothing is saved
+	captureOutput(t, func() {
+		again, err := handleTagAdd(result, []string{"--filter=pending", "--tag=urgent"})
+		if err != nil || again != nil {
+			t.Errorf("Expected no change when all tasks already carry the tag, got %+v, %v", again, err)
+		}
+	})
+
+	out = captureOutput(t, func() {
+		var err error
+		result, err = handleTagRemove(result, []string{"--tag=urgent"})
+		if err != nil {
+			t.Fatalf("handleTagRemove failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Removed tag 'urgent' from 2 of 3 matching tasks") {
+		t.Errorf("Expected count of untagged tasks, got:\n%s", out)
+	}
+	if result[0].Tags != nil || strings.Join(result[2].Tags, ",") != "home" {
+		t.Errorf("Expected urgent removed and home kept, got %+v", result)
+	}
+
+	for _, args := range [][]string{{"--filter=pending"}, {"--tag=a;b"}, {"--tag=x", "--filter=unknown"}} {
+		var err error
+		captureOutput(t, func() {
+			_, err = handleTagAdd(tasks, args)
+		})
+		if exitCode(err) != exitUsage {
+			t.Errorf("Expected usage error for %v, got %v", args, err)
+		}
+	}
+}
+
+func TestListStale(t *testing.T) {
+	chdirTemp(t)
+
+	day := 24 * time.Hour
+	tasks := []todo.Task{
+		{ID: 1, Description: "Old", CreatedAt: time.Now().Add(-31 * day)},
+		{ID: 2, Description: "Recent", CreatedAt: time.Now().Add(-29 * day)},
+		{ID: 3, Description: "Old but done", Done: true, CreatedAt: time.Now().Add(-60 * day)},
+	}
+	list := func(args ...string) []string {
+		out := captureOutput(t, func() {
+			if err := handleList(tasks, append([]string{"--width=200", "--no-summary"}, args...)); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+		return strings.Split(strings.TrimSpace(out), "\n")
+	}
+
+	// The default max age is 30 days
+	lines := list()
+	if len(lines) != 4 || !strings.HasSuffix(lines[1], "Old (stale)") || strings.Contains(lines[2], "(stale)") || strings.Contains(lines[3], "(stale)") {
+		t.Errorf("Expected only task 1 marked stale, got:\n%s", strings.Join(lines, "\n"))
+	}
+
+	lines = list("--max-age=7")
+	if !strings.HasSuffix(lines[1], "(stale)") || !strings.HasSuffix(lines[2], "(stale)") {
+		t.Errorf("Expected tasks 1 and 2 stale with --max-age=7, got:\n%s", strings.Join(lines, "\n"))
+	}
+	if lines = list("--max-age=0"); strings.Contains(strings.Join(lines, "\n"), "(stale)") {
+		t.Errorf("Expected no stale marker with --max-age=0, got:\n%s", strings.Join(lines, "\n"))
+	}
+
+	var err error
+	captureOutput(t, func() {
+		err = handleList(tasks, []string{"--max-age=-1"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for negative --max-age, got %v", err)
+	}
+}
+
+func TestListCountBy(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Priority: todo.PriorityHigh, Tags: []string{"work", "home"}},
+		{ID: 2, Description: "Task 2", Priority: todo.PriorityHigh, Done: true, Tags: []string{"work"}},
+		{ID: 3, Description: "Task 3", Priority: todo.PriorityLow},
+	}
+	countBy := func(args ...string) string {
+		var err error
+		out := captureOutput(t, func() { err = handleList(tasks, args) })
+		if err != nil {
+			t.Fatalf("handleList %v failed: %v", args, err)
+		}
+		return out
+	}
+
+	for key, expected := range map[string]string{
+		"status":   "done: 1\npending: 2\n",
+		"priority": "high: 2\nlow: 1\n",
+		"tag":      "(untagged): 1\nhome: 1\nwork: 2\n",
+	} {
+		if out := countBy("--count-by=" + key); out != expected {
+			t.Errorf("--count-by=%s: expected %q, got %q", key, expected, out)
+		}
+	}
+
+	if out := countBy("--count-by=tag", "--output=json", "--compact"); strings.TrimSpace(out) != `{"(untagged)":1,"home":1,"work":2}` {
+		t.Errorf("Expected tag counts as JSON, got %q", out)
+	}
+
+	var err error
+	captureOutput(t, func() { err = handleList(tasks, []string{"--count-by=ref"}) })
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for an unsupported key, got %v", err)
+	}
+}
+
+func TestListOverdue(t *testing.T) {
+	chdirTemp(t)
+
+	yesterday := time.Now().AddDate(0, 0, -1)
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	tasks := []todo.Task{
+		{ID: 1, Description: "Due yesterday", DueDate: &yesterday},
+		{ID: 2, Description: "Due tomorrow", DueDate: &tomorrow},
+		{ID: 3, Description: "No due date"},
+		{ID: 4, Description: "Done late", Done: true, DueDate: &yesterday},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--overdue", "--output=ids-csv"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "1" {
+		t.Errorf("Expected only task 1 to be overdue, got %q", out)
+	}
+
+	// Without overdue tasks JSON output is still an empty array
+	out = captureOutput(t, func() {
+		if err := handleList(tasks[1:], []string{"--overdue", "--output=json", "--compact"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "[]" {
+		t.Errorf("Expected an empty JSON array, got %q", out)
+	}
+}
+
+func TestPinnedTasksListedFirst(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Done: false},
+	}
+
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handlePin(tasks, []string{"--id=3"})
+		if err != nil {
+			t.Fatalf("handlePin failed: %v", err)
+		}
+	})
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--width=200", "--no-summary"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 4 || lines[1] != "[ ] [ID:3] Task 3 (pinned)" || !strings.Contains(lines[2], "[ID:1]") {
+		t.Errorf("Expected pinned task 3 first, got:\n%s", out)
+	}
+
+	// Structured output uses the same order
+	out = captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=ids-csv"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "3,1,2" {
+		t.Errorf("Expected IDs 3,1,2, got %q", out)
+	}
+
+	captureOutput(t, func() {
+		var err error
+		tasks, err = handleUnpin(tasks, []string{"--id=3"})
+		if err != nil {
+			t.Fatalf("handleUnpin failed: %v", err)
+		}
+	})
+	if tasks[2].Pinned {
+		t.Error("Expected task 3 to be unpinned")
+	}
+}
+
+func TestListSummary(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Done: false},
+		{ID: 4, Description: "Task 4", Done: true},
+		{ID: 5, Description: "Task 5", Done: true},
+	}
+
+	list := func(args ...string) string {
+		return captureOutput(t, func() {
+			if err := handleList(tasks, args); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+	}
+
+	out := list("--filter=pending", "--width=200")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if expected := "Showing 2 of 5 tasks (3 done, 2 pending)"; lines[len(lines)-1] != expected {
+		t.Errorf("Expected summary '%s', got:\n%s", expected, out)
+	}
+
+	out = list("--filter=pending", "--width=200", "--no-summary")
+	if strings.Contains(out, "Showing") {
+		t.Errorf("Expected no summary with --no-summary, got:\n%s", out)
+	}
+
+	// Structured output never gets a summary line
+	out = list("--output=ids-csv")
+	if strings.TrimSpace(out) != "1,2,3,4,5" {
+		t.Errorf("Expected only IDs, got %q", out)
+	}
+}
+
+func TestListNoHeader(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+	}
+
+	list := func(args ...string) string {
+		return captureOutput(t, func() {
+			if err := handleList(tasks, args); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+	}
+
+	out := list("--output=csv")
+	if !strings.HasPrefix(out, "ID,Description,Done") || strings.Count(out, "\n") != 3 {
+		t.Errorf("Expected CSV with header, got:\n%s", out)
+	}
+
+	out = list("--output=csv", "--no-header")
+	if strings.Contains(out, "Description") || !strings.HasPrefix(out, "1,Task 1,false") || strings.Count(out, "\n") != 2 {
+		t.Errorf("Expected CSV without header, got:\n%s", out)
+	}
+
+	out = list("--width=200")
+	if !strings.Contains(out, "Task list (all):") {
+		t.Errorf("Expected text header, got:\n%s", out)
+	}
+
+	out = list("--width=200", "--no-header")
+	if strings.Contains(out, "Task list") || !strings.HasPrefix(out, "[ ] [ID:1] Task 1") {
+		t.Errorf("Expected text without header, got:\n%s", out)
+	}
+}
+
+func TestLoadReportsImportResult(t *testing.T) {
+	chdirTemp(t)
+
+	content := "ID,Description,Done\n1,Valid,false\nabc,Bad ID,false\n2,Bad done,maybe\n3,Also valid,true\n0,Zero ID,false\n"
+	if err := os.WriteFile("mixed.csv", []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	var loaded []todo.Task
+	var result storage.ImportResult
+	out := captureOutput(t, func() {
+		var err error
+		loaded, result, err = handleLoad(nil, []string{"--file=mixed.csv"})
+		if err != nil {
+			t.Fatalf("handleLoad failed: %v", err)
+		}
+	})
+
+	if len(loaded) != 2 || result.Imported != 2 {
+		t.Errorf("Expected 2 imported tasks, got %d (result %d)", len(loaded), result.Imported)
+	}
+	if result.Skipped != 3 || len(result.Errors) != 3 {
+		t.Errorf("Expected 3 skipped records with messages, got %+v", result)
+	}
+	if !strings.Contains(out, "skipped 3 invalid records") || !strings.Contains(out, "line 3") {
+		t.Errorf("Expected human summary with skipped lines, got: %s", out)
+	}
+}
+
+func TestListSortRandomWithSeed(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3"},
+		{ID: 4, Description: "Task 4", Pinned: true},
+		{ID: 5, Description: "Task 5"},
+	}
+
+	list := func(args ...string) string {
+		return captureOutput(t, func() {
+			if err := handleList(tasks, args); err != nil {
+				t.Fatalf("handleList failed: %v", err)
+			}
+		})
+	}
+
+	first := list("--sort=random", "--seed=7", "--output=ids-csv")
+	if second := list("--sort=random", "--seed=7", "--output=ids-csv"); second != first {
+		t.Errorf("Expected the same order for the same seed, got %q and %q", first, second)
+	}
+	// Seed 0 is a seed like any other, not the time-based default
+	zero := list("--sort=random", "--seed=0", "--output=ids-csv")
+	for i := 0; i < 5; i++ {
+		if again := list("--sort=random", "--seed=0", "--output=ids-csv"); again != zero {
+			t.Fatalf("Expected the same order for seed 0, got %q and %q", zero, again)
+		}
+	}
+	// Pinned tasks lead regardless of the sort order
+	if !strings.HasPrefix(first, "4,") {
+		t.Errorf("Expected pinned task 4 first, got %q", first)
+	}
+	if out := list("--sort=description", "--output=ids-csv"); strings.TrimSpace(out) != "4,1,2,3,5" {
+		t.Errorf("Expected pinned task first with description sort, got %q", out)
+	}
+
+	var err error
+	captureOutput(t, func() {
+		err = handleList(tasks, []string{"--sort=unknown"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for invalid sort key, got %v", err)
+	}
+}
+
+// TestHelperProcess runs the application when started by a test as a
+// subprocess with TODO_HELPER_PROCESS=1. Arguments follow "--".
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("TODO_HELPER_PROCESS") != "1" {
+		return
+	}
+	args := os.Args
+	for i, arg := range args {
+		if arg == "--" {
+			args = args[i+1:]
+			break
+		}
+	}
+	os.Args = append([]string{"todo"}, args...)
+	os.Exit(run())
+}
+
+// runHelper runs the application in dir through TestHelperProcess with
+// the extra environment variables in env.
+// Returns the combined output and the exit code.
+func runHelper(t *testing.T, dir string, env []string, args ...string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], append([]string{"-test.run=^TestHelperProcess$", "--"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "TODO_HELPER_PROCESS=1"), env...)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return string(out), exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("todo %v failed to start: %v", args, err)
+	}
+	return string(out), exitOK
+}
+
+// mustRunHelper is like runHelper but fails the test unless the command succeeds.
+func mustRunHelper(t *testing.T, dir string, env []string, args ...string) string {
+	t.Helper()
+	out, code := runHelper(t, dir, env, args...)
+	if code != exitOK {
+		t.Fatalf("todo %v exited with %d:\n%s", args, code, out)
+	}
+	return out
+}
+
+func TestConcurrentAddProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+	dir := t.TempDir()
+
+	const processes = 6
+	var wg sync.WaitGroup
+	errs := make(chan error, processes)
+	for i := 0; i < processes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if out, code := runHelper(t, dir, nil, "add", fmt.Sprintf("--desc=Task from process %d", i)); code != exitOK {
+				errs <- fmt.Errorf("process %d exited with %d\n%s", i, code, out)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	tasks, err := storage.LoadJSON(filepath.Join(dir, tasksFile))
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	if len(tasks) != processes {
+		t.Fatalf("Expected %d tasks to survive concurrent adds, got %d: %+v", processes, len(tasks), tasks)
+	}
+	if err := todo.ValidateTasks(tasks); err != nil {
+		t.Errorf("Concurrent adds produced invalid tasks: %v", err)
+	}
+}
+
+func TestListIgnoresLock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	mustRunHelper(t, dir, nil, "add", "--desc=Readable while locked")
+
+	// A lock held by a running process is never reclaimed, so a read
+	// that waited for it would only finish after the lock timeout
+	lockPath := storage.LockPath(filepath.Join(dir, tasksFile))
+	if err := os.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+
+	start := time.Now()
+	out := mustRunHelper(t, dir, nil, "list")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("list took %v with a lock present, expected it not to wait for the lock", elapsed)
+	}
+	if !strings.Contains(out, "Readable while locked") {
+		t.Errorf("Expected the task in list output, got:\n%s", out)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Errorf("Expected list to leave the lock file alone: %v", err)
+	}
+}
+
+func TestVerboseLockSelfCheck(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	mustRunHelper(t, dir, nil, "--verbose", "add", "--desc=Task 1")
+
+	data, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "Lock self-check: acquired and released") {
+		t.Errorf("Expected lock self-check timing in log, got:\n%s", data)
+	}
+	if _, err := os.Stat(storage.LockPath(filepath.Join(dir, tasksFile))); !os.IsNotExist(err) {
+		t.Errorf("Expected no lock file after self-check, got %v", err)
+	}
+
+	// The self-check is skipped by default
+	dir = t.TempDir()
+	mustRunHelper(t, dir, nil, "list")
+	data, err = os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if strings.Contains(string(data), "Lock self-check") {
+		t.Errorf("Expected no lock self-check without --verbose, got:\n%s", data)
+	}
+}
+
+func TestTasksFileOverride(t *testing.T) {
+	t.Setenv(tasksFileEnv, "")
+	if got, _ := resolveTasksFile(""); got != defaultTasksFile {
+		t.Errorf("Expected %s without flag and env, got %s", defaultTasksFile, got)
+	}
+	t.Setenv(tasksFileEnv, "env.json")
+	if got, _ := resolveTasksFile(""); got != "env.json" {
+		t.Errorf("Expected TODO_FILE to be used, got %s", got)
+	}
+	if got, _ := resolveTasksFile("flag.json"); got != "flag.json" {
+		t.Errorf("Expected --file to override TODO_FILE, got %s", got)
+	}
+
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	shared := filepath.Join(t.TempDir(), "shared.json")
+	other := filepath.Join(t.TempDir(), "other.json")
+	runTodo := func(args ...string) {
+		mustRunHelper(t, dir, []string{tasksFileEnv + "=" + shared}, args...)
+	}
+
+	// TODO_FILE is used for loading and saving
+	runTodo("add", "--desc=Task 1")
+	runTodo("add", "--desc=Task 2")
+	tasks, err := storage.LoadJSON(shared)
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("Expected 2 tasks in %s, got %d, %v", shared, len(tasks), err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultTasksFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no %s in the working directory, got %v", defaultTasksFile, err)
+	}
+
+	// --file overrides TODO_FILE
+	runTodo("--file="+other, "add", "--desc=Other task")
+	if tasks, err := storage.LoadJSON(other); err != nil || len(tasks) != 1 {
+		t.Errorf("Expected 1 task in %s, got %d, %v", other, len(tasks), err)
+	}
+	if tasks, _ := storage.LoadJSON(shared); len(tasks) != 2 {
+		t.Errorf("Expected %s to stay unchanged, got %d tasks", shared, len(tasks))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), "Using tasks file: "+other) {
+		t.Errorf("Expected the chosen tasks file in the log, got:\n%s", data)
+	}
+}
+
+func TestCSVDataFormat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	env := func(format string) []string {
+		return []string{tasksFileEnv + "=", dataFormatEnv + "=" + format}
+	}
+
+	mustRunHelper(t, dir, env(""), "--data-format=csv", "add", "--desc=Buy milk")
+	mustRunHelper(t, dir, env(""), "--data-format=csv", "add", "--desc=Write, with comma")
+	mustRunHelper(t, dir, env(""), "--data-format=csv", "complete", "--id=1")
+
+	csvFile := filepath.Join(dir, "tasks.csv")
+	tasks, err := storage.LoadCSV(csvFile)
+	if err != nil {
+		t.Fatalf("Failed to load %s: %v", csvFile, err)
+	}
+	if len(tasks) != 2 || !tasks[0].Done || tasks[1].Done || tasks[1].Description != "Write, with comma" {
+		t.Fatalf("Expected task 1 done and task 2 pending in %s, got %+v", csvFile, tasks)
+	}
+	if _, err := os.Stat(filepath.Join(dir, defaultTasksFile)); !os.IsNotExist(err) {
+		t.Errorf("Expected no %s with --data-format=csv, got %v", defaultTasksFile, err)
+	}
+
+	// TODO_DATA_FORMAT selects the same store as the flag
+	out := mustRunHelper(t, dir, env("csv"), "list", "--filter=pending")
+	if !strings.Contains(out, "Write, with comma") || strings.Contains(out, "Buy milk") {
+		t.Errorf("Expected only the pending task in list output, got:\n%s", out)
+	}
+
+	if out, code := runHelper(t, dir, env(""), "--data-format=xml", "list"); code != exitUsage {
+		t.Errorf("Expected exit code %d for an unknown data format, got %d:\n%s", exitUsage, code, out)
+	}
+
+	// An existing tasks.json is not silently replaced by an empty tasks.yaml
+	mustRunHelper(t, dir, env(""), "add", "--desc=Kept in JSON")
+	if out, code := runHelper(t, dir, env(""), "--data-format=yaml", "list"); code != exitUsage || !strings.Contains(out, "export --format=yaml") {
+		t.Errorf("Expected exit code %d and a hint to convert, got %d:\n%s", exitUsage, code, out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tasks.yaml")); !os.IsNotExist(err) {
+		t.Errorf("Expected no tasks.yaml to be created, got %v", err)
+	}
+	mustRunHelper(t, dir, env(""), "export", "--format=yaml", "--out=tasks.yaml")
+	if out := mustRunHelper(t, dir, env(""), "--data-format=yaml", "list"); !strings.Contains(out, "Kept in JSON") {
+		t.Errorf("Expected converted tasks in yaml list output, got:\n%s", out)
+	}
+}
+
+func TestLogErrorsOnly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	// ConsoleError for the blank description and the failing complete
+	// are written to the log file only
+	if out, code := runHelper(t, dir, nil, "--log-errors-only", "add", "--desc=Good", "--desc="); code != exitOK || strings.Contains(out, "Cannot add task") {
+		t.Errorf("Expected add to succeed without the error on the console, got %d:\n%s", code, out)
+	}
+	if out, code := runHelper(t, dir, nil, "--log-errors-only", "complete", "--id=42"); code != exitError || out != "" {
+		t.Errorf("Expected complete to fail without console output, got %d:\n%s", code, out)
+	}
+
+	// Command output is still printed
+	if out := mustRunHelper(t, dir, nil, "--log-errors-only", "list"); !strings.Contains(out, "Good") {
+		t.Errorf("Expected list output with --log-errors-only, got:\n%s", out)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	for _, want := range []string{"ERROR", "Cannot add task", "Complete failed"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %q in the log file, got:\n%s", want, data)
+		}
+	}
+
+	// Without the flag the same error reaches the console
+	if out, _ := runHelper(t, dir, nil, "complete", "--id=42"); !strings.Contains(out, "Complete failed") {
+		t.Errorf("Expected the error on the console without --log-errors-only, got:\n%s", out)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	runTodo := func(args ...string) string {
+		return mustRunHelper(t, dir, nil, args...)
+	}
+	ids := func() string {
+		tasks, err := storage.LoadJSON(filepath.Join(dir, tasksFile))
+		if err != nil {
+			t.Fatalf("LoadJSON failed: %v", err)
+		}
+		return formatIDsCSV(tasks)
+	}
+
+	// Nothing to undo is not an error
+	if out := runTodo("undo"); !strings.Contains(out, "Nothing to undo") {
+		t.Errorf("Expected nothing to undo, got:\n%s", out)
+	}
+
+	runTodo("add", "--desc=Task 1", "--desc=Task 2")
+	runTodo("delete", "--id=1")
+	if got := ids(); got != "2" {
+		t.Fatalf("Expected task 1 deleted, got %s", got)
+	}
+
+	// Undo restores the deleted task, undo again redoes the delete
+	out := runTodo("undo")
+	if got := ids(); got != "1,2" {
+		t.Errorf("Expected undo to restore task 1, got %s", got)
+	}
+	if !strings.Contains(out, "Restored 2 tasks") {
+		t.Errorf("Expected restore report, got:\n%s", out)
+	}
+	runTodo("undo")
+	if got := ids(); got != "2" {
+		t.Errorf("Expected second undo to redo the delete, got %s", got)
+	}
+
+	// Read-only commands don't replace the backup
+	runTodo("list")
+	runTodo("undo")
+	if got := ids(); got != "1,2" {
+		t.Errorf("Expected list to keep the backup, got %s", got)
+	}
+
+	// The event log is never backed up or swapped, so undo is rejected
+	// with --append-only and the history stays intact
+	runTodo("--append-only", "add", "--desc=Logged 1")
+	runTodo("--append-only", "add", "--desc=Logged 2")
+	eventsPath := filepath.Join(dir, storage.EventsPath(tasksFile))
+	logBefore, err := os.ReadFile(eventsPath)
+	if err != nil {
+		t.Fatalf("Cannot read event log: %v", err)
+	}
+	if _, err := os.Stat(storage.BackupPath(eventsPath)); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup of the event log, stat error: %v", err)
+	}
+	if out, code := runHelper(t, dir, nil, "--append-only", "undo"); code != exitUsage {
+		t.Errorf("Expected exit code %d for undo with --append-only, got %d:\n%s", exitUsage, code, out)
+	}
+	logAfter, err := os.ReadFile(eventsPath)
+	if err != nil {
+		t.Fatalf("Cannot read event log: %v", err)
+	}
+	if string(logAfter) != string(logBefore) {
+		t.Errorf("Expected event log unchanged, got:\n%s", logAfter)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := map[string]logger.LogLevel{
+		"debug":   logger.LevelDebug,
+		"INFO":    logger.LevelInfo,
+		"warn":    logger.LevelWarn,
+		"warning": logger.LevelWarn,
+		" error ": logger.LevelError,
+	}
+	for name, expected := range tests {
+		level, err := parseLogLevel(name)
+		if err != nil {
+			t.Errorf("parseLogLevel(%q) failed: %v", name, err)
+		} else if level != expected {
+			t.Errorf("parseLogLevel(%q): expected %v, got %v", name, expected, level)
+		}
+	}
+
+	// Unknown levels fall back to the default
+	if level, err := parseLogLevel("verbose"); err == nil || level != defaultConsoleLevel {
+		t.Errorf("Expected error and default level for unknown level, got %v, %v", level, err)
+	}
+}
+
+func TestStrictIDs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	ids := func(dir string) string {
+		tasks, err := storage.LoadJSON(filepath.Join(dir, tasksFile))
+		if err != nil {
+			t.Fatalf("LoadJSON failed: %v", err)
+		}
+		return formatIDsCSV(tasks)
+	}
+
+	for _, strict := range []bool{false, true} {
+		dir := t.TempDir()
+		mustRunHelper(t, dir, nil, "add", "--desc=Task 1", "--desc=Task 2", "--desc=Task 3")
+
+		args := []string{"delete", "--id=2"}
+		if strict {
+			args = append([]string{"--strict-ids"}, args...)
+		}
+		mustRunHelper(t, dir, nil, args...)
+
+		expected := "1,3"
+		if strict {
+			expected = "1,2"
+		}
+		if got := ids(dir); got != expected {
+			t.Errorf("strict=%v: expected IDs %s, got %s", strict, expected, got)
+		}
+	}
+}
+
+func TestReuseIDsLast(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts subprocesses")
+	}
+
+	dir := t.TempDir()
+	mustRunHelper(t, dir, nil, "add", "--desc=Task 1", "--desc=Task 2", "--desc=Task 3")
+	mustRunHelper(t, dir, nil, "delete", "--id=2")
+	mustRunHelper(t, dir, nil, "--reuse-ids", "add", "--desc=Reused")
+
+	// last is the task just added with the reused ID 2, not the highest ID
+	mustRunHelper(t, dir, nil, "complete", "--id=last")
+	tasks, err := storage.LoadJSON(filepath.Join(dir, tasksFile))
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	for _, task := range tasks {
+		if task.Done != (task.Description == "Reused") || (task.Description == "Reused" && task.ID != 2) {
+			t.Errorf("Expected only the reused task 2 completed, got %+v", tasks)
+			break
+		}
+	}
+}
+
+func TestNumericDescriptionDoesNotCollideWithID(t *testing.T) {
+	var tasks []todo.Task
+	for i := 1; i <= 42; i++ {
+		tasks = append(tasks, todo.Task{ID: i, Description: fmt.Sprintf("Task %d", i)})
+	}
+
+	// A purely numeric description is stored as is, with a hint
+	out := captureOutput(t, func() {
+		var err error
+		tasks, err = handleAdd(tasks, []string{"--desc=42"})
+		if err != nil {
+			t.Fatalf("handleAdd failed: %v", err)
+		}
+	})
+	if tasks[42].ID != 43 || tasks[42].Description != "42" {
+		t.Fatalf("Expected task 43 described as '42', got %+v", tasks[42])
+	}
+	if !strings.Contains(out, "--match=42") {
+		t.Errorf("Expected hint about --match, got: %s", out)
+	}
+
+	// --match targets the description, not ID 42
+	var result []todo.Task
+	captureOutput(t, func() {
+		var err error
+		result, err = handleComplete(append([]todo.Task(nil), tasks...), []string{"--match=42"})
+		if err != nil {
+			t.Fatalf("handleComplete --match failed: %v", err)
+		}
+	})
+	if !result[42].Done || result[41].Done {
+		t.Errorf("Expected only task 43 to be completed by --match=42")
+	}
+
+	// --id targets the ID, not the description
+	captureOutput(t, func() {
+		var err error
+		result, err = handleComplete(append([]todo.Task(nil), tasks...), []string{"--id=42"})
+		if err != nil {
+			t.Fatalf("handleComplete --id failed: %v", err)
+		}
+	})
+	if !result[41].Done || result[42].Done {
+		t.Errorf("Expected only task 42 to be completed by --id=42")
+	}
+}
+
+func TestExportMaxCell(t *testing.T) {
+	chdirTemp(t)
+
+	long := "Купить молоко и хлеб"
+	tasks := []todo.Task{
+		{ID: 1, Description: long},
+		{ID: 2, Description: "Короткая"},
+		{ID: 3, Description: "0123456789"},
+	}
+
+	captureOutput(t, func() {
+		if err := handleExport(tasks, []string{"--format=csv", "--out=short", "--max-cell=10"}); err != nil {
+			t.Fatalf("handleExport failed: %v", err)
+		}
+		if err := handleExport(tasks, []string{"--format=json", "--out=full"}); err != nil {
+			t.Fatalf("handleExport failed: %v", err)
+		}
+	})
+
+	loaded, err := storage.LoadCSV("short.csv")
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	// Cut at 10 runes including the ellipsis, exactly 10 runes stay intact
+	expected := []string{"Купить мо…", "Короткая", "0123456789"}
+	for i, task := range loaded {
+		if task.Description != expected[i] {
+			t.Errorf("Task %d: expected '%s', got '%s'", task.ID, expected[i], task.Description)
+		}
+	}
+	if tasks[0].Description != long {
+		t.Error("Export must not modify the task list")
+	}
+
+	// JSON export is unaffected
+	full, err := storage.LoadJSON("full.json")
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	if full[0].Description != long {
+		t.Errorf("Expected full description in JSON, got '%s'", full[0].Description)
+	}
+
+	captureOutput(t, func() {
+		err = handleExport(tasks, []string{"--format=json", "--max-cell=10"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for --max-cell with json, got %v", err)
+	}
+}
+
+func TestAddNoDup(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{{ID: 1, Description: "Buy milk", Priority: todo.PriorityMedium}}
+
+	var result []todo.Task
+	var err error
+	out := captureOutput(t, func() {
+		result, err = handleAdd(tasks, []string{"--desc=buy milk", "--no-dup"})
+	})
+	if err != nil || result != nil {
+		t.Errorf("Expected a duplicate to be skipped without error, got %+v, %v", result, err)
+	}
+	if !strings.Contains(out, "already exists as task 1") {
+		t.Errorf("Expected duplicate message, got:\n%s", out)
+	}
+
+	captureOutput(t, func() {
+		result, err = handleAdd(tasks, []string{"--desc=Buy milk", "--desc=Call mom", "--no-dup"})
+	})
+	if err != nil || len(result) != 2 || result[1].Description != "Call mom" {
+		t.Errorf("Expected only 'Call mom' to be added, got %+v, %v", result, err)
+	}
+
+	// Without --no-dup duplicates are still added
+	captureOutput(t, func() {
+		result, err = handleAdd(tasks, []string{"--desc=Buy milk"})
+	})
+	if err != nil || len(result) != 2 {
+		t.Errorf("Expected the duplicate to be added, got %+v, %v", result, err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	dir := t.TempDir()
+
+	files := map[string][]todo.Task{
+		"home.json": {
+			{ID: 1, Description: "Buy milk", Done: true},
+			{ID: 2, Description: "Call mom"},
+		},
+		"work.json": {
+			{ID: 1, Description: "Write report"},
+			{ID: 2, Description: "Review PR"},
+			{ID: 3, Description: "Deploy", Done: true},
+		},
+	}
+	for name, tasks := range files {
+		if err := storage.SaveJSON(filepath.Join(dir, name), tasks); err != nil {
+			t.Fatalf("SaveJSON failed: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.json"), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	// Sidecars of a data file match *.json too but are not task files
+	if err := storage.SaveState(storage.StatePath(filepath.Join(dir, "home.json")), storage.ListState{LastRun: time.Now()}); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	if err := storage.SaveTemplate(storage.TemplatesPath(filepath.Join(dir, "home.json")), todo.Template{Name: "bug"}); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+	glob := "--glob=" + filepath.Join(dir, "*.json")
+
+	var err error
+	out := captureOutput(t, func() { err = handleReport([]string{glob}) })
+	if err != nil {
+		t.Fatalf("handleReport failed: %v", err)
+	}
+	for _, expected := range []string{
+		"home.json: 2 total, 1 done, 1 pending",
+		"work.json: 3 total, 1 done, 2 pending",
+		"Total (2 files): 5 total, 2 done, 3 pending",
+		"Skipping " + filepath.Join(dir, "broken.json"),
+	} {
+		if !strings.Contains(out, expected) {
+			t.Errorf("Expected %q in report, got:\n%s", expected, out)
+		}
+	}
+	if strings.Contains(out, "state.json") || strings.Contains(out, storage.TemplatesFileName) {
+		t.Errorf("Expected sidecar files to be ignored, got:\n%s", out)
+	}
+
+	out = captureOutput(t, func() { err = handleReport([]string{glob, "--output=json"}) })
+	if err != nil {
+		t.Fatalf("handleReport failed: %v", err)
+	}
+	var report statusReport
+	if err := json.Unmarshal([]byte(out[strings.Index(out, "{"):]), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v\n%s", err, out)
+	}
+	if len(report.Files) != 2 || len(report.Skipped) != 1 || report.Total != (todo.TaskStats{Total: 5, Done: 2, Pending: 3}) {
+		t.Errorf("Unexpected JSON report: %+v", report)
+	}
+
+	captureOutput(t, func() { err = handleReport([]string{"--glob=["}) })
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for malformed pattern, got %v", err)
+	}
+}
+
+func TestLoadAppend(t *testing.T) {
+	chdirTemp(t)
+
+	exported := []todo.Task{
+		{ID: 1, Description: "Buy milk", Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Call mom", Priority: todo.PriorityMedium},
+	}
+	if err := storage.SaveJSON("backup.json", exported); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+	current := []todo.Task{
+		{ID: 1, Description: "Buy milk", Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Added after export", Priority: todo.PriorityMedium},
+	}
+
+	var replaced, merged []todo.Task
+	out := captureOutput(t, func() {
+		var err error
+		replaced, _, err = handleLoad(current, []string{"--file=backup.json"})
+		if err != nil {
+			t.Fatalf("handleLoad failed: %v", err)
+		}
+		merged, _, err = handleLoad(current, []string{"--file=backup.json", "--append"})
+		if err != nil {
+			t.Fatalf("handleLoad --append failed: %v", err)
+		}
+	})
+
+	// Without --append the file replaces the current tasks
+	if !reflect.DeepEqual(replaced, exported) {
+		t.Errorf("Expected tasks from the file, got %+v", replaced)
+	}
+
+	if len(merged) != 3 || merged[2].ID != 3 || merged[2].Description != "Call mom" {
+		t.Errorf("Expected current tasks plus 'Call mom' as ID 3, got %+v", merged)
+	}
+	if !strings.Contains(out, "Skipped 1 tasks that already exist") {
+		t.Errorf("Expected duplicate report, got:\n%s", out)
+	}
+}
+
+func TestLoadReplaceIDs(t *testing.T) {
+	chdirTemp(t)
+
+	exported := []todo.Task{
+		{ID: 4, Description: "Buy milk", Priority: todo.PriorityMedium},
+		{ID: 1, Description: "Call mom", Done: true, Priority: todo.PriorityHigh},
+	}
+	if err := storage.SaveJSON("backup.json", exported); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+	current := []todo.Task{
+		{ID: 1, Description: "Buy milk", Priority: todo.PriorityMedium},
+		{ID: 3, Description: "Write report", Priority: todo.PriorityMedium},
+	}
+
+	var loaded []todo.Task
+	var err error
+	captureOutput(t, func() {
+		loaded, _, err = handleLoad(current, []string{"--file=backup.json", "--replace-ids"})
+	})
+	if err != nil {
+		t.Fatalf("handleLoad --replace-ids failed: %v", err)
+	}
+
+	// Imported tasks follow the current ones with IDs 4 and 5, in file order
+	if len(loaded) != 4 || loaded[2].ID != 4 || loaded[2].Description != "Buy milk" ||
+		loaded[3].ID != 5 || loaded[3].Description != "Call mom" || !loaded[3].Done {
+		t.Errorf("Unexpected tasks after --replace-ids: %+v", loaded)
+	}
+
+	captureOutput(t, func() {
+		_, _, err = handleLoad(current, []string{"--file=backup.json", "--replace-ids", "--append"})
+	})
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for --replace-ids with --append, got %v", err)
+	}
+}
+
+func TestExportAndLoadDelimiter(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Buy milk, bread", Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Call mom", Done: true, Priority: todo.PriorityMedium},
+	}
+
+	var loaded []todo.Task
+	var err error
+	captureOutput(t, func() {
+		if err := handleExport(tasks, []string{"--format=csv", "--out=sheet", "--delimiter=;"}); err != nil {
+			t.Fatalf("handleExport failed: %v", err)
+		}
+		loaded, _, err = handleLoad(nil, []string{"--file=sheet.csv", "--delimiter=;"})
+	})
+	if err != nil {
+		t.Fatalf("handleLoad failed: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Description != "Buy milk, bread" || !loaded[1].Done {
+		t.Errorf("Unexpected tasks: %+v", loaded)
+	}
+
+	for _, args := range [][]string{
+		{"--format=csv", "--delimiter=;;"},
+		{"--format=json", "--delimiter=;"},
+	} {
+		captureOutput(t, func() { err = handleExport(tasks, args) })
+		if exitCode(err) != exitUsage {
+			t.Errorf("handleExport %v: expected usage error, got %v", args, err)
+		}
+	}
+	captureOutput(t, func() { _, _, err = handleLoad(nil, []string{"--file=sheet.csv", "--delimiter=ab"}) })
+	if exitCode(err) != exitUsage {
+		t.Errorf("Expected usage error for multi-character delimiter, got %v", err)
+	}
+}
+
+func TestExportAndLoadYAML(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Купить молоко", Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Call mom", Done: true, Priority: todo.PriorityHigh, Tags: []string{"home"}},
+	}
+
+	var loaded []todo.Task
+	captureOutput(t, func() {
+		if err := handleExport(tasks, []string{"--format=yaml", "--out=backup"}); err != nil {
+			t.Fatalf("handleExport failed: %v", err)
+		}
+		if err := os.Rename("backup.yaml", "backup.yml"); err != nil {
+			t.Fatalf("Rename failed: %v", err)
+		}
+		var err error
+		loaded, _, err = handleLoad(nil, []string{"--file=backup.yml"})
+		if err != nil {
+			t.Fatalf("handleLoad failed: %v", err)
+		}
+	})
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected %+v, got %+v", tasks, loaded)
+	}
+}
+
+func TestExportDryRun(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2", Done: true},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleExport(tasks, []string{"--format=csv", "--out=backup", "--dry-run", "--checksum"}); err != nil {
+			t.Fatalf("handleExport --dry-run failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "Would export 2 tasks to backup.csv (csv)") {
+		t.Errorf("Expected dry-run summary, got %q", out)
+	}
+
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "backup") {
+			t.Errorf("Expected no file to be written, found %s", entry.Name())
+		}
+	}
+}
+
+func TestExportChecksumAndLoadVerify(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Task 2", Done: true, Priority: todo.PriorityMedium},
+	}
+
+	captureOutput(t, func() {
+		if err := handleExport(tasks, []string{"--out=backup", "--checksum"}); err != nil {
+			t.Fatalf("handleExport failed: %v", err)
+		}
+	})
+	if _, err := os.Stat("backup.json.sha256"); err != nil {
+		t.Fatalf("Expected checksum sidecar: %v", err)
+	}
+
+	// A matching file is imported
+	var loaded []todo.Task
+	var err error
+	captureOutput(t, func() {
+		loaded, _, err = handleLoad(nil, []string{"--file=backup.json", "--verify"})
+	})
+	if err != nil {
+		t.Fatalf("handleLoad --verify failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected %+v, got %+v", tasks, loaded)
+	}
+
+	// A tampered file is rejected
+	data, err := os.ReadFile("backup.json")
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if err := os.WriteFile("backup.json", []byte(strings.Replace(string(data), "Task 2", "Task X", 1)), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	captureOutput(t, func() {
+		loaded, _, err = handleLoad(nil, []string{"--file=backup.json", "--verify"})
+	})
+	if !errors.Is(err, storage.ErrChecksumMismatch) || loaded != nil {
+		t.Errorf("Expected checksum mismatch and no tasks, got %v, %+v", err, loaded)
+	}
+
+	// Without --verify the sidecar is ignored
+	captureOutput(t, func() {
+		_, _, err = handleLoad(nil, []string{"--file=backup.json"})
+	})
+	if err != nil {
+		t.Errorf("Expected load without --verify to succeed, got %v", err)
+	}
+}
+
+func TestListAndLoadTSV(t *testing.T) {
+	chdirTemp(t)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "With\ttab", Done: false, Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Plain", Done: true, Priority: todo.PriorityHigh},
+	}
+
+	out := captureOutput(t, func() {
+		if err := handleList(tasks, []string{"--output=tsv", "--no-header"}); err != nil {
+			t.Fatalf("handleList failed: %v", err)
+		}
+	})
+	if out != "1\t\"With\ttab\"\tfalse\t\tfalse\tmedium\t\t\t\t\n2\tPlain\ttrue\t\tfalse\thigh\t\t\t\t\n" {
+		t.Errorf("Unexpected TSV output: %q", out)
+	}
+
+	captureOutput(t, func() {
+		if err := handleExport(tasks, []string{"--format=tsv", "--out=backup"}); err != nil {
+			t.Fatalf("handleExport failed: %v", err)
+		}
+	})
+	var loaded []todo.Task
+	captureOutput(t, func() {
+		var err error
+		loaded, _, err = handleLoad(nil, []string{"--file=backup.tsv"})
+		if err != nil {
+			t.Fatalf("handleLoad failed: %v", err)
+		}
+	})
+	if len(loaded) != 2 || loaded[0].Description != "With\ttab" {
+		t.Errorf("Unexpected tasks loaded from TSV: %+v", loaded)
+	}
+}
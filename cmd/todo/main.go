@@ -1,8 +1,12 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"todo-app/internal/storage"
 	"todo-app/internal/todo"
@@ -10,6 +14,93 @@ import (
 	"github.com/ZeRg0912/logger"
 )
 
+// defaultTasksFile is the path of the primary data file
+// when neither --file nor TODO_FILE is set.
+const defaultTasksFile = "tasks.json"
+
+// tasksFileEnv is the environment variable that overrides defaultTasksFile.
+const tasksFileEnv = "TODO_FILE"
+
+// tasksFile is the path of the primary data file, see resolveTasksFile.
+var tasksFile = defaultTasksFile
+
+// dataFormatEnv is the environment variable that sets the data file format.
+const dataFormatEnv = "TODO_DATA_FORMAT"
+
+// idStrategy picks the IDs of new tasks, todo.LowestFreeID with --reuse-ids.
+var idStrategy todo.IDStrategy = todo.NextID
+
+// dataStore loads and saves the primary data file, see resolveDataStore.
+var dataStore = storage.Store{Format: "json", Load: storage.LoadJSON, Save: storage.SaveJSON, Write: storage.WriteJSON}
+
+// logFile is the path of the application log file.
+const logFile = "logs/app.log"
+
+// logLevelEnv is the environment variable that sets the console log level.
+const logLevelEnv = "TODO_LOG_LEVEL"
+
+// defaultConsoleLevel is the console log level when TODO_LOG_LEVEL is unset or invalid.
+const defaultConsoleLevel = logger.LevelError
+
+// errorsOnlyConsoleLevel is above every log level, so with --log-errors-only
+// no log message, errors included, reaches the console.
+const errorsOnlyConsoleLevel = logger.LevelError + 1
+
+// logErrorsOnly is set by the global --log-errors-only flag, see consoleError.
+var logErrorsOnly bool
+
+// Build information, injected at build time with
+// -ldflags "-X main.Version=... -X main.Commit=... -X main.Date=...".
+var (
+	Version = "dev"
+	Commit  = "dev"
+	Date    = "dev"
+)
+
+// Exit codes returned by run().
+const (
+	exitOK    = 0 // Command completed successfully
+	exitError = 1 // Command failed
+	exitUsage = 2 // Command line usage was invalid
+)
+
+// mutatingCommands are the commands that save the task list.
+// They hold the data file lock from load until save, so concurrent
+// processes cannot load the same state and overwrite each other's changes.
+var mutatingCommands = map[string]bool{
+	"add":              true,
+	"complete":         true,
+	"uncomplete":       true,
+	"delete":           true,
+	"move":             true,
+	"clear":            true,
+	"pin":              true,
+	"unpin":            true,
+	"tag-add":          true,
+	"tag-remove":       true,
+	"load":             true,
+	"restore-snapshot": true,
+	"undo":             true,
+}
+
+// commandAliases maps short command names to their canonical command.
+var commandAliases = map[string]string{
+	"ls":   "list",
+	"rm":   "delete",
+	"mk":   "add",
+	"new":  "add",
+	"done": "complete",
+}
+
+// resolveCommand returns the canonical name for a command or alias.
+// Unknown names are returned unchanged.
+func resolveCommand(name string) string {
+	if canonical, ok := commandAliases[name]; ok {
+		return canonical
+	}
+	return name
+}
+
 // main is the entry point of the To-Do Manager application.
 // It calls run() and exits with the returned exit code.
 func main() {
@@ -17,26 +108,99 @@ func main() {
 }
 
 // run executes the application logic and returns an exit code.
-// Returns 0 on success, 1 on error.
+// Returns exitOK on success, exitUsage on invalid usage, exitError on other errors.
 // This separation allows for better testability of the application logic.
 //
 // The application supports the following commands:
 //   - add: Add a new task
 //   - list: List tasks with optional filtering
+//   - count: Print the number of tasks
 //   - complete: Mark a task as completed
+//   - uncomplete: Mark a completed task as pending again
 //   - delete: Delete a task
+//   - move: Move a task to another position in the list
+//   - clear: Remove all completed tasks
+//   - pin, unpin: Keep a task at the top of list output
+//   - next: Recommend the most urgent pending tasks
+//   - search: Find tasks by description
+//   - report: Summarize task counts across several data files
+//   - query: Query tasks with a jq-style expression
 //   - export: Export tasks to JSON or CSV
 //   - load: Import tasks from JSON or CSV
+//   - snapshot, restore-snapshot, snapshots: Manage named snapshots
+//   - template: Save and list task templates for add --template
+//   - undo: Revert the last change, run again to redo it
+//   - doctor: Diagnose environment issues
+//   - version: Print build information
 //   - help: Show usage information
 //
+// Short aliases from commandAliases (ls, rm, mk, new, done) resolve to
+// their canonical command before dispatch.
+//
 // Tasks are persisted in a JSON file and automatically saved after modifying commands.
+// Before every save the previous data file is copied to a .bak backup for undo.
+// With the global --append-only flag, every change is instead appended to an
+// event log and the current state is rebuilt by replaying it on load.
+// With the global --strict-ids flag, tasks are renumbered to contiguous IDs
+// before every save; by default gaps are kept, since renumbering breaks
+// external references to task IDs.
+// With the global --reuse-ids flag, new tasks fill the lowest gap in IDs
+// instead of getting the highest ID plus one.
+// With the global --lock-dir flag, lock files are created in that directory
+// instead of next to the data file.
+// With the global --file flag, or the TODO_FILE environment variable,
+// tasks are loaded from and saved to that file instead of tasks.json.
+// With the global --data-format flag, or the TODO_DATA_FORMAT environment
+// variable, the data file is stored as yaml, csv or tsv instead of JSON,
+// and its default name changes to match, e.g. tasks.csv.
+// With the global --verbose flag, a lock self-check logs how long it takes
+// to acquire and release the data file lock.
+//
+// The TODO_LOG_LEVEL environment variable (debug, info, warn, error) sets
+// which log messages are also printed to the console; the default is error.
+// With the global --log-errors-only flag, errors are written to the log file
+// but not printed to the console, e.g. for cron jobs; command output is unchanged.
 func run() int {
-	// Initialize logger - LevelError to console, all levels to file
-	err := logger.InitBoth(logger.LevelError, logger.LevelDebug, "logs/app.log", 10*1024*1024)
+	// Parse global flags placed before the command, before the logger
+	// is initialized, since --log-errors-only sets its console level
+	globalCmd := flag.NewFlagSet("todo", flag.ContinueOnError)
+	appendOnly := globalCmd.Bool("append-only", false, "Record changes in an append-only event log")
+	verbose := globalCmd.Bool("verbose", false, "Run startup self-checks and log their timings")
+	strictIDs := globalCmd.Bool("strict-ids", false, "Renumber tasks to contiguous IDs on every save")
+	reuseIDs := globalCmd.Bool("reuse-ids", false, "Give new tasks the lowest free ID instead of the highest plus one")
+	lockDir := globalCmd.String("lock-dir", "", "Directory for lock files (default: next to the data file)")
+	file := globalCmd.String("file", "", "Tasks file (default: $TODO_FILE or tasks.json)")
+	dataFormat := globalCmd.String("data-format", "", "Data file format: json, yaml, csv or tsv (default: $TODO_DATA_FORMAT or json)")
+	errorsOnly := globalCmd.Bool("log-errors-only", false, "Write errors only to the log file, not to the console")
+	setupCommandConfig(globalCmd)
+	parseErr := globalCmd.Parse(os.Args[1:])
+
+	consoleLevel := defaultConsoleLevel
+	envLevel := os.Getenv(logLevelEnv)
+	var levelErr error
+	if envLevel != "" {
+		if consoleLevel, levelErr = parseLogLevel(envLevel); levelErr != nil {
+			consoleLevel = defaultConsoleLevel
+		}
+	}
+
+	logErrorsOnly = *errorsOnly
+	if logErrorsOnly {
+		consoleLevel = errorsOnlyConsoleLevel
+	}
+
+	// Initialize logger - consoleLevel to console, all levels to file
+	err := logger.InitBoth(consoleLevel, logger.LevelDebug, logFile, 10*1024*1024)
 	if err != nil {
 		// Before initialize logger all info to console by fmt
 		fmt.Printf("Failed to initialize logger: %v\n", err)
-		return 1
+		return exitError
+	}
+	if levelErr != nil {
+		logger.Warn("Ignoring %s: %v", logLevelEnv, levelErr)
+		if !logErrorsOnly {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v, using error\n", logLevelEnv, levelErr)
+		}
 	}
 
 	defer func() {
@@ -45,25 +209,104 @@ func run() int {
 		}
 	}()
 
-	if len(os.Args) < 2 {
+	if errors.Is(parseErr, flag.ErrHelp) {
+		printUsage()
+		return exitOK
+	} else if parseErr != nil {
+		logger.Error("Invalid global flags: %v", parseErr)
+		printUsage()
+		return exitUsage
+	}
+
+	if *reuseIDs {
+		idStrategy = todo.LowestFreeID
+	}
+	storage.SetLockDir(*lockDir)
+	if dataStore, err = resolveDataStore(*dataFormat); err != nil {
+		logger.Error("Invalid global flags: %v", err)
 		printUsage()
-		return 1
+		return exitUsage
+	}
+	if tasksFile, err = resolveTasksFile(*file); err != nil {
+		logger.Error("Cannot choose the tasks file: %v", err)
+		return exitUsage
+	}
+
+	if globalCmd.NArg() < 1 {
+		printUsage()
+		return exitUsage
 	}
 
 	// Parse args
-	command := os.Args[1]
-	args := os.Args[2:]
+	command := resolveCommand(globalCmd.Arg(0))
+	args := globalCmd.Args()[1:]
 
 	logger.Info("Command executed: %s %v", command, args)
+	logger.Info("Using tasks file: %s (format: %s)", tasksFile, dataStore.Format)
+	if command != globalCmd.Arg(0) {
+		logger.Debug("Resolved alias '%s' to '%s'", globalCmd.Arg(0), command)
+	}
 	logger.Debug("Full args: %#v", os.Args)
 
+	// The self-check takes the lock itself, so it must run before a
+	// mutating command acquires it
+	if *verbose {
+		if err := selfCheckLock(tasksFile); err != nil {
+			logger.Warn("Lock self-check failed: %v", err)
+		}
+	}
+
+	// Doctor runs before loading, so it can report a corrupt data file
+	if command == "doctor" {
+		if err := handleDoctor(args); err != nil {
+			logger.Error("Doctor failed: %v", err)
+			return exitCode(err)
+		}
+		return exitOK
+	}
+
+	// Version doesn't need the task list
+	if command == "version" {
+		if err := handleVersion(args); err != nil {
+			logger.Error("Version failed: %v", err)
+			return exitCode(err)
+		}
+		return exitOK
+	}
+
+	// Lock before loading, so the whole load, modify, save cycle is atomic
+	var lock *storage.FileLock
+	if mutatingCommands[command] {
+		var err error
+		lock, err = storage.AcquireLock(tasksFile)
+		if err != nil {
+			logger.Error("Failed to lock tasks: %v", err)
+			return exitError
+		}
+		defer lock.Release()
+	}
+
+	// Undo swaps files instead of loading tasks, so it can also
+	// revert a change that left an unreadable data file
+	if command == "undo" {
+		if err := handleUndo(args, *appendOnly); err != nil {
+			logger.Error("Undo failed: %v", err)
+			return exitCode(err)
+		}
+		return exitOK
+	}
+
 	// Load current tasks
-	tasks, err := storage.LoadJSON("tasks.json")
+	eventsFile := storage.EventsPath(tasksFile)
+	tasks, err := loadTasks(*appendOnly)
 	if err != nil {
 		logger.Error("Failed to load tasks: %v", err)
-		return 1
+		return exitError
 	}
 
+	// Keep a deep copy of the loaded state to diff the result against
+	originalTasks := todo.CloneTasks(tasks)
+
 	var resultTasks []todo.Task
 
 	// All available commands
@@ -72,57 +315,259 @@ func run() int {
 		resultTasks, err = handleAdd(tasks, args)
 		if err != nil {
 			logger.Error("Add failed: %v", err)
-			return 1
+			return exitCode(err)
 		}
 	case "list":
 		err := handleList(tasks, args)
 		if err != nil {
 			logger.Error("List failed: %v", err)
-			return 1
+			return exitCode(err)
+		}
+	case "count":
+		err := handleCount(tasks, args)
+		if err != nil {
+			logger.Error("Count failed: %v", err)
+			return exitCode(err)
 		}
 	case "complete":
 		resultTasks, err = handleComplete(tasks, args)
 		if err != nil {
 			logger.Error("Complete failed: %v", err)
-			return 1
+			return exitCode(err)
+		}
+	case "uncomplete":
+		resultTasks, err = handleUncomplete(tasks, args)
+		if err != nil {
+			logger.Error("Uncomplete failed: %v", err)
+			return exitCode(err)
 		}
 	case "delete":
 		resultTasks, err = handleDelete(tasks, args)
 		if err != nil {
 			logger.Error("Delete failed: %v", err)
-			return 1
+			return exitCode(err)
+		}
+	case "move":
+		resultTasks, err = handleMove(tasks, args)
+		if err != nil {
+			logger.Error("Move failed: %v", err)
+			return exitCode(err)
+		}
+	case "clear":
+		resultTasks, err = handleClear(tasks, args)
+		if err != nil {
+			logger.Error("Clear failed: %v", err)
+			return exitCode(err)
+		}
+	case "pin":
+		resultTasks, err = handlePin(tasks, args)
+		if err != nil {
+			logger.Error("Pin failed: %v", err)
+			return exitCode(err)
+		}
+	case "unpin":
+		resultTasks, err = handleUnpin(tasks, args)
+		if err != nil {
+			logger.Error("Unpin failed: %v", err)
+			return exitCode(err)
+		}
+	case "tag-add":
+		resultTasks, err = handleTagAdd(tasks, args)
+		if err != nil {
+			logger.Error("Tag add failed: %v", err)
+			return exitCode(err)
+		}
+	case "tag-remove":
+		resultTasks, err = handleTagRemove(tasks, args)
+		if err != nil {
+			logger.Error("Tag remove failed: %v", err)
+			return exitCode(err)
+		}
+	case "next":
+		err := handleNext(tasks, args)
+		if err != nil {
+			logger.Error("Next failed: %v", err)
+			return exitCode(err)
+		}
+	case "search":
+		err := handleSearch(tasks, args)
+		if err != nil {
+			logger.Error("Search failed: %v", err)
+			return exitCode(err)
+		}
+	case "report":
+		err := handleReport(args)
+		if err != nil {
+			logger.Error("Report failed: %v", err)
+			return exitCode(err)
+		}
+	case "query":
+		err := handleQuery(tasks, args)
+		if err != nil {
+			logger.Error("Query failed: %v", err)
+			return exitCode(err)
 		}
 	case "export":
 		err := handleExport(tasks, args)
 		if err != nil {
 			logger.Error("Export failed: %v", err)
-			return 1
+			return exitCode(err)
 		}
 	case "load":
-		importedTasks, err := handleLoad(args)
+		importedTasks, _, err := handleLoad(tasks, args)
 		if err != nil {
 			logger.Error("Load failed: %v", err)
-			return 1
+			return exitCode(err)
 		}
 		resultTasks = importedTasks
+	case "snapshot":
+		err := handleSnapshot(tasks, args)
+		if err != nil {
+			logger.Error("Snapshot failed: %v", err)
+			return exitCode(err)
+		}
+	case "restore-snapshot":
+		resultTasks, err = handleRestoreSnapshot(args)
+		if err != nil {
+			logger.Error("Restore snapshot failed: %v", err)
+			return exitCode(err)
+		}
+	case "snapshots":
+		err := handleSnapshots(args)
+		if err != nil {
+			logger.Error("Snapshots failed: %v", err)
+			return exitCode(err)
+		}
+	case "template":
+		err := handleTemplate(args)
+		if err != nil {
+			logger.Error("Template failed: %v", err)
+			return exitCode(err)
+		}
 	case "help", "-h", "--help":
 		printUsage()
-		return 0
+		return exitOK
 	default:
 		logger.Error("Unknown command: %s", command)
 		printUsage()
-		return 1
+		return exitUsage
 	}
 
-	// Save changes if command modified tasks
+	// Save changes if command modified tasks, the lock is already held
 	if resultTasks != nil {
-		err = storage.SaveJSON("tasks.json", resultTasks)
+		if *strictIDs {
+			resultTasks = renumberTasks(resultTasks)
+		}
+		// The event log is only ever appended to, so it needs no backup
+		if !*appendOnly {
+			if err := storage.Backup(tasksFile); err != nil {
+				logger.Warn("Cannot back up tasks for undo: %v", err)
+			}
+		}
+		if *appendOnly {
+			err = storage.AppendEvents(eventsFile, storage.DiffEvents(originalTasks, resultTasks))
+		} else if lock != nil {
+			err = dataStore.SaveLocked(lock, tasksFile, resultTasks)
+		} else {
+			err = dataStore.Save(tasksFile, resultTasks)
+		}
 		if err != nil {
 			logger.Error("Failed to save tasks: %v", err)
-			return 1
+			return exitError
 		}
 		logger.Info("Tasks saved successfully, total tasks: %d", len(resultTasks))
 	}
 
-	return 0
+	return exitOK
+}
+
+// parseLogLevel maps a level name (debug, info, warn or error, ignoring
+// case) to its logger.LogLevel.
+// Returns an error if the name is not a known level.
+func parseLogLevel(name string) (logger.LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return logger.LevelDebug, nil
+	case "info":
+		return logger.LevelInfo, nil
+	case "warn", "warning":
+		return logger.LevelWarn, nil
+	case "error":
+		return logger.LevelError, nil
+	default:
+		return defaultConsoleLevel, fmt.Errorf("unknown log level '%s', expected debug, info, warn or error", name)
+	}
+}
+
+// loadTasks loads the current tasks from the tasks file, or by replaying
+// the event log with --append-only.
+func loadTasks(appendOnly bool) ([]todo.Task, error) {
+	if appendOnly {
+		events, err := storage.LoadEvents(storage.EventsPath(tasksFile))
+		return storage.ReplayEvents(events), err
+	}
+	return dataStore.Load(tasksFile)
+}
+
+// resolveDataStore returns the store for the data file format: the
+// --data-format flag value if set, otherwise the TODO_DATA_FORMAT
+// environment variable, otherwise json.
+// Returns an error if the format is unknown.
+func resolveDataStore(flagValue string) (storage.Store, error) {
+	format := flagValue
+	if format == "" {
+		format = os.Getenv(dataFormatEnv)
+	}
+	if format == "" {
+		format = "json"
+	}
+	return storage.NewStore(strings.ToLower(format))
+}
+
+// resolveTasksFile returns the data file path: the --file flag value if set,
+// otherwise the TODO_FILE environment variable, otherwise defaultTasksFile
+// with its extension replaced by the data format, e.g. tasks.csv.
+// Returns an error if the format-derived file doesn't exist yet while
+// defaultTasksFile does, so switching formats never silently starts
+// from an empty list.
+func resolveTasksFile(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if env := os.Getenv(tasksFileEnv); env != "" {
+		return env, nil
+	}
+	path := strings.TrimSuffix(defaultTasksFile, filepath.Ext(defaultTasksFile)) + "." + dataStore.Format
+	if path == defaultTasksFile {
+		return path, nil
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err := os.Stat(defaultTasksFile); err == nil {
+			return "", fmt.Errorf("%s does not exist but %s does: run without --data-format to keep using it, or convert it first with export --format=%s --out=%s",
+				path, defaultTasksFile, dataStore.Format, path)
+		}
+	}
+	return path, nil
+}
+
+// renumberTasks makes task IDs contiguous with todo.Renumber
+// and logs every ID that changed.
+func renumberTasks(tasks []todo.Task) []todo.Task {
+	renumbered := todo.Renumber(tasks)
+	for i := range tasks {
+		if tasks[i].ID != renumbered[i].ID {
+			logger.Info("Renumbered task %d to %d", tasks[i].ID, renumbered[i].ID)
+		}
+	}
+	return renumbered
+}
+
+// exitCode maps a command error to the process exit code.
+// Usage errors return exitUsage, all other errors return exitError.
+func exitCode(err error) int {
+	var usageErr *usageError
+	if errors.As(err, &usageErr) {
+		return exitUsage
+	}
+	return exitError
 }
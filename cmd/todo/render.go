@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+	"golang.org/x/term"
+)
+
+// fallbackWidth is the line width used when stdout is not a terminal.
+const fallbackWidth = 80
+
+// terminalWidth reports the width of the terminal attached to stdout,
+// or fallbackWidth when stdout is not a terminal.
+// It is a variable so tests can stub terminal detection.
+var terminalWidth = func() int {
+	fd := int(os.Stdout.Fd())
+	if term.IsTerminal(fd) {
+		if width, _, err := term.GetSize(fd); err == nil && width > 0 {
+			return width
+		}
+	}
+	return fallbackWidth
+}
+
+// stdoutIsTerminal reports whether stdout is attached to a terminal.
+// It is a variable so tests can stub terminal detection.
+var stdoutIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// GlyphSet holds the status markers printed in front of each task
+// and the marker appended to overdue tasks.
+// An empty Overdue falls back to the plain ASCII marker.
+type GlyphSet struct {
+	Done    string
+	Pending string
+	Overdue string
+}
+
+// defaultGlyphs are the markers used on a terminal unless overridden by flags.
+var defaultGlyphs = GlyphSet{Done: "[X]", Pending: "[ ]", Overdue: "⚠️ OVERDUE"}
+
+// plainGlyphs are ASCII-only markers used when stdout is piped,
+// so emojis don't get in the way of tools parsing the output.
+var plainGlyphs = GlyphSet{Done: "[X]", Pending: "[ ]", Overdue: "OVERDUE"}
+
+// outputGlyphs returns defaultGlyphs when stdout is a terminal
+// and plainGlyphs otherwise.
+func outputGlyphs() GlyphSet {
+	if stdoutIsTerminal() {
+		return defaultGlyphs
+	}
+	return plainGlyphs
+}
+
+// Status returns the marker matching the task's completion state.
+func (g GlyphSet) Status(task todo.Task) string {
+	if task.Done {
+		return g.Done
+	}
+	return g.Pending
+}
+
+// renderOptions controls how tasks are rendered as text lines.
+// Width limits the line length in runes, 0 disables truncation.
+// Full disables truncation regardless of Width, see addFullFlag.
+// Now is the time overdue and stale tasks are checked against; the zero
+// time marks no task as overdue.
+// MaxAge marks pending tasks older than it as stale, 0 marks none.
+type renderOptions struct {
+	Glyphs     GlyphSet
+	DoneSuffix string
+	Width      int
+	Full       bool
+	Now        time.Time
+	MaxAge     time.Duration
+}
+
+// addFullFlag registers the --full flag on a command that renders task lines.
+// Every such command honors it through renderOptions.Full.
+func addFullFlag(cmd *flag.FlagSet) *bool {
+	return cmd.Bool("full", false, "Print complete descriptions without truncation")
+}
+
+// fit truncates a rendered line to opts.Width unless opts.Full is set.
+func (opts renderOptions) fit(line string) string {
+	if opts.Full {
+		return line
+	}
+	return truncate(line, opts.Width)
+}
+
+// formatTaskLine renders a task as "<status> [ID:n] <description>",
+// followed by "(pinned)" for pinned tasks, "(priority: ...)" for low and
+// high priority tasks, "(ref: ...)" when the task has an external
+// reference, "(tags: ...)" for tagged tasks, the overdue glyph when it
+// is past its due date and "(stale)" when it is older than opts.MaxAge,
+// fitted to the line width by opts.fit.
+func formatTaskLine(task todo.Task, opts renderOptions) string {
+	line := fmt.Sprintf("%s [ID:%d] %s", opts.Glyphs.Status(task), task.ID, task.Label(opts.DoneSuffix))
+	if task.Pinned {
+		line += " (pinned)"
+	}
+	if task.Priority != "" && task.Priority != todo.PriorityMedium {
+		line += fmt.Sprintf(" (priority: %s)", task.Priority)
+	}
+	if task.Ref != "" {
+		line += fmt.Sprintf(" (ref: %s)", task.Ref)
+	}
+	if len(task.Tags) > 0 {
+		line += fmt.Sprintf(" (tags: %s)", strings.Join(task.Tags, ", "))
+	}
+	if todo.IsOverdue(task, opts.Now) {
+		marker := opts.Glyphs.Overdue
+		if marker == "" {
+			marker = plainGlyphs.Overdue
+		}
+		line += " " + marker
+	}
+	if todo.IsStale(task, opts.MaxAge, opts.Now) {
+		line += " (stale)"
+	}
+	return opts.fit(line)
+}
+
+// formatCompact renders tasks as a single summary line of IDs,
+// e.g. "Pending: 1,4,7  Done: 2,3". An empty group is shown as "-".
+// IDs keep the order of the task list.
+func formatCompact(tasks []todo.Task) string {
+	var pending, done []string
+	for _, task := range tasks {
+		if task.Done {
+			done = append(done, strconv.Itoa(task.ID))
+		} else {
+			pending = append(pending, strconv.Itoa(task.ID))
+		}
+	}
+	return fmt.Sprintf("Pending: %s  Done: %s", joinIDs(pending), joinIDs(done))
+}
+
+// formatIDsCSV renders the task IDs as one comma-separated line, e.g. "1,4,7".
+// An empty task list renders as an empty string.
+func formatIDsCSV(tasks []todo.Task) string {
+	ids := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, strconv.Itoa(task.ID))
+	}
+	return strings.Join(ids, ",")
+}
+
+// joinIDs joins IDs with commas, or returns "-" when there are none.
+func joinIDs(ids []string) string {
+	if len(ids) == 0 {
+		return "-"
+	}
+	return strings.Join(ids, ",")
+}
+
+// truncateCells returns a copy of tasks with Description and Ref cut to
+// at most maxCell runes, logging every task that was shortened.
+// A maxCell of 0 returns tasks unchanged.
+func truncateCells(tasks []todo.Task, maxCell int) []todo.Task {
+	if maxCell <= 0 {
+		return tasks
+	}
+	result := make([]todo.Task, len(tasks))
+	for i, task := range tasks {
+		description, ref := truncate(task.Description, maxCell), truncate(task.Ref, maxCell)
+		if description != task.Description || ref != task.Ref {
+			logger.Info("Truncated long fields of task %d to %d characters", task.ID, maxCell)
+		}
+		task.Description, task.Ref = description, ref
+		result[i] = task
+	}
+	return result
+}
+
+// truncate shortens s to at most width runes, marking the cut with "…".
+// A width of 0 or less returns s unchanged.
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
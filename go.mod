@@ -2,4 +2,10 @@ module todo-app
 
 go 1.23.0
 
-require github.com/ZeRg0912/logger v1.0.3
+require (
+	github.com/ZeRg0912/logger v1.0.3
+	golang.org/x/term v0.34.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require golang.org/x/sys v0.35.0 // indirect
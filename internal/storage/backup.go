@@ -0,0 +1,100 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// BackupPath returns the path of the undo backup that belongs to a data file.
+func BackupPath(dataPath string) string {
+	return dataPath + ".bak"
+}
+
+// Backup copies the data file to BackupPath(dataPath), replacing the
+// previous backup, so the next change can be undone with SwapBackup.
+// If the data file doesn't exist yet, an old backup is removed instead,
+// so undo can never restore a state older than the last change.
+// The caller must hold the lock for dataPath.
+// Returns an error if the file cannot be read or the backup cannot be written.
+func Backup(dataPath string) error {
+	backupPath := BackupPath(dataPath)
+
+	data, err := os.ReadFile(dataPath)
+	if os.IsNotExist(err) {
+		if err := os.Remove(backupPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot remove old backup %s: %w", backupPath, err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("cannot read %s for backup: %w", dataPath, err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(backupPath), filepath.Base(backupPath)+".tmp.*")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file for %s: %w", backupPath, err)
+	}
+	tmpPath := tmpFile.Name()
+
+	defer func() {
+		tmpFile.Close()
+		if _, err := os.Stat(tmpPath); err == nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		return fmt.Errorf("cannot write to temporary file %s: %w", tmpPath, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("cannot close temporary file %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, backupPath); err != nil {
+		return fmt.Errorf("cannot rename temporary file to %s: %w", backupPath, err)
+	}
+
+	logger.Debug("Backed up %s to %s", dataPath, backupPath)
+	return nil
+}
+
+// SwapBackup exchanges the data file with its backup, so the state before
+// the last change becomes current and the undone state becomes the backup;
+// swapping again redoes the change.
+// The caller must hold the lock for dataPath.
+// Returns false if there is no backup, or an error if renaming fails.
+func SwapBackup(dataPath string) (bool, error) {
+	backupPath := BackupPath(dataPath)
+	if _, err := os.Stat(backupPath); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("cannot check backup %s: %w", backupPath, err)
+	}
+
+	swapPath := dataPath + ".undo.tmp"
+	hasCurrent := true
+	if err := os.Rename(dataPath, swapPath); os.IsNotExist(err) {
+		hasCurrent = false
+	} else if err != nil {
+		return false, fmt.Errorf("cannot move %s aside: %w", dataPath, err)
+	}
+
+	if err := os.Rename(backupPath, dataPath); err != nil {
+		if hasCurrent {
+			os.Rename(swapPath, dataPath)
+		}
+		return false, fmt.Errorf("cannot restore backup %s: %w", backupPath, err)
+	}
+
+	if hasCurrent {
+		if err := os.Rename(swapPath, backupPath); err != nil {
+			return true, fmt.Errorf("cannot keep undone state as backup %s: %w", backupPath, err)
+		}
+	}
+
+	logger.Info("Swapped %s with backup %s", dataPath, backupPath)
+	return true, nil
+}
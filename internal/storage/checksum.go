@@ -0,0 +1,82 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// ErrChecksumMismatch is returned (wrapped) by VerifyChecksum when a file
+// no longer matches the hash in its sidecar.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ChecksumPath returns the path of the SHA-256 sidecar file for path.
+func ChecksumPath(path string) string {
+	return path + ".sha256"
+}
+
+// FileChecksum returns the hex-encoded SHA-256 hash of the file at path.
+func FileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// WriteChecksum hashes the file at path and writes the hash to its
+// sidecar at ChecksumPath(path), in the "<hash>  <file name>" format
+// of sha256sum, so the file can also be checked with `sha256sum -c`.
+func WriteChecksum(path string) error {
+	sum, err := FileChecksum(path)
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	if err := os.WriteFile(ChecksumPath(path), []byte(line), 0644); err != nil {
+		return fmt.Errorf("cannot write checksum for %s: %w", path, err)
+	}
+	logger.Info("Wrote checksum %s for %s", sum, path)
+	return nil
+}
+
+// VerifyChecksum checks the file at path against the hash in its sidecar.
+// Returns false if there is no sidecar, true if the file matches it,
+// and an error wrapping ErrChecksumMismatch if it doesn't.
+func VerifyChecksum(path string) (bool, error) {
+	data, err := os.ReadFile(ChecksumPath(path))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("cannot read checksum for %s: %w", path, err)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return false, fmt.Errorf("checksum file %s is empty", ChecksumPath(path))
+	}
+
+	sum, err := FileChecksum(path)
+	if err != nil {
+		return false, err
+	}
+	if !strings.EqualFold(fields[0], sum) {
+		return false, fmt.Errorf("%w: %s has hash %s, expected %s", ErrChecksumMismatch, path, sum, fields[0])
+	}
+	logger.Info("Verified checksum of %s", path)
+	return true, nil
+}
@@ -3,101 +3,324 @@
 package storage
 
 import (
+	"bufio"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 	"todo-app/internal/todo"
+	"unicode/utf8"
 
 	"github.com/ZeRg0912/logger"
 )
 
+// csvHeader lists the columns written by SaveCSV, in order.
+var csvHeader = []string{"ID", "Description", "Done", "Ref", "Pinned", "Priority", "DueDate", "CreatedAt", "CompletedAt", "Tags"}
+
 // LoadCSV reads tasks from a CSV file with logging support.
-// The CSV file should have a header row with columns: ID, Description, Done.
+// Like LoadJSON, reading never acquires the write lock.
+// The CSV file should have a header row with columns: ID, Description, Done,
+// and optionally Ref, Pinned, Priority, DueDate, CreatedAt, CompletedAt and Tags.
+// Columns are matched by header name, so files written before optional
+// columns were added still load.
+// A missing or empty Priority defaults to todo.PriorityMedium.
+// Timestamps are written in RFC 3339 format, an empty field means no time.
+// Tags are separated by semicolons, e.g. "work;urgent".
+// Records with malformed fields or non-positive IDs are logged and skipped.
 // Returns an empty task slice if the file has only a header or is empty.
 // Returns an error if file reading or CSV parsing fails.
 func LoadCSV(path string) ([]todo.Task, error) {
+	tasks, _, err := LoadCSVWithResult(path)
+	return tasks, err
+}
+
+// LoadCSVWithResult works like LoadCSV and additionally returns
+// an ImportResult describing imported and skipped records.
+func LoadCSVWithResult(path string) ([]todo.Task, ImportResult, error) {
+	return loadDelimited(path, ',', false)
+}
+
+// LoadCSVDelimited works like LoadCSVWithResult for files whose fields
+// are separated by comma instead of ',', e.g. ';' from spreadsheet tools.
+// With strict set, the first malformed or invalid record, e.g. one with an
+// unterminated quote, a different number of fields than the header or an
+// unknown priority, aborts the load with its error instead of being skipped.
+// Returns an error if comma is not a valid delimiter, see ParseDelimiter.
+func LoadCSVDelimited(path string, comma rune, strict bool) ([]todo.Task, ImportResult, error) {
+	if err := validDelimiter(comma); err != nil {
+		return nil, ImportResult{}, err
+	}
+	return loadDelimited(path, comma, strict)
+}
+
+// lineOffset returns the byte offset of the start of the n-th line (from 0)
+// of content counted from offset.
+func lineOffset(content string, offset, n int) int {
+	for ; n > 0; n-- {
+		end := strings.IndexByte(content[offset:], '\n')
+		if end < 0 {
+			return len(content)
+		}
+		offset += end + 1
+	}
+	return offset
+}
+
+// readLazyRecord parses a single line with LazyQuotes, which accepts quotes
+// that csv.Reader would reject, e.g. `"Say "hi"",false`.
+// Reports false if the line doesn't hold exactly one record.
+func readLazyRecord(line string, comma rune) ([]string, bool) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimRight(line, "\r\n")))
+	reader.Comma = comma
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	record, err := reader.Read()
+	if err != nil {
+		return nil, false
+	}
+	if _, err := reader.Read(); err != io.EOF {
+		return nil, false
+	}
+	return record, true
+}
+
+// ParseDelimiter parses a CSV delimiter given on the command line.
+// It must be a single character; "\t" is accepted for a tab.
+// Returns an error for multi-character delimiters and for characters
+// that cannot separate CSV fields, such as quotes or line breaks.
+func ParseDelimiter(s string) (rune, error) {
+	if s == `\t` {
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("delimiter must be a single character, got '%s'", s)
+	}
+	comma, _ := utf8.DecodeRuneInString(s)
+	if err := validDelimiter(comma); err != nil {
+		return 0, err
+	}
+	return comma, nil
+}
+
+// validDelimiter reports an error if comma cannot separate CSV fields.
+func validDelimiter(comma rune) error {
+	if comma == '"' || comma == '\r' || comma == '\n' || comma == utf8.RuneError {
+		return fmt.Errorf("invalid delimiter %q", comma)
+	}
+	return nil
+}
+
+// loadDelimited reads tasks from a delimiter-separated file such as CSV or TSV.
+// It implements LoadCSVWithResult, LoadCSVDelimited and LoadTSVWithResult.
+//
+// Malformed records and records with invalid values are skipped unless
+// strict is set, then the first of them aborts the load. A record that fails
+// to parse, e.g. because of an unterminated quote, would make csv.Reader
+// swallow or misalign every following line, so parsing resumes on the line
+// after the record's first line. That line alone is retried with
+// LazyQuotes first, so stray quotes inside a field don't lose the record.
+// Records with more or fewer fields than the header are read as they are;
+// missing optional columns are empty.
+func loadDelimited(path string, comma rune, strict bool) ([]todo.Task, ImportResult, error) {
+	var result ImportResult
+
 	file, err := os.Open(path)
 	if err != nil {
-		return nil, fmt.Errorf("cannot open file %s: %w", path, err)
+		return nil, result, fmt.Errorf("cannot open file %s: %w", path, err)
 	}
 	defer file.Close()
 
-	reader := csv.NewReader(file)
+	data, err := io.ReadAll(skipBOM(file))
+	if err != nil {
+		return nil, result, fmt.Errorf("cannot read file %s: %w", path, err)
+	}
+	content := string(data)
 
 	var tasks []todo.Task
-	lineNum := 0
-	skippedCount := 0
+	var columns map[string]int
+	addRecord := func(record []string, lineNum int) error {
+		if columns == nil {
+			columns = csvColumns(record)
+			return nil
+		}
+		task, err := parseCSVRecord(record, columns)
+		if err != nil && strict {
+			return fmt.Errorf("invalid record at line %d in %s: %w", lineNum, path, err)
+		} else if err != nil {
+			result.skip("Skipping record at line %d: %v", lineNum, err)
+			return nil
+		}
+		tasks = append(tasks, task)
+		return nil
+	}
 
-	for {
-		record, err := reader.Read()
-		if err != nil {
+	// offset is where parsing (re)starts, firstLine the number of lines before it.
+	// In strict mode parsing never restarts, so error line numbers stay exact.
+	offset, firstLine := 0, 0
+	for offset < len(content) {
+		reader := csv.NewReader(strings.NewReader(content[offset:]))
+		reader.Comma = comma
+		if !strict {
+			reader.FieldsPerRecord = -1
+		}
+
+		for {
+			record, err := reader.Read()
 			if err == io.EOF {
+				offset = len(content)
 				break
 			}
-			skippedCount++
-			logger.Warn("CSV read error at line %d: %v", lineNum+1, err)
-			continue
+			var parseErr *csv.ParseError
+			if err != nil && (strict || !errors.As(err, &parseErr)) {
+				return nil, result, fmt.Errorf("CSV read error in %s: %w", path, err)
+			}
+			if err != nil {
+				lineNum := firstLine + parseErr.StartLine
+				lineStart := lineOffset(content, offset, parseErr.StartLine-1)
+				line := content[lineStart:]
+				if end := strings.IndexByte(line, '\n'); end >= 0 {
+					line = line[:end+1]
+				}
+				// Only reached in lenient mode, where addRecord never fails
+				if lazy, ok := readLazyRecord(line, comma); ok {
+					logger.Debug("Read record at line %d with lazy quotes after: %v", lineNum, parseErr.Err)
+					addRecord(lazy, lineNum)
+				} else {
+					result.skip("CSV read error at line %d: %v", lineNum, parseErr.Err)
+				}
+				offset = lineStart + len(line)
+				firstLine = lineNum
+				break
+			}
+
+			line, _ := reader.FieldPos(0)
+			if err := addRecord(record, firstLine+line); err != nil {
+				return nil, result, err
+			}
 		}
+	}
+
+	result.Imported = len(tasks)
+	if result.Skipped > 0 {
+		logger.Info("Loaded %d tasks from %s, skipped %d invalid records", len(tasks), path, result.Skipped)
+	} else {
+		logger.Info("Successfully loaded %d tasks from %s", len(tasks), path)
+	}
 
-		lineNum++
+	return tasks, result, nil
+}
 
-		if lineNum == 1 {
-			continue
-		}
+// parseCSVRecord converts a data record into a task, looking up fields
+// by the header columns from csvColumns.
+// Returns an error describing the first invalid field.
+func parseCSVRecord(record []string, columns map[string]int) (todo.Task, error) {
+	if len(record) < 3 {
+		return todo.Task{}, fmt.Errorf("expected 3 fields, got %d", len(record))
+	}
 
-		if len(record) < 3 {
-			skippedCount++
-			logger.Warn("Skipping record at line %d: expected 3 fields, got %d", lineNum, len(record))
-			continue
-		}
+	idField := csvField(record, columns, "id")
+	id, err := strconv.Atoi(idField)
+	if err != nil {
+		return todo.Task{}, fmt.Errorf("invalid ID format '%s'", idField)
+	}
+	if err := todo.ValidateID(id); err != nil {
+		return todo.Task{}, err
+	}
 
-		id, err := strconv.Atoi(strings.TrimSpace(record[0]))
-		if err != nil {
-			skippedCount++
-			logger.Warn("Skipping record at line %d: invalid ID format '%s'", lineNum, record[0])
-			continue
-		}
+	doneField := csvField(record, columns, "done")
+	done, err := strconv.ParseBool(doneField)
+	if err != nil {
+		return todo.Task{}, fmt.Errorf("invalid Done format '%s'", doneField)
+	}
 
-		done, err := strconv.ParseBool(strings.TrimSpace(record[2]))
+	pinned := false
+	if pinnedField := csvField(record, columns, "pinned"); pinnedField != "" {
+		pinned, err = strconv.ParseBool(pinnedField)
 		if err != nil {
-			skippedCount++
-			logger.Warn("Skipping record at line %d: invalid Done format '%s'", lineNum, record[2])
-			continue
+			return todo.Task{}, fmt.Errorf("invalid Pinned format '%s'", pinnedField)
 		}
+	}
 
-		task := todo.Task{
-			ID:          id,
-			Description: strings.TrimSpace(record[1]),
-			Done:        done,
+	priority := todo.PriorityMedium
+	if priorityField := csvField(record, columns, "priority"); priorityField != "" {
+		if err := todo.ValidatePriority(priorityField); err != nil {
+			return todo.Task{}, err
 		}
-		tasks = append(tasks, task)
+		priority = priorityField
 	}
 
-	if skippedCount > 0 {
-		logger.Info("Loaded %d tasks from CSV, skipped %d invalid records", len(tasks), skippedCount)
-	} else {
-		logger.Info("Successfully loaded %d tasks from CSV", len(tasks))
+	dueDate, err := parseCSVTime(csvField(record, columns, "duedate"))
+	if err != nil {
+		return todo.Task{}, fmt.Errorf("invalid DueDate format '%s'", csvField(record, columns, "duedate"))
+	}
+	createdAt, err := parseCSVTime(csvField(record, columns, "createdat"))
+	if err != nil {
+		return todo.Task{}, fmt.Errorf("invalid CreatedAt format '%s'", csvField(record, columns, "createdat"))
+	}
+	completedAt, err := parseCSVTime(csvField(record, columns, "completedat"))
+	if err != nil {
+		return todo.Task{}, fmt.Errorf("invalid CompletedAt format '%s'", csvField(record, columns, "completedat"))
 	}
 
-	return tasks, nil
+	task := todo.Task{
+		ID:          id,
+		Description: csvField(record, columns, "description"),
+		Done:        done,
+		Ref:         csvField(record, columns, "ref"),
+		Pinned:      pinned,
+		Priority:    priority,
+		DueDate:     dueDate,
+		CompletedAt: completedAt,
+		Tags:        todo.NormalizeTags([]string{csvField(record, columns, "tags")}),
+	}
+	if createdAt != nil {
+		task.CreatedAt = *createdAt
+	}
+	return task, nil
 }
 
 // SaveCSV writes tasks to a CSV file with a header row and logging.
+// Encoding is shared with SaveCSVStream.
 // Uses atomic write (temp file + rename) to protect data from corruption.
 // Uses file locking to prevent concurrent access conflicts.
-// The CSV format includes columns: ID, Description, Done.
+// The CSV format includes columns: ID, Description, Done, Ref, Pinned, Priority,
+// DueDate, CreatedAt, CompletedAt, Tags.
 // Returns an error if file creation or CSV writing fails.
 func SaveCSV(path string, tasks []todo.Task) error {
+	return saveDelimited(path, tasks, ',')
+}
+
+// SaveCSVDelimited works like SaveCSV but separates fields by comma
+// instead of ','.
+// Returns an error if comma is not a valid delimiter, see ParseDelimiter.
+func SaveCSVDelimited(path string, tasks []todo.Task, comma rune) error {
+	if err := validDelimiter(comma); err != nil {
+		return err
+	}
+	return saveDelimited(path, tasks, comma)
+}
+
+// saveDelimited writes tasks to a delimiter-separated file such as CSV or TSV.
+// It implements SaveCSV and SaveTSV.
+func saveDelimited(path string, tasks []todo.Task, comma rune) error {
 	lock, err := AcquireLock(path)
 	if err != nil {
 		return fmt.Errorf("cannot acquire lock for %s: %w", path, err)
 	}
 	defer lock.Release()
 
+	return writeDelimited(path, tasks, comma)
+}
+
+// writeDelimited writes tasks like saveDelimited, but without locking.
+// The caller must already hold the lock for path.
+func writeDelimited(path string, tasks []todo.Task, comma rune) error {
 	dir := filepath.Dir(path)
 	if dir == "." {
 		absPath, err := filepath.Abs(path)
@@ -119,12 +342,64 @@ func SaveCSV(path string, tasks []todo.Task) error {
 		}
 	}()
 
-	writer := csv.NewWriter(tmpFile)
+	successCount, err := writeCSV(tmpFile, tasks, true, comma)
+	if err != nil {
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("cannot sync temporary file %s: %w", tmpPath, err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("cannot close temporary file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("cannot rename temporary file to %s: %w", path, err)
+	}
+
+	logger.Info("Successfully exported %d/%d tasks to file: %s", successCount, len(tasks), path)
+	return nil
+}
 
-	header := []string{"ID", "Description", "Done"}
-	err = writer.Write(header)
+// SaveCSVStream writes tasks as CSV with a header row directly to w.
+// Unlike SaveCSV it uses no temporary file or lock, so it can write
+// to pipes, stdout, or compressed streams.
+// Returns an error if CSV writing fails.
+func SaveCSVStream(w io.Writer, tasks []todo.Task) error {
+	successCount, err := writeCSV(w, tasks, true, ',')
 	if err != nil {
-		return fmt.Errorf("cannot write CSV header: %w", err)
+		return err
+	}
+	logger.Info("Successfully streamed %d/%d tasks as CSV", successCount, len(tasks))
+	return nil
+}
+
+// SaveCSVStreamNoHeader writes tasks as CSV records without a header row to w,
+// e.g. for appending to an existing CSV file.
+// Returns an error if CSV writing fails.
+func SaveCSVStreamNoHeader(w io.Writer, tasks []todo.Task) error {
+	successCount, err := writeCSV(w, tasks, false, ',')
+	if err != nil {
+		return err
+	}
+	logger.Info("Successfully streamed %d/%d tasks as CSV without header", successCount, len(tasks))
+	return nil
+}
+
+// writeCSV encodes tasks as records separated by comma into w,
+// preceded by a header row when header is true.
+// Tasks that fail to encode are logged and skipped.
+// Returns the number of tasks written successfully.
+func writeCSV(w io.Writer, tasks []todo.Task, header bool, comma rune) (int, error) {
+	writer := csv.NewWriter(w)
+	writer.Comma = comma
+
+	if header {
+		if err := writer.Write(csvHeader); err != nil {
+			return 0, fmt.Errorf("cannot write CSV header: %w", err)
+		}
 	}
 
 	successCount := 0
@@ -133,6 +408,13 @@ func SaveCSV(path string, tasks []todo.Task) error {
 			strconv.Itoa(task.ID),
 			task.Description,
 			strconv.FormatBool(task.Done),
+			task.Ref,
+			strconv.FormatBool(task.Pinned),
+			task.Priority,
+			formatCSVTime(task.DueDate),
+			formatCSVTime(&task.CreatedAt),
+			formatCSVTime(task.CompletedAt),
+			strings.Join(task.Tags, todo.TagSeparator),
 		}
 		err := writer.Write(record)
 		if err != nil {
@@ -144,21 +426,65 @@ func SaveCSV(path string, tasks []todo.Task) error {
 
 	writer.Flush()
 	if err := writer.Error(); err != nil {
-		return fmt.Errorf("CSV flush error: %w", err)
+		return successCount, fmt.Errorf("CSV flush error: %w", err)
 	}
+	return successCount, nil
+}
 
-	if err := tmpFile.Sync(); err != nil {
-		return fmt.Errorf("cannot sync temporary CSV file %s: %w", tmpPath, err)
+// formatCSVTime renders a timestamp for a CSV field in RFC 3339 format
+// with fractional seconds, so times compare the same after a round trip as
+// in JSON, or an empty string for a nil or zero time.
+func formatCSVTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
 	}
+	return t.Format(time.RFC3339Nano)
+}
 
-	if err := tmpFile.Close(); err != nil {
-		return fmt.Errorf("cannot close temporary CSV file %s: %w", tmpPath, err)
+// parseCSVTime parses an RFC 3339 CSV field written by formatCSVTime,
+// with or without fractional seconds.
+// Returns nil for an empty field, or an error if the field is malformed.
+func parseCSVTime(field string) (*time.Time, error) {
+	if field == "" {
+		return nil, nil
 	}
+	t, err := time.Parse(time.RFC3339, field)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
 
-	if err := os.Rename(tmpPath, path); err != nil {
-		return fmt.Errorf("cannot rename temporary file to %s: %w", path, err)
+// csvColumns maps lower-cased header names to their column index.
+// Required columns missing from the header fall back to the original
+// fixed layout ID, Description, Done, so older files keep loading.
+func csvColumns(header []string) map[string]int {
+	columns := map[string]int{"id": 0, "description": 1, "done": 2}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
 	}
+	return columns
+}
 
-	logger.Info("Successfully exported %d/%d tasks to CSV file: %s", successCount, len(tasks), path)
-	return nil
+// csvField returns the trimmed value of the named column,
+// or an empty string if the record has no such column.
+func csvField(record []string, columns map[string]int, name string) string {
+	index, ok := columns[name]
+	if !ok || index >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[index])
+}
+
+// skipBOM wraps r in a buffered reader that drops a leading UTF-8 BOM.
+// Spreadsheet tools like Excel prepend a BOM to exported CSV files,
+// which would otherwise end up in the first header field.
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	bom, err := br.Peek(3)
+	if err == nil && bom[0] == 0xEF && bom[1] == 0xBB && bom[2] == 0xBF {
+		br.Discard(3)
+		logger.Debug("Removed UTF-8 BOM from CSV file")
+	}
+	return br
 }
@@ -0,0 +1,238 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// EventType identifies the kind of change recorded in the event log.
+type EventType string
+
+const (
+	EventCreate   EventType = "create"
+	EventComplete EventType = "complete"
+	EventUpdate   EventType = "update"
+	EventDelete   EventType = "delete"
+	EventReorder  EventType = "reorder"
+)
+
+// Event is a single entry of the append-only task log.
+// Task holds the full task state after the change
+// (or the last known state for delete events).
+// Reorder events leave Task empty and list all task IDs in their new order.
+type Event struct {
+	Type  EventType `json:"type"`
+	Time  time.Time `json:"time"`
+	Task  todo.Task `json:"task"`
+	Order []int     `json:"order,omitempty"`
+}
+
+// EventsPath returns the path of the event log that belongs to a data file,
+// e.g. "tasks.json" becomes "tasks.events.jsonl".
+func EventsPath(dataPath string) string {
+	return strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + ".events.jsonl"
+}
+
+// DiffEvents compares two task lists and returns the events that turn
+// before into after: creates for new IDs, deletes for missing IDs,
+// completes for tasks that became done, and updates for any other change.
+// If replaying those would leave the tasks in a different order than after,
+// e.g. after move, a final reorder event records the order of after.
+func DiffEvents(before, after []todo.Task) []Event {
+	now := time.Now()
+	var events []Event
+
+	afterByID := make(map[int]todo.Task, len(after))
+	for _, task := range after {
+		afterByID[task.ID] = task
+	}
+	beforeByID := make(map[int]todo.Task, len(before))
+	for _, task := range before {
+		beforeByID[task.ID] = task
+		if _, ok := afterByID[task.ID]; !ok {
+			events = append(events, Event{Type: EventDelete, Time: now, Task: task})
+		}
+	}
+
+	for _, task := range after {
+		old, ok := beforeByID[task.ID]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: EventCreate, Time: now, Task: task})
+		case !old.Done && task.Done && old.Description == task.Description:
+			events = append(events, Event{Type: EventComplete, Time: now, Task: task})
+		case !reflect.DeepEqual(old, task):
+			events = append(events, Event{Type: EventUpdate, Time: now, Task: task})
+		}
+	}
+
+	// Replay keeps the remaining tasks in place and appends created ones
+	var replayed, order []int
+	for _, task := range before {
+		if _, ok := afterByID[task.ID]; ok {
+			replayed = append(replayed, task.ID)
+		}
+	}
+	for _, task := range after {
+		order = append(order, task.ID)
+		if _, ok := beforeByID[task.ID]; !ok {
+			replayed = append(replayed, task.ID)
+		}
+	}
+	if !reflect.DeepEqual(replayed, order) {
+		events = append(events, Event{Type: EventReorder, Time: now, Order: order})
+	}
+	return events
+}
+
+// ReplayEvents rebuilds the current task list by applying events in order.
+// Unknown event types and events for unknown tasks are logged and skipped.
+func ReplayEvents(events []Event) []todo.Task {
+	tasks := []todo.Task{}
+	for i, event := range events {
+		index := -1
+		for j := range tasks {
+			if tasks[j].ID == event.Task.ID {
+				index = j
+				break
+			}
+		}
+
+		switch event.Type {
+		case EventCreate:
+			if index != -1 {
+				tasks[index] = event.Task
+				continue
+			}
+			tasks = append(tasks, event.Task)
+		case EventComplete, EventUpdate:
+			if index == -1 {
+				logger.Warn("Skipping event %d: task ID %d not found", i+1, event.Task.ID)
+				continue
+			}
+			tasks[index] = event.Task
+		case EventDelete:
+			if index == -1 {
+				logger.Warn("Skipping event %d: task ID %d not found", i+1, event.Task.ID)
+				continue
+			}
+			tasks = append(tasks[:index], tasks[index+1:]...)
+		case EventReorder:
+			tasks = reorderTasks(tasks, event.Order)
+		default:
+			logger.Warn("Skipping event %d: unknown type '%s'", i+1, event.Type)
+		}
+	}
+	return tasks
+}
+
+// reorderTasks sorts tasks into the given ID order.
+// Tasks whose ID is not in order keep their relative order after the others.
+func reorderTasks(tasks []todo.Task, order []int) []todo.Task {
+	position := make(map[int]int, len(order))
+	for i, id := range order {
+		if _, ok := position[id]; !ok {
+			position[id] = i
+		}
+	}
+	rank := func(task todo.Task) int {
+		if i, ok := position[task.ID]; ok {
+			return i
+		}
+		return len(order)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return rank(tasks[i]) < rank(tasks[j]) })
+	return tasks
+}
+
+// LoadEvents reads all events from a JSON lines event log.
+// Returns an empty slice if the file doesn't exist.
+// Malformed lines are logged and skipped.
+// Returns an error if the file cannot be read.
+func LoadEvents(path string) ([]Event, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		logger.Info("Event log %s does not exist, returning empty event list", path)
+		return []Event{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot open event log %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			logger.Warn("Skipping event log line %d: %v", lineNum, err)
+			continue
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read event log %s: %w", path, err)
+	}
+
+	logger.Info("Successfully loaded %d events from %s", len(events), path)
+	return events, nil
+}
+
+// AppendEvents appends events to a JSON lines event log, one event per line.
+// Existing entries are never rewritten.
+// Uses file locking to prevent concurrent access conflicts.
+// Returns an error if the file cannot be opened or written.
+func AppendEvents(path string, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	lock, err := AcquireLock(path)
+	if err != nil {
+		return fmt.Errorf("cannot acquire lock for %s: %w", path, err)
+	}
+	defer lock.Release()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open event log %s: %w", path, err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("cannot marshal event for task ID %d: %w", event.Task.ID, err)
+		}
+		writer.Write(data)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("cannot write to event log %s: %w", path, err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("cannot sync event log %s: %w", path, err)
+	}
+
+	logger.Info("Appended %d events to %s", len(events), path)
+	return nil
+}
@@ -0,0 +1,137 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// htmlTimeLayout is how due, created and completed times are shown.
+const htmlTimeLayout = "2006-01-02 15:04"
+
+// htmlTemplate renders a self-contained task table with inline CSS.
+// html/template escapes every value, so descriptions cannot inject markup.
+// Missing timestamps are left empty, overdue due dates are highlighted.
+var htmlTemplate = template.Must(template.New("tasks").Funcs(template.FuncMap{
+	"isURL": func(ref string) bool {
+		lower := strings.ToLower(ref)
+		return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+	},
+	"formatTime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format(htmlTimeLayout)
+	},
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Tasks</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 0.4em 0.8em; text-align: left; }
+th { background: #f0f0f0; }
+tr.pinned td { background: #fffbe6; }
+tr.done td { color: #888; }
+tr.done td.description { text-decoration: line-through; }
+td.priority-high { color: #c00; font-weight: bold; }
+td.overdue { color: #c00; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Tasks ({{len .}})</h1>
+<table>
+<tr><th>ID</th><th>Status</th><th>Priority</th><th>Description</th><th>Tags</th><th>Due</th><th>Pinned</th><th>Created</th><th>Completed</th><th>Ref</th></tr>
+{{- range .}}
+<tr class="{{if .Done}}done{{else}}pending{{end}}{{if .Pinned}} pinned{{end}}"><td>{{.ID}}</td><td>{{if .Done}}done{{else}}pending{{end}}</td><td class="priority-{{.Priority}}">{{.Priority}}</td><td class="description">{{.Description}}</td><td>{{join .Tags ", "}}</td><td{{if .Overdue}} class="overdue"{{end}}>{{with .DueDate}}{{formatTime .}}{{end}}{{if .Overdue}} (overdue){{end}}</td><td>{{if .Pinned}}yes{{end}}</td><td>{{formatTime .CreatedAt}}</td><td>{{with .CompletedAt}}{{formatTime .}}{{end}}</td><td>{{if isURL .Ref}}<a href="{{.Ref}}">{{.Ref}}</a>{{else}}{{.Ref}}{{end}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// htmlRow is a task as rendered by htmlTemplate, with its overdue state
+// decided once at export time.
+type htmlRow struct {
+	todo.Task
+	Overdue bool
+}
+
+// SaveHTML writes tasks to a self-contained HTML page with a styled table.
+// HTML is an export-only format, there is no matching loader.
+// Uses atomic write (temp file + rename) to protect data from corruption.
+// Uses file locking to prevent concurrent access conflicts.
+// Returns an error if file creation or rendering fails.
+func SaveHTML(path string, tasks []todo.Task) error {
+	lock, err := AcquireLock(path)
+	if err != nil {
+		return fmt.Errorf("cannot acquire lock for %s: %w", path, err)
+	}
+	defer lock.Release()
+
+	dir := filepath.Dir(path)
+	if dir == "." {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("cannot get absolute path for %s: %w", path, err)
+		}
+		dir = filepath.Dir(absPath)
+	}
+	tmpFile, err := os.CreateTemp(dir, filepath.Base(path)+".tmp.*")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file for %s: %w", path, err)
+	}
+	tmpPath := tmpFile.Name()
+
+	defer func() {
+		tmpFile.Close()
+		if _, err := os.Stat(tmpPath); err == nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if err := writeHTML(tmpFile, tasks); err != nil {
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("cannot sync temporary HTML file %s: %w", tmpPath, err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("cannot close temporary HTML file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("cannot rename temporary file to %s: %w", path, err)
+	}
+
+	logger.Info("Successfully exported %d tasks to HTML file: %s", len(tasks), path)
+	return nil
+}
+
+// writeHTML renders tasks as an HTML page into w.
+// Pending tasks past their due date at the time of the export are marked overdue.
+func writeHTML(w io.Writer, tasks []todo.Task) error {
+	now := time.Now()
+	rows := make([]htmlRow, len(tasks))
+	for i, task := range tasks {
+		rows[i] = htmlRow{Task: task, Overdue: todo.IsOverdue(task, now)}
+	}
+	if err := htmlTemplate.Execute(w, rows); err != nil {
+		return fmt.Errorf("cannot render HTML: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"fmt"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// ImportResult summarizes an import for callers that report it themselves.
+// Imported is the number of tasks read successfully, Skipped the number
+// of invalid records, and Errors holds one message per skipped record.
+type ImportResult struct {
+	Imported int      `json:"imported"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// skip records a skipped record and logs the reason as a warning.
+func (r *ImportResult) skip(format string, a ...any) {
+	message := fmt.Sprintf(format, a...)
+	r.Skipped++
+	r.Errors = append(r.Errors, message)
+	logger.Warn("%s", message)
+}
@@ -3,8 +3,10 @@
 package storage
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"todo-app/internal/todo"
@@ -12,17 +14,26 @@ import (
 	"github.com/ZeRg0912/logger"
 )
 
+// JSONStreamThreshold is the task count above which JSON output is streamed
+// task by task instead of being marshaled into a single buffer.
+const JSONStreamThreshold = 1000
+
 // LoadJSON reads tasks from a JSON file with logging.
+// Reading never acquires the write lock, so read-only commands
+// keep working while a stale .lock file is present.
 // Returns an empty task slice if the file doesn't exist or is empty.
-// Returns an error if file reading or JSON parsing fails.
+// Returns an error if path is a directory, or if file reading or JSON parsing fails.
 func LoadJSON(path string) ([]todo.Task, error) {
-	_, err := os.Stat(path)
+	info, err := os.Stat(path)
 	if os.IsNotExist(err) {
 		logger.Info("JSON file %s does not exist, returning empty task list", path)
 		return []todo.Task{}, nil
 	} else if err != nil {
 		return nil, fmt.Errorf("unexpected error accessing path %s: %w", path, err)
 	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("expected a file but %s is a directory", path)
+	}
 
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -50,6 +61,8 @@ func LoadJSON(path string) ([]todo.Task, error) {
 }
 
 // SaveJSON writes tasks to a JSON file with indentation and logging.
+// Lists larger than JSONStreamThreshold are streamed without indentation
+// to avoid holding the whole marshaled buffer in memory.
 // Uses atomic write (temp file + rename) to protect data from corruption.
 // Uses file locking to prevent concurrent access conflicts.
 // Returns an error if JSON marshaling or file writing fails.
@@ -60,11 +73,14 @@ func SaveJSON(path string, tasks []todo.Task) error {
 	}
 	defer lock.Release()
 
-	data, err := json.MarshalIndent(tasks, "", "  ")
-	if err != nil {
-		return fmt.Errorf("cannot marshal tasks to JSON: %w", err)
-	}
+	return WriteJSON(path, tasks)
+}
 
+// WriteJSON writes tasks to a JSON file like SaveJSON, but without locking.
+// The caller must already hold the lock for path, e.g. to keep it across
+// a whole load, modify, save cycle.
+// Returns an error if JSON marshaling or file writing fails.
+func WriteJSON(path string, tasks []todo.Task) error {
 	dir := filepath.Dir(path)
 	if dir == "." {
 		absPath, err := filepath.Abs(path)
@@ -86,8 +102,18 @@ func SaveJSON(path string, tasks []todo.Task) error {
 		}
 	}()
 
-	if _, err := tmpFile.Write(data); err != nil {
-		return fmt.Errorf("cannot write to temporary file %s: %w", tmpPath, err)
+	if len(tasks) > JSONStreamThreshold {
+		if err := WriteJSONStream(tmpFile, tasks); err != nil {
+			return fmt.Errorf("cannot write to temporary file %s: %w", tmpPath, err)
+		}
+	} else {
+		data, err := json.MarshalIndent(tasks, "", "  ")
+		if err != nil {
+			return fmt.Errorf("cannot marshal tasks to JSON: %w", err)
+		}
+		if _, err := tmpFile.Write(data); err != nil {
+			return fmt.Errorf("cannot write to temporary file %s: %w", tmpPath, err)
+		}
 	}
 
 	if err := tmpFile.Sync(); err != nil {
@@ -105,3 +131,75 @@ func SaveJSON(path string, tasks []todo.Task) error {
 	logger.Info("Successfully saved %d tasks to JSON file: %s", len(tasks), path)
 	return nil
 }
+
+// writeJSONFile writes v as indented JSON to path, through a temporary
+// file in the same directory that is renamed over path, so readers never
+// see a partial file. It doesn't lock, the caller is responsible for that.
+// Returns an error if marshaling or writing fails.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal to JSON: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp.*")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file for %s: %w", path, err)
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		tmpFile.Close()
+		if _, err := os.Stat(tmpPath); err == nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		return fmt.Errorf("cannot write to temporary file %s: %w", tmpPath, err)
+	}
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("cannot sync temporary file %s: %w", tmpPath, err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("cannot close temporary file %s: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("cannot rename temporary file to %s: %w", path, err)
+	}
+	return nil
+}
+
+// WriteJSONStream writes tasks to w as a compact JSON array, encoding one
+// task at a time so the whole list is never held in a single buffer.
+// The output is identical to json.Marshal of the same slice.
+// Returns an error if encoding or writing fails.
+func WriteJSONStream(w io.Writer, tasks []todo.Task) error {
+	bw := bufio.NewWriter(w)
+
+	if err := bw.WriteByte('['); err != nil {
+		return fmt.Errorf("cannot write JSON stream: %w", err)
+	}
+	for i, task := range tasks {
+		if i > 0 {
+			if err := bw.WriteByte(','); err != nil {
+				return fmt.Errorf("cannot write JSON stream: %w", err)
+			}
+		}
+		data, err := json.Marshal(task)
+		if err != nil {
+			return fmt.Errorf("cannot marshal task ID %d to JSON: %w", task.ID, err)
+		}
+		if _, err := bw.Write(data); err != nil {
+			return fmt.Errorf("cannot write JSON stream: %w", err)
+		}
+	}
+	if err := bw.WriteByte(']'); err != nil {
+		return fmt.Errorf("cannot write JSON stream: %w", err)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot flush JSON stream: %w", err)
+	}
+	logger.Debug("Streamed %d tasks as JSON", len(tasks))
+	return nil
+}
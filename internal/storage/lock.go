@@ -3,8 +3,14 @@
 package storage
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/ZeRg0912/logger"
@@ -15,30 +21,148 @@ const (
 	lockRetry   = 100 * time.Millisecond
 )
 
+// DefaultStaleLockTimeout is how old a lock file without a readable owner
+// PID must be before AcquireLock reclaims it, see SetStaleLockTimeout.
+const DefaultStaleLockTimeout = 30 * time.Second
+
+// staleLockTimeout is the current timeout, see SetStaleLockTimeout.
+var staleLockTimeout = DefaultStaleLockTimeout
+
+// SetStaleLockTimeout sets how old a lock file without a readable owner PID,
+// e.g. one left by a crash right after it was created, must be before
+// AcquireLock reclaims it. Locks whose owner process is known to be dead
+// are reclaimed regardless of their age.
+func SetStaleLockTimeout(timeout time.Duration) {
+	staleLockTimeout = timeout
+}
+
+// lockDir is the directory lock files are created in, see SetLockDir.
+// Empty means next to the file they guard.
+var lockDir string
+
+// SetLockDir places lock files in dir instead of next to the files they
+// guard, e.g. to keep synced folders free of .lock files.
+// Lock files in dir are named after a hash of the guarded file's absolute
+// path, so files with the same name in different directories don't share
+// a lock. An empty dir restores the default.
+func SetLockDir(dir string) {
+	lockDir = dir
+}
+
+// heldLocks maps the path of every lock file this process holds or waits
+// for to its *heldLock. An entry is deleted once no AcquireLock waits for
+// it and no FileLock holds it. Guarded by heldLocksMu.
+var (
+	heldLocksMu sync.Mutex
+	heldLocks   = make(map[string]*heldLock)
+)
+
+// heldLock is the in-process side of one lock file. The AcquireLock that
+// creates the lock file first takes the token, and the last Release gives
+// it back, so goroutines of this process exclude each other like processes.
+// count is the number of FileLocks sharing the lock file, see Reacquire,
+// and users the number of AcquireLock calls holding or waiting for it.
+type heldLock struct {
+	token    chan struct{}
+	count    int
+	users    int
+	lockFile *os.File
+}
+
 // FileLock represents a file lock for concurrent access protection.
 type FileLock struct {
-	lockFile *os.File
+	held     *heldLock
 	path     string
+	released bool
+}
+
+// LockPath returns the path of the lock file that guards path.
+func LockPath(path string) string {
+	if lockDir == "" {
+		return path + ".lock"
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	sum := sha256.Sum256([]byte(absPath))
+	return filepath.Join(lockDir, filepath.Base(path)+"-"+hex.EncodeToString(sum[:8])+".lock")
+}
+
+// StaleLock reports whether the lock file for path exists and was left
+// behind by a process that is gone, by the same check AcquireLock uses to
+// reclaim it: the owner PID no longer runs, or there is no readable PID
+// and the file is older than the stale lock timeout.
+// Returns false if there is no lock file.
+func StaleLock(path string) (bool, error) {
+	lockPath := LockPath(path)
+	info, err := os.Stat(lockPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("cannot check lock for %s: %w", path, err)
+	}
+	data, err := os.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("cannot read lock for %s: %w", path, err)
+	}
+	_, stale := staleLockReason(info, data)
+	return stale, nil
 }
 
 // AcquireLock acquires an exclusive lock on a file.
+// The lock file is created at LockPath(path), creating the lock directory
+// set by SetLockDir if needed, and holds the PID of the current process.
+// The lock excludes other processes and other goroutines of this process:
+// while it is held, AcquireLock of the same path waits, even in the
+// goroutine holding it. Code that runs under a held lock and needs it
+// again reenters it explicitly with FileLock.Reacquire.
+// A lock left behind by a process that is no longer running is reclaimed
+// with a warning, see reclaimStaleLock.
 // Returns an error if the lock cannot be acquired within the timeout.
 func AcquireLock(path string) (*FileLock, error) {
-	lockPath := path + ".lock"
+	lockPath := LockPath(path)
 	start := time.Now()
 
+	if lockDir != "" {
+		if err := os.MkdirAll(lockDir, 0755); err != nil {
+			return nil, fmt.Errorf("cannot create lock directory %s: %w", lockDir, err)
+		}
+	}
+
+	held := useHeldLock(lockPath)
+	timer := time.NewTimer(lockTimeout)
+	defer timer.Stop()
+	select {
+	case held.token <- struct{}{}:
+	case <-timer.C:
+		unuseHeldLock(lockPath, held)
+		return nil, fmt.Errorf("cannot acquire lock for %s: timeout after %v", path, lockTimeout)
+	}
+
 	for {
 		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
 		if err == nil {
-			lock := &FileLock{
-				lockFile: file,
-				path:     lockPath,
+			if _, err := fmt.Fprintf(file, "%d\n", os.Getpid()); err != nil {
+				logger.Warn("Cannot write PID to lock file %s: %v", lockPath, err)
 			}
+			heldLocksMu.Lock()
+			held.count = 1
+			held.lockFile = file
+			heldLocksMu.Unlock()
 			logger.Debug("Acquired lock for %s", path)
-			return lock, nil
+			return &FileLock{held: held, path: lockPath}, nil
+		}
+
+		if reclaimStaleLock(lockPath) {
+			continue
 		}
 
 		if time.Since(start) > lockTimeout {
+			<-held.token
+			unuseHeldLock(lockPath, held)
 			return nil, fmt.Errorf("cannot acquire lock for %s: timeout after %v", path, lockTimeout)
 		}
 
@@ -46,16 +170,142 @@ func AcquireLock(path string) (*FileLock, error) {
 	}
 }
 
+// useHeldLock returns the heldLock for lockPath, adding one to heldLocks
+// if needed, and counts the caller as one of its users.
+func useHeldLock(lockPath string) *heldLock {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+
+	held, ok := heldLocks[lockPath]
+	if !ok {
+		held = &heldLock{token: make(chan struct{}, 1)}
+		heldLocks[lockPath] = held
+	}
+	held.users++
+	return held
+}
+
+// unuseHeldLock undoes useHeldLock, deleting the entry for lockPath
+// when it has no users left.
+func unuseHeldLock(lockPath string, held *heldLock) {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+
+	held.users--
+	if held.users == 0 {
+		delete(heldLocks, lockPath)
+	}
+}
+
+// Reacquire returns another FileLock for the lock fl holds, without
+// waiting, for code that takes the lock while its caller already holds it,
+// see Store.SaveLocked. This is the only way to reenter a lock.
+// The lock file is removed when the last FileLock sharing it is released.
+// Returns an error if fl was already released.
+func (fl *FileLock) Reacquire() (*FileLock, error) {
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+
+	if fl.released {
+		return nil, fmt.Errorf("cannot reacquire released lock %s", fl.path)
+	}
+	fl.held.count++
+	logger.Debug("Reentered lock %s, held %d times", fl.path, fl.held.count)
+	return &FileLock{held: fl.held, path: fl.path}, nil
+}
+
+// reclaimStaleLock removes the lock file at lockPath if its owner is gone:
+// the PID written by AcquireLock no longer runs, or there is no readable PID
+// and the file is older than the stale lock timeout.
+// The lock file is checked again right before removal, so a lock taken over
+// by another process in the meantime is left alone.
+// Returns true if the lock file was removed.
+func reclaimStaleLock(lockPath string) bool {
+	info, err := os.Stat(lockPath)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(lockPath)
+	if err != nil {
+		return false
+	}
+
+	reason, stale := staleLockReason(info, data)
+	if !stale {
+		return false
+	}
+
+	// The owner may have released the lock and another process taken it
+	// while the PID was checked; then the file is no longer the stale one
+	current, err := os.Stat(lockPath)
+	if err != nil || !os.SameFile(info, current) || !current.ModTime().Equal(info.ModTime()) {
+		return false
+	}
+	if currentData, err := os.ReadFile(lockPath); err != nil || string(currentData) != string(data) {
+		return false
+	}
+
+	logger.Warn("Reclaiming stale lock %s: %s", lockPath, reason)
+	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Cannot remove stale lock %s: %v", lockPath, err)
+		return false
+	}
+	return true
+}
+
+// staleLockReason decides whether a lock file with the given info and
+// content is stale: the PID it holds no longer runs, or there is no
+// readable PID and the file is older than the stale lock timeout.
+// A lock held by this process is never stale.
+// Returns the reason for a stale lock.
+func staleLockReason(info os.FileInfo, data []byte) (string, bool) {
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err == nil && pid > 0 {
+		if pid == os.Getpid() || processAlive(pid) {
+			return "", false
+		}
+		return fmt.Sprintf("owner process %d is not running", pid), true
+	}
+	age := time.Since(info.ModTime())
+	if age <= staleLockTimeout {
+		return "", false
+	}
+	return fmt.Sprintf("no owner PID and %s old", age.Round(time.Second)), true
+}
+
 // Release releases the file lock.
+// The lock file is only removed once every FileLock sharing it, see
+// Reacquire, is released; then a waiting AcquireLock can take it.
+// Releasing the same FileLock twice does nothing.
 func (fl *FileLock) Release() error {
-	if fl.lockFile != nil {
-		fl.lockFile.Close()
+	heldLocksMu.Lock()
+	if fl.released {
+		heldLocksMu.Unlock()
+		return nil
+	}
+	fl.released = true
+	fl.held.count--
+	if count := fl.held.count; count > 0 {
+		heldLocksMu.Unlock()
+		logger.Debug("Released reentered lock %s, still held %d times", fl.path, count)
+		return nil
 	}
-	if err := os.Remove(fl.path); err != nil && !os.IsNotExist(err) {
+	lockFile := fl.held.lockFile
+	fl.held.lockFile = nil
+	heldLocksMu.Unlock()
+
+	// The lock file is removed before the token is given back, so the next
+	// holder in this process doesn't find it still there
+	if lockFile != nil {
+		lockFile.Close()
+	}
+	err := os.Remove(fl.path)
+	<-fl.held.token
+	unuseHeldLock(fl.path, fl.held)
+	if err != nil && !os.IsNotExist(err) {
 		logger.Warn("Failed to remove lock file %s: %v", fl.path, err)
 		return fmt.Errorf("cannot release lock: %w", err)
 	}
 	logger.Debug("Released lock for %s", fl.path)
 	return nil
 }
-
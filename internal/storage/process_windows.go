@@ -0,0 +1,18 @@
+//go:build windows
+
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import "os"
+
+// processAlive reports whether a process with the given PID exists.
+// On Windows os.FindProcess opens the process and fails if it doesn't exist.
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
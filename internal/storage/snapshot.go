@@ -0,0 +1,99 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// SnapshotDirName is the directory, next to the data file, that holds snapshots.
+const SnapshotDirName = "snapshots"
+
+// snapshotNamePattern restricts snapshot names to safe file name characters.
+var snapshotNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// SnapshotDir returns the snapshot directory that belongs to a data file.
+func SnapshotDir(dataPath string) string {
+	return filepath.Join(filepath.Dir(dataPath), SnapshotDirName)
+}
+
+// ValidateSnapshotName validates that a snapshot name is safe to use as a file name.
+// Returns an error if the name is empty or contains characters other than
+// letters, digits, dots, dashes, and underscores.
+func ValidateSnapshotName(name string) error {
+	if name == "" {
+		return fmt.Errorf("snapshot name cannot be empty")
+	}
+	if !snapshotNamePattern.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid snapshot name '%s': use letters, digits, '.', '-' or '_'", name)
+	}
+	return nil
+}
+
+// SaveSnapshot stores tasks as a named JSON snapshot in dir.
+// Creates the directory if needed and overwrites an existing snapshot with the same name.
+// Returns the snapshot file path, or an error if the name is invalid or saving fails.
+func SaveSnapshot(dir, name string, tasks []todo.Task) (string, error) {
+	if err := ValidateSnapshotName(name); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("cannot create snapshot directory %s: %w", dir, err)
+	}
+
+	path := filepath.Join(dir, name+".json")
+	if err := SaveJSON(path, tasks); err != nil {
+		return "", fmt.Errorf("cannot save snapshot %s: %w", name, err)
+	}
+
+	logger.Info("Saved snapshot '%s' with %d tasks to %s", name, len(tasks), path)
+	return path, nil
+}
+
+// LoadSnapshot reads the named JSON snapshot from dir.
+// Returns an error if the name is invalid, the snapshot doesn't exist, or loading fails.
+func LoadSnapshot(dir, name string) ([]todo.Task, error) {
+	if err := ValidateSnapshotName(name); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, name+".json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("snapshot '%s' does not exist", name)
+	}
+
+	tasks, err := LoadJSON(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load snapshot %s: %w", name, err)
+	}
+	return tasks, nil
+}
+
+// ListSnapshots returns the names of all snapshots in dir, sorted alphabetically.
+// Returns an empty slice if the directory doesn't exist.
+func ListSnapshots(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot directory %s: %w", dir, err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,57 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// ListState holds the marker recorded by the last list --since-last-run
+// for a data file. LastRun is the time of that run; tasks created later
+// count as new.
+type ListState struct {
+	LastRun time.Time `json:"last_run"`
+}
+
+// StatePath returns the path of the state file that belongs to a data file.
+func StatePath(dataPath string) string {
+	return dataPath + ".state.json"
+}
+
+// LoadState reads the list state from a state file.
+// Returns a zero state if the file doesn't exist yet.
+// Returns an error if file reading or JSON parsing fails.
+func LoadState(path string) (ListState, error) {
+	var state ListState
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		logger.Debug("State file %s does not exist, returning empty state", path)
+		return state, nil
+	} else if err != nil {
+		return state, fmt.Errorf("cannot read state file %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("cannot parse state from %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// SaveState writes the list state to a state file.
+// Uses atomic write (temp file + rename) to protect data from corruption.
+// The caller must hold the lock of the data file, so concurrent
+// list runs don't interleave their load and save of the state.
+// Returns an error if JSON marshaling or file writing fails.
+func SaveState(path string, state ListState) error {
+	if err := writeJSONFile(path, state); err != nil {
+		return fmt.Errorf("cannot write state file %s: %w", path, err)
+	}
+	logger.Debug("Saved list state to %s (last run %s)", path, state.LastRun.Format(time.RFC3339))
+	return nil
+}
@@ -1,8 +1,20 @@
 package storage
 
 import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 	"todo-app/internal/todo"
 )
 
@@ -77,6 +89,21 @@ func TestJSONLoadEmptyFile(t *testing.T) {
 	}
 }
 
+func TestJSONLoadDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+
+	_, err := LoadJSON(path)
+	if err == nil {
+		t.Fatal("Expected error when the data file is a directory")
+	}
+	if expected := fmt.Sprintf("expected a file but %s is a directory", path); err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
 func TestJSONWithSpecialCharacters(t *testing.T) {
 	testFile := "unicode_test.json"
 	defer os.Remove(testFile)
@@ -206,6 +233,251 @@ func TestCSVSaveAndLoad(t *testing.T) {
 	}
 }
 
+func TestCSVRefRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tasks.csv")
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Fix login", Done: false, Ref: "https://tracker.example.com/issues/42", Priority: todo.PriorityHigh},
+		{ID: 2, Description: "No ref", Done: true, Priority: todo.PriorityMedium},
+	}
+	if err := SaveCSV(path, tasks); err != nil {
+		t.Fatalf("SaveCSV failed: %v", err)
+	}
+	loaded, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected %+v, got %+v", tasks, loaded)
+	}
+
+	// Files written before the Ref and Priority columns existed still load,
+	// with the default priority
+	legacy := filepath.Join(dir, "legacy.csv")
+	if err := os.WriteFile(legacy, []byte("ID,Description,Done\n1,Old task,true\n"), 0644); err != nil {
+		t.Fatalf("Failed to write legacy CSV: %v", err)
+	}
+	loaded, err = LoadCSV(legacy)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	expected := []todo.Task{{ID: 1, Description: "Old task", Done: true, Priority: todo.PriorityMedium}}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, loaded)
+	}
+
+	// Columns are matched by header name, not position
+	reordered := filepath.Join(dir, "reordered.csv")
+	if err := os.WriteFile(reordered, []byte("Ref,Done,ID,Description\nJIRA-7,false,3,Moved\n"), 0644); err != nil {
+		t.Fatalf("Failed to write reordered CSV: %v", err)
+	}
+	loaded, err = LoadCSV(reordered)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	expected = []todo.Task{{ID: 3, Description: "Moved", Done: false, Ref: "JIRA-7", Priority: todo.PriorityMedium}}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, loaded)
+	}
+}
+
+func TestCSVInvalidPrioritySkipped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+	content := "ID,Description,Done,Priority\n1,Low,false,low\n2,Bad,false,urgent\n3,Empty,true,\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	loaded, result, err := LoadCSVWithResult(path)
+	if err != nil {
+		t.Fatalf("LoadCSVWithResult failed: %v", err)
+	}
+	expected := []todo.Task{
+		{ID: 1, Description: "Low", Done: false, Priority: todo.PriorityLow},
+		{ID: 3, Description: "Empty", Done: true, Priority: todo.PriorityMedium},
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, loaded)
+	}
+	if result.Skipped != 1 {
+		t.Errorf("Expected 1 skipped record, got %d", result.Skipped)
+	}
+}
+
+// Test that a record with an unterminated quote is skipped and the valid
+// records after it still load, while strict mode aborts on it
+func TestCSVUnterminatedQuote(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+	content := "ID,Description,Done\n1,First,false\n2,\"Broken,false\n3,Third,true\n4,Say \"hi\",false\n5,Extra,false,high,x\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	loaded, result, err := LoadCSVWithResult(path)
+	if err != nil {
+		t.Fatalf("LoadCSVWithResult failed: %v", err)
+	}
+	expected := []todo.Task{
+		{ID: 1, Description: "First", Done: false, Priority: todo.PriorityMedium},
+		{ID: 3, Description: "Third", Done: true, Priority: todo.PriorityMedium},
+		{ID: 4, Description: `Say "hi"`, Done: false, Priority: todo.PriorityMedium},
+		{ID: 5, Description: "Extra", Done: false, Priority: todo.PriorityMedium},
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, loaded)
+	}
+	if result.Skipped != 1 || len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "line 3") {
+		t.Errorf("Expected 1 skipped record at line 3, got %d: %v", result.Skipped, result.Errors)
+	}
+
+	_, _, err = LoadCSVDelimited(path, ',', true)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected strict load to fail at line 3, got %v", err)
+	}
+
+	// Strict mode also rejects records with a different number of fields
+	if err := os.WriteFile(path, []byte("ID,Description,Done\n1,First,false\n2,Second,false,extra\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	_, _, err = LoadCSVDelimited(path, ',', true)
+	if !errors.Is(err, csv.ErrFieldCount) {
+		t.Errorf("Expected ErrFieldCount in strict mode, got %v", err)
+	}
+	if loaded, _, err := LoadCSVDelimited(path, ',', false); err != nil || len(loaded) != 2 {
+		t.Errorf("Expected lenient load of 2 tasks, got %d: %v", len(loaded), err)
+	}
+
+	// Strict mode returns the error of a record with an invalid value
+	if err := os.WriteFile(path, []byte("ID,Description,Done,Priority\n1,First,false,low\n2,Second,false,urgent\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	_, _, err = LoadCSVDelimited(path, ',', true)
+	if !errors.Is(err, todo.ErrInvalidPriority) || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected ErrInvalidPriority at line 3 in strict mode, got %v", err)
+	}
+	if loaded, result, err := LoadCSVDelimited(path, ',', false); err != nil || len(loaded) != 1 || result.Skipped != 1 {
+		t.Errorf("Expected lenient load to skip the invalid record, got %d tasks, %d skipped: %v", len(loaded), result.Skipped, err)
+	}
+}
+
+func TestCSVDueDateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+
+	due := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+	tasks := []todo.Task{
+		{ID: 1, Description: "Report", DueDate: &due, Priority: todo.PriorityMedium},
+		{ID: 2, Description: "No due date", Priority: todo.PriorityMedium},
+	}
+	if err := SaveCSV(path, tasks); err != nil {
+		t.Fatalf("SaveCSV failed: %v", err)
+	}
+	loaded, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].DueDate == nil || !loaded[0].DueDate.Equal(due) {
+		t.Fatalf("Expected due date %v on task 1, got %+v", due, loaded)
+	}
+	if loaded[1].DueDate != nil {
+		t.Errorf("Expected no due date on task 2, got %v", loaded[1].DueDate)
+	}
+}
+
+func TestCSVTimestampsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+
+	createdAt := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	completedAt := createdAt.Add(90 * time.Minute)
+	tasks := []todo.Task{
+		{ID: 1, Description: "Done", Done: true, Priority: todo.PriorityMedium, CreatedAt: createdAt, CompletedAt: &completedAt},
+		{ID: 2, Description: "Legacy", Priority: todo.PriorityMedium},
+	}
+	if err := SaveCSV(path, tasks); err != nil {
+		t.Fatalf("SaveCSV failed: %v", err)
+	}
+	loaded, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if len(loaded) != 2 || !loaded[0].CreatedAt.Equal(createdAt) || loaded[0].CompletedAt == nil || !loaded[0].CompletedAt.Equal(completedAt) {
+		t.Fatalf("Expected timestamps to round-trip, got %+v", loaded)
+	}
+	if !loaded[1].CreatedAt.IsZero() || loaded[1].CompletedAt != nil {
+		t.Errorf("Expected zero timestamps for legacy task, got %+v", loaded[1])
+	}
+}
+
+func TestCSVTagsRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Report", Priority: todo.PriorityMedium, Tags: []string{"work", "urgent"}},
+		{ID: 2, Description: "Untagged", Priority: todo.PriorityMedium},
+	}
+	if err := SaveCSV(path, tasks); err != nil {
+		t.Fatalf("SaveCSV failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !strings.Contains(string(data), "work;urgent") {
+		t.Errorf("Expected semicolon-separated tags, got:\n%s", data)
+	}
+
+	loaded, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected %+v, got %+v", tasks, loaded)
+	}
+
+	// A tag set with the separator in it reloads as the same tags
+	tasks, err = todo.SetTags(tasks, 2, []string{"a;b", "c"})
+	if err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	if err := SaveCSV(path, tasks); err != nil {
+		t.Fatalf("SaveCSV failed: %v", err)
+	}
+	loaded, err = LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected tags to survive the round trip, expected %+v, got %+v", tasks, loaded)
+	}
+}
+
+func TestJSONRefOmittedWhenEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Linked", Ref: "JIRA-42", Priority: todo.PriorityLow},
+		{ID: 2, Description: "Plain", Priority: todo.PriorityMedium},
+	}
+	if err := SaveJSON(path, tasks); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if count := bytes.Count(data, []byte(`"ref"`)); count != 1 {
+		t.Errorf("Expected ref key only for the linked task, found %d times:\n%s", count, data)
+	}
+
+	loaded, err := LoadJSON(path)
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected %+v, got %+v", tasks, loaded)
+	}
+}
+
 func TestCSVLoadWithInvalidData(t *testing.T) {
 	testFile := "invalid_test.csv"
 	defer os.Remove(testFile)
@@ -254,6 +526,27 @@ func TestCSVLoadWithInvalidData(t *testing.T) {
 	}
 }
 
+func TestCSVLoadSkipsNonPositiveIDs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+	content := "ID,Description,Done\n0,Zero,false\n1,Valid,false\n-1,Negative,true\n2,Also valid,true\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+
+	loaded, err := LoadCSV(path)
+	if err != nil {
+		t.Fatalf("LoadCSV failed: %v", err)
+	}
+
+	expected := []todo.Task{
+		{ID: 1, Description: "Valid", Done: false, Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Also valid", Done: true, Priority: todo.PriorityMedium},
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, loaded)
+	}
+}
+
 func TestCSVWithSpecialCharacters(t *testing.T) {
 	testFile := "special_chars_test.csv"
 	defer os.Remove(testFile)
@@ -287,3 +580,1075 @@ func TestCSVWithSpecialCharacters(t *testing.T) {
 		t.Errorf("Quotes not preserved: expected 'Task with \"quotes\"', got '%s'", loaded[1].Description)
 	}
 }
+
+func TestCSVWithUTF8BOM(t *testing.T) {
+	testFile := "bom_test.csv"
+	defer os.Remove(testFile)
+
+	bomCSV := "\xEF\xBB\xBFID,Description,Done\n1,Task 1,false\n2,Task 2,true\n"
+	err := os.WriteFile(testFile, []byte(bomCSV), 0644)
+	if err != nil {
+		t.Fatalf("Failed to write BOM file: %v", err)
+	}
+
+	// Load should handle BOM correctly
+	loaded, err := LoadCSV(testFile)
+	if err != nil {
+		t.Fatalf("LoadCSV failed with BOM: %v", err)
+	}
+
+	if len(loaded) != 2 {
+		t.Fatalf("Expected 2 tasks, got %d", len(loaded))
+	}
+	if loaded[0].ID != 1 || loaded[0].Description != "Task 1" || loaded[0].Done {
+		t.Errorf("First task not loaded correctly: %+v", loaded[0])
+	}
+}
+
+func TestCSVStreamMatchesFile(t *testing.T) {
+	testFile := "stream_test.csv"
+	defer os.Remove(testFile)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task, with comma", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+	}
+
+	var buf bytes.Buffer
+	if err := SaveCSVStream(&buf, tasks); err != nil {
+		t.Fatalf("SaveCSVStream failed: %v", err)
+	}
+
+	if err := SaveCSV(testFile, tasks); err != nil {
+		t.Fatalf("SaveCSV failed: %v", err)
+	}
+	data, err := os.ReadFile(testFile)
+	if err != nil {
+		t.Fatalf("Failed to read CSV file: %v", err)
+	}
+
+	if buf.String() != string(data) {
+		t.Errorf("Streamed CSV differs from file:\nstream: %q\nfile:   %q", buf.String(), string(data))
+	}
+}
+
+func TestCSVDelimiter(t *testing.T) {
+	dir := t.TempDir()
+
+	// Test loading a semicolon-delimited file from a spreadsheet tool
+	path := filepath.Join(dir, "sheet.csv")
+	content := "ID;Description;Done;Tags\n1;Buy milk, bread;false;home\n2;\"Pay; bills\";true;\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	loaded, result, err := LoadCSVDelimited(path, ';', false)
+	if err != nil {
+		t.Fatalf("LoadCSVDelimited failed: %v", err)
+	}
+	if result.Skipped != 0 || len(loaded) != 2 {
+		t.Fatalf("Expected 2 tasks and no skipped records, got %+v, %+v", loaded, result)
+	}
+	if loaded[0].Description != "Buy milk, bread" || loaded[1].Description != "Pay; bills" || !loaded[1].Done {
+		t.Errorf("Unexpected tasks: %+v", loaded)
+	}
+
+	// Test round trip with a custom delimiter
+	out := filepath.Join(dir, "out.csv")
+	if err := SaveCSVDelimited(out, loaded, ';'); err != nil {
+		t.Fatalf("SaveCSVDelimited failed: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "ID;Description;Done;") {
+		t.Errorf("Expected semicolon-separated header, got %q", data)
+	}
+	again, _, err := LoadCSVDelimited(out, ';', false)
+	if err != nil || len(again) != 2 || again[1].Description != "Pay; bills" {
+		t.Errorf("Round trip failed: %+v, %v", again, err)
+	}
+
+	// Test delimiter parsing
+	for input, expected := range map[string]rune{",": ',', ";": ';', "|": '|', `\t`: '\t'} {
+		if comma, err := ParseDelimiter(input); err != nil || comma != expected {
+			t.Errorf("ParseDelimiter(%q): expected %q, got %q, %v", input, expected, comma, err)
+		}
+	}
+	for _, input := range []string{"", ";;", "ab", `"`, "\n"} {
+		if _, err := ParseDelimiter(input); err == nil {
+			t.Errorf("ParseDelimiter(%q): expected an error", input)
+		}
+	}
+	if err := SaveCSVDelimited(out, loaded, '"'); err == nil {
+		t.Error("Expected SaveCSVDelimited to reject a quote delimiter")
+	}
+}
+
+func BenchmarkSaveCSVStream(b *testing.B) {
+	tasks := make([]todo.Task, 10000)
+	for i := range tasks {
+		tasks[i] = todo.Task{ID: i + 1, Description: fmt.Sprintf("Benchmark task %d", i+1), Done: i%2 == 0}
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := SaveCSVStream(io.Discard, tasks); err != nil {
+			b.Fatalf("SaveCSVStream failed: %v", err)
+		}
+	}
+}
+
+func TestWriteJSONStreamMatchesMarshal(t *testing.T) {
+	cases := [][]todo.Task{
+		{},
+		{{ID: 1, Description: "Single task", Done: false}},
+		{
+			{ID: 1, Description: "Task with \"quotes\" and <tags>", Done: false},
+			{ID: 2, Description: "Задача 🚀", Done: true},
+			{ID: 3, Description: "Task 3", Done: false},
+		},
+	}
+
+	for _, tasks := range cases {
+		var buf bytes.Buffer
+		if err := WriteJSONStream(&buf, tasks); err != nil {
+			t.Fatalf("WriteJSONStream failed: %v", err)
+		}
+
+		expected, err := json.Marshal(tasks)
+		if err != nil {
+			t.Fatalf("json.Marshal failed: %v", err)
+		}
+
+		if buf.String() != string(expected) {
+			t.Errorf("Streamed JSON differs from json.Marshal:\nstream:  %s\nmarshal: %s", buf.String(), expected)
+		}
+	}
+}
+
+func TestJSONSaveAndLoadLargeList(t *testing.T) {
+	testFile := "large_test.json"
+	defer os.Remove(testFile)
+
+	tasks := make([]todo.Task, JSONStreamThreshold+1)
+	for i := range tasks {
+		tasks[i] = todo.Task{ID: i + 1, Description: fmt.Sprintf("Task %d", i+1), Done: i%2 == 0, Priority: todo.PriorityMedium}
+	}
+
+	if err := SaveJSON(testFile, tasks); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+
+	loaded, err := LoadJSON(testFile)
+	if err != nil {
+		t.Fatalf("LoadJSON failed: %v", err)
+	}
+	if len(loaded) != len(tasks) {
+		t.Fatalf("Expected %d tasks, got %d", len(tasks), len(loaded))
+	}
+	if !reflect.DeepEqual(loaded[len(loaded)-1], tasks[len(tasks)-1]) {
+		t.Errorf("Last task mismatch: expected %+v, got %+v", tasks[len(tasks)-1], loaded[len(loaded)-1])
+	}
+}
+
+func TestReplayEvents(t *testing.T) {
+	events := []Event{
+		{Type: EventCreate, Task: todo.Task{ID: 1, Description: "Task 1", Done: false}},
+		{Type: EventCreate, Task: todo.Task{ID: 2, Description: "Task 2", Done: false}},
+		{Type: EventCreate, Task: todo.Task{ID: 3, Description: "Task 3", Done: false}},
+		{Type: EventComplete, Task: todo.Task{ID: 1, Description: "Task 1", Done: true}},
+		{Type: EventDelete, Task: todo.Task{ID: 2, Description: "Task 2", Done: false}},
+		{Type: EventUpdate, Task: todo.Task{ID: 3, Description: "Task 3 edited", Done: false}},
+		{Type: EventDelete, Task: todo.Task{ID: 99}}, // Unknown task is skipped
+	}
+
+	tasks := ReplayEvents(events)
+
+	expected := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: true},
+		{ID: 3, Description: "Task 3 edited", Done: false},
+	}
+	if len(tasks) != len(expected) {
+		t.Fatalf("Expected %d tasks, got %d: %+v", len(expected), len(tasks), tasks)
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(tasks[i], expected[i]) {
+			t.Errorf("Task %d: expected %+v, got %+v", i, expected[i], tasks[i])
+		}
+	}
+}
+
+func TestAppendEventsRoundTrip(t *testing.T) {
+	testFile := "events_test.events.jsonl"
+	defer os.Remove(testFile)
+
+	before := []todo.Task{}
+	after := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false, Priority: todo.PriorityHigh},
+		{ID: 2, Description: "Task 2", Done: false, Priority: todo.PriorityMedium},
+	}
+	if err := AppendEvents(testFile, DiffEvents(before, after)); err != nil {
+		t.Fatalf("AppendEvents failed: %v", err)
+	}
+
+	before = after
+	after = []todo.Task{
+		{ID: 1, Description: "Task 1", Done: true, Priority: todo.PriorityHigh},
+	}
+	diff := DiffEvents(before, after)
+	if len(diff) != 2 {
+		t.Fatalf("Expected delete and complete events, got %+v", diff)
+	}
+	if err := AppendEvents(testFile, diff); err != nil {
+		t.Fatalf("AppendEvents failed: %v", err)
+	}
+
+	events, err := LoadEvents(testFile)
+	if err != nil {
+		t.Fatalf("LoadEvents failed: %v", err)
+	}
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events in log, got %d", len(events))
+	}
+
+	tasks := ReplayEvents(events)
+	if len(tasks) != 1 || !reflect.DeepEqual(tasks[0], after[0]) {
+		t.Errorf("Replay should produce current state %+v, got %+v", after, tasks)
+	}
+}
+
+// Test that a changed order, as left by move, is recorded and replayed
+func TestDiffEventsReorder(t *testing.T) {
+	before := []todo.Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3"},
+	}
+	after := []todo.Task{before[2], before[0], before[1]}
+
+	diff := DiffEvents(before, after)
+	if len(diff) != 1 || diff[0].Type != EventReorder || !reflect.DeepEqual(diff[0].Order, []int{3, 1, 2}) {
+		t.Fatalf("Expected a single reorder event to 3,1,2, got %+v", diff)
+	}
+	events := append(DiffEvents(nil, before), diff...)
+	if tasks := ReplayEvents(events); !reflect.DeepEqual(tasks, after) {
+		t.Errorf("Expected replay to produce %+v, got %+v", after, tasks)
+	}
+
+	// Deletes and appended creates keep the order on their own
+	added := append(todo.CloneTasks(before[1:]), todo.Task{ID: 4, Description: "Task 4"})
+	for _, event := range DiffEvents(before, added) {
+		if event.Type == EventReorder {
+			t.Errorf("Expected no reorder event for delete and create, got %+v", event)
+		}
+	}
+}
+
+// TestSaveJSONConcurrent saves from several goroutines at once. AcquireLock
+// makes them take turns, so the file must end up as exactly one input.
+func TestSaveJSONConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	testFile := filepath.Join(dir, "concurrent_test.json")
+
+	const writers = 8
+	inputs := make([][]todo.Task, writers)
+	for i := range inputs {
+		for j := 0; j <= i; j++ {
+			inputs[i] = append(inputs[i], todo.Task{
+				ID:          j + 1,
+				Description: fmt.Sprintf("Writer %d task %d", i, j+1),
+				Done:        j%2 == 0,
+				Priority:    todo.PriorityMedium,
+			})
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := range inputs {
+		wg.Add(1)
+		go func(tasks []todo.Task) {
+			defer wg.Done()
+			if err := SaveJSON(testFile, tasks); err != nil {
+				errs <- err
+			}
+		}(inputs[i])
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("Concurrent SaveJSON failed: %v", err)
+	}
+
+	// The file must be valid JSON equal to exactly one of the inputs
+	loaded, err := LoadJSON(testFile)
+	if err != nil {
+		t.Fatalf("LoadJSON failed after concurrent saves: %v", err)
+	}
+	matched := false
+	for _, tasks := range inputs {
+		if reflect.DeepEqual(loaded, tasks) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		t.Errorf("Final file does not match any writer's input: %+v", loaded)
+	}
+
+	// No lock or temporary files may be left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read test directory: %v", err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("Expected only the data file to remain, got %v", names)
+	}
+}
+
+func TestLoadIgnoresLockFile(t *testing.T) {
+	dir := t.TempDir()
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+	}
+
+	jsonFile := filepath.Join(dir, "locked.json")
+	csvFile := filepath.Join(dir, "locked.csv")
+	if err := SaveJSON(jsonFile, tasks); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+	if err := SaveCSV(csvFile, tasks); err != nil {
+		t.Fatalf("SaveCSV failed: %v", err)
+	}
+
+	// Simulate stale locks left behind by a crashed writer
+	for _, path := range []string{jsonFile, csvFile} {
+		if err := os.WriteFile(path+".lock", nil, 0644); err != nil {
+			t.Fatalf("Failed to create lock file: %v", err)
+		}
+	}
+
+	// Reads must not wait for the lock (it would time out after lockTimeout)
+	start := time.Now()
+	loaded, err := LoadJSON(jsonFile)
+	if err != nil || len(loaded) != 1 {
+		t.Errorf("LoadJSON should succeed with lock present, got %d tasks (err: %v)", len(loaded), err)
+	}
+	loaded, err = LoadCSV(csvFile)
+	if err != nil || len(loaded) != 1 {
+		t.Errorf("LoadCSV should succeed with lock present, got %d tasks (err: %v)", len(loaded), err)
+	}
+	if elapsed := time.Since(start); elapsed >= lockRetry {
+		t.Errorf("Loading took %v, reads appear to wait for the lock", elapsed)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), SnapshotDirName)
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false, Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Задача 2", Done: true, Priority: todo.PriorityLow},
+	}
+
+	if _, err := SaveSnapshot(dir, "sprint1", tasks); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+	if _, err := SaveSnapshot(dir, "alpha", tasks[:1]); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	loaded, err := LoadSnapshot(dir, "sprint1")
+	if err != nil {
+		t.Fatalf("LoadSnapshot failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Restored tasks differ: expected %+v, got %+v", tasks, loaded)
+	}
+
+	names, err := ListSnapshots(dir)
+	if err != nil {
+		t.Fatalf("ListSnapshots failed: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"alpha", "sprint1"}) {
+		t.Errorf("Expected sorted snapshot names [alpha sprint1], got %v", names)
+	}
+
+	// Missing snapshot is an error, unlike a missing data file
+	if _, err := LoadSnapshot(dir, "missing"); err == nil {
+		t.Error("Expected error for missing snapshot")
+	}
+
+	// Names that could escape the snapshot directory are rejected
+	for _, name := range []string{"", "../evil", "a/b", ".."} {
+		if _, err := SaveSnapshot(dir, name, tasks); err == nil {
+			t.Errorf("Expected error for invalid snapshot name '%s'", name)
+		}
+	}
+}
+
+func TestTemplatesRoundTrip(t *testing.T) {
+	path := TemplatesPath(filepath.Join(t.TempDir(), "tasks.json"))
+
+	templates, err := LoadTemplates(path)
+	if err != nil || len(templates) != 0 {
+		t.Fatalf("Expected no templates before the first save, got %v, %v", templates, err)
+	}
+
+	if err := SaveTemplate(path, todo.Template{Name: "bug", Priority: todo.PriorityLow, Tags: []string{"Bug"}}); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+	if err := SaveTemplate(path, todo.Template{Name: "chore", Tags: []string{"home"}}); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+	// Saving under an existing name replaces the template
+	bug := todo.Template{Name: "bug", Priority: todo.PriorityHigh, Tags: []string{"bug", "urgent"}}
+	if err := SaveTemplate(path, bug); err != nil {
+		t.Fatalf("SaveTemplate failed: %v", err)
+	}
+
+	templates, err = LoadTemplates(path)
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	if len(templates) != 2 || templates[0].Name != "bug" || templates[1].Name != "chore" {
+		t.Errorf("Expected templates bug and chore, got %+v", templates)
+	}
+
+	loaded, err := LoadTemplate(path, "bug")
+	if err != nil {
+		t.Fatalf("LoadTemplate failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, bug) {
+		t.Errorf("Expected %+v, got %+v", bug, loaded)
+	}
+
+	if _, err := LoadTemplate(path, "missing"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Errorf("Expected ErrTemplateNotFound for missing template, got %v", err)
+	}
+	if err := SaveTemplate(path, todo.Template{Name: "bad", Priority: "urgent"}); !errors.Is(err, todo.ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+
+	// Saving replaces the file atomically and releases its lock
+	if _, err := os.Stat(LockPath(path)); !os.IsNotExist(err) {
+		t.Errorf("Expected lock file to be removed after save, stat error: %v", err)
+	}
+	if matches, _ := filepath.Glob(path + ".tmp.*"); len(matches) != 0 {
+		t.Errorf("Expected no temporary files left, got %v", matches)
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	if stale, err := StaleLock(path); err != nil || stale {
+		t.Errorf("Expected no stale lock without a lock file, got %v, %v", stale, err)
+	}
+
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	if stale, err := StaleLock(path); err != nil || stale {
+		t.Errorf("Expected a fresh lock not to be stale, got %v, %v", stale, err)
+	}
+
+	// An old lock is not stale while its owner is running
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(LockPath(path), old, old); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	if stale, err := StaleLock(path); err != nil || stale {
+		t.Errorf("Expected an old lock of a running process not to be stale, got %v, %v", stale, err)
+	}
+	lock.Release()
+
+	// A lock of a process that has exited is stale however fresh it is
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run helper process: %v", err)
+	}
+	if err := os.WriteFile(LockPath(path), []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	if stale, err := StaleLock(path); err != nil || !stale {
+		t.Errorf("Expected a lock of a dead process to be stale, got %v, %v", stale, err)
+	}
+
+	// Without a PID only the age counts
+	if err := os.WriteFile(LockPath(path), nil, 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	if stale, err := StaleLock(path); err != nil || stale {
+		t.Errorf("Expected a fresh lock without PID not to be stale, got %v, %v", stale, err)
+	}
+	if err := os.Chtimes(LockPath(path), old, old); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	if stale, err := StaleLock(path); err != nil || !stale {
+		t.Errorf("Expected an old lock without PID to be stale, got %v, %v", stale, err)
+	}
+}
+
+func TestReclaimStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	lockPath := LockPath(path)
+
+	// The lock file holds the owner's PID
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	data, err := os.ReadFile(lockPath)
+	if err != nil || strings.TrimSpace(string(data)) != fmt.Sprint(os.Getpid()) {
+		t.Errorf("Expected PID %d in lock file, got %q, %v", os.Getpid(), data, err)
+	}
+	if reclaimStaleLock(lockPath) {
+		t.Error("A lock held by a running process must not be reclaimed")
+	}
+	lock.Release()
+
+	// A lock whose owner has exited is reclaimed right away
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Failed to run helper process: %v", err)
+	}
+	if err := os.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", cmd.Process.Pid)), 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	start := time.Now()
+	lock, err = AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock should reclaim a lock of a dead process: %v", err)
+	}
+	if time.Since(start) > lockTimeout {
+		t.Errorf("Reclaiming took %v, expected no waiting for the lock timeout", time.Since(start))
+	}
+	lock.Release()
+
+	// Without a readable PID only locks older than the stale timeout are reclaimed
+	SetStaleLockTimeout(time.Minute)
+	defer SetStaleLockTimeout(DefaultStaleLockTimeout)
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to write lock file: %v", err)
+	}
+	if reclaimStaleLock(lockPath) {
+		t.Error("A fresh lock without PID must not be reclaimed")
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(lockPath, old, old); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	if !reclaimStaleLock(lockPath) {
+		t.Error("Expected an old lock without PID to be reclaimed")
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Expected reclaimed lock file to be removed, got %v", err)
+	}
+}
+
+func TestAcquireLockReacquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	lockPath := LockPath(path)
+
+	first, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+
+	// Reentering the held lock succeeds without waiting
+	start := time.Now()
+	second, err := first.Reacquire()
+	if err != nil {
+		t.Fatalf("Reacquire failed: %v", err)
+	}
+	if time.Since(start) > lockRetry {
+		t.Errorf("Reacquire took %v, expected no waiting", time.Since(start))
+	}
+
+	// Saving while the lock is held reenters it
+	store, _ := NewStore("json")
+	if err := store.SaveLocked(first, path, []todo.Task{{ID: 1, Description: "Task 1"}}); err != nil {
+		t.Fatalf("SaveLocked under a held lock failed: %v", err)
+	}
+	if err := store.SaveLocked(first, path+".other", nil); err == nil {
+		t.Error("Expected SaveLocked to reject a lock for another file")
+	}
+
+	if err := second.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	// Releasing twice must not drop the other holder's count
+	if err := second.Release(); err != nil {
+		t.Fatalf("Second Release failed: %v", err)
+	}
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Errorf("Expected lock file to remain while still held: %v", err)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("Expected lock file to be removed after the last release, got %v", err)
+	}
+	heldLocksMu.Lock()
+	_, ok := heldLocks[lockPath]
+	heldLocksMu.Unlock()
+	if ok {
+		t.Error("Expected the held lock entry to be deleted after the last release")
+	}
+	if _, err := first.Reacquire(); err == nil {
+		t.Error("Expected Reacquire of a released lock to fail")
+	}
+
+	// The lock can be taken again after it was fully released
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock after release failed: %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquireLockExcludesGoroutines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	first, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+
+	// A second AcquireLock in this process waits for the release
+	acquired := make(chan *FileLock)
+	go func() {
+		lock, err := AcquireLock(path)
+		if err != nil {
+			t.Errorf("AcquireLock in another goroutine failed: %v", err)
+		}
+		acquired <- lock
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected AcquireLock to wait while the lock is held")
+	case <-time.After(2 * lockRetry):
+	}
+
+	first.Release()
+	select {
+	case lock := <-acquired:
+		if lock != nil {
+			lock.Release()
+		}
+	case <-time.After(lockTimeout):
+		t.Fatal("Expected AcquireLock to succeed after the release")
+	}
+}
+
+func TestLockedReadModifyWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	store, _ := NewStore("json")
+
+	// Goroutines that load, add a task and save under the lock, like main
+	// does for mutating commands, must not lose each other's updates
+	const workers, adds = 2, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < adds; i++ {
+				lock, err := AcquireLock(path)
+				if err != nil {
+					t.Errorf("AcquireLock failed: %v", err)
+					return
+				}
+				tasks, err := store.Load(path)
+				if err == nil {
+					tasks = append(tasks, todo.Task{ID: len(tasks) + 1, Description: fmt.Sprintf("Worker %d task %d", w, i)})
+					err = store.SaveLocked(lock, path, tasks)
+				}
+				lock.Release()
+				if err != nil {
+					t.Errorf("Read-modify-write failed: %v", err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	tasks, err := store.Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(tasks) != workers*adds {
+		t.Errorf("Expected %d tasks, got %d: updates were lost", workers*adds, len(tasks))
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	if err := SaveJSON(path, []todo.Task{{ID: 1, Description: "Task 1"}}); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+
+	// No sidecar yet, nothing to verify
+	if verified, err := VerifyChecksum(path); err != nil || verified {
+		t.Errorf("Expected no verification without sidecar, got %v, %v", verified, err)
+	}
+
+	if err := WriteChecksum(path); err != nil {
+		t.Fatalf("WriteChecksum failed: %v", err)
+	}
+	data, err := os.ReadFile(ChecksumPath(path))
+	if err != nil {
+		t.Fatalf("Failed to read sidecar: %v", err)
+	}
+	sum, err := FileChecksum(path)
+	if err != nil {
+		t.Fatalf("FileChecksum failed: %v", err)
+	}
+	if string(data) != sum+"  tasks.json\n" {
+		t.Errorf("Unexpected sidecar content: %q", data)
+	}
+
+	// A file matching its sidecar verifies
+	if verified, err := VerifyChecksum(path); err != nil || !verified {
+		t.Errorf("Expected matching file to verify, got %v, %v", verified, err)
+	}
+
+	// A tampered file fails verification
+	if err := os.WriteFile(path, []byte(`[{"id":1,"description":"Tampered"}]`), 0644); err != nil {
+		t.Fatalf("Failed to tamper file: %v", err)
+	}
+	if _, err := VerifyChecksum(path); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch for tampered file, got %v", err)
+	}
+}
+
+func TestBackupAndSwap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+
+	// Test swapping without a backup
+	if swapped, err := SwapBackup(path); err != nil || swapped {
+		t.Errorf("Expected nothing to swap without a backup, got %v, %v", swapped, err)
+	}
+
+	// Test that backing up a missing file removes an old backup
+	if err := os.WriteFile(BackupPath(path), []byte("[]"), 0644); err != nil {
+		t.Fatalf("Failed to write backup: %v", err)
+	}
+	if err := Backup(path); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if _, err := os.Stat(BackupPath(path)); !os.IsNotExist(err) {
+		t.Errorf("Expected old backup to be removed, got %v", err)
+	}
+
+	before := []todo.Task{{ID: 1, Description: "Task 1"}}
+	after := []todo.Task{{ID: 1, Description: "Task 1"}, {ID: 2, Description: "Task 2"}}
+	if err := SaveJSON(path, before); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+	if err := Backup(path); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := SaveJSON(path, after); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+
+	// Test that swapping restores the backup and keeps the undone state
+	if swapped, err := SwapBackup(path); err != nil || !swapped {
+		t.Fatalf("SwapBackup failed: %v, %v", swapped, err)
+	}
+	if loaded, _ := LoadJSON(path); len(loaded) != 1 {
+		t.Errorf("Expected backup with 1 task to be restored, got %+v", loaded)
+	}
+	if swapped, err := SwapBackup(path); err != nil || !swapped {
+		t.Fatalf("Second SwapBackup failed: %v, %v", swapped, err)
+	}
+	if loaded, _ := LoadJSON(path); len(loaded) != 2 {
+		t.Errorf("Expected swapping again to redo, got %+v", loaded)
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	dataDir := t.TempDir()
+	locks := filepath.Join(t.TempDir(), "locks")
+	SetLockDir(locks)
+	defer SetLockDir("")
+
+	path := filepath.Join(dataDir, "tasks.json")
+	if filepath.Dir(LockPath(path)) != locks {
+		t.Errorf("Expected lock path in %s, got %s", locks, LockPath(path))
+	}
+	// Same file name in another directory gets its own lock
+	if LockPath(path) == LockPath(filepath.Join(t.TempDir(), "tasks.json")) {
+		t.Error("Expected different lock paths for different data files")
+	}
+
+	lock, err := AcquireLock(path)
+	if err != nil {
+		t.Fatalf("AcquireLock failed: %v", err)
+	}
+	if _, err := os.Stat(LockPath(path)); err != nil {
+		t.Errorf("Expected lock file in the lock directory: %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Expected no lock file next to the data file, got %v", err)
+	}
+	lock.Release()
+
+	// Saving leaves the data directory free of lock files
+	if err := SaveJSON(path, []todo.Task{{ID: 1, Description: "Task 1"}}); err != nil {
+		t.Fatalf("SaveJSON failed: %v", err)
+	}
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "tasks.json" {
+		t.Errorf("Expected only tasks.json in the data directory, got %v", entries)
+	}
+}
+
+func TestSaveHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.html")
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Fix <script>alert('x')</script> & more", Done: false},
+		{ID: 2, Description: "Ship", Done: true, Ref: "https://tracker.example.com/issues/42"},
+		{ID: 3, Description: "Plain", Done: false, Ref: "JIRA-7"},
+	}
+	if err := SaveHTML(path, tasks); err != nil {
+		t.Fatalf("SaveHTML failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	page := string(data)
+
+	// One table row per task plus the header row
+	if rows := strings.Count(page, "<tr"); rows != len(tasks)+1 {
+		t.Errorf("Expected %d rows, got %d", len(tasks)+1, rows)
+	}
+	if strings.Contains(page, "<script>") {
+		t.Error("Description markup was not escaped")
+	}
+	if !strings.Contains(page, "Fix &lt;script&gt;alert(&#39;x&#39;)&lt;/script&gt; &amp; more") {
+		t.Errorf("Expected escaped description in page:\n%s", page)
+	}
+	if !strings.Contains(page, `<a href="https://tracker.example.com/issues/42">`) {
+		t.Error("Expected URL ref to be rendered as a link")
+	}
+	if strings.Contains(page, `href="JIRA-7"`) {
+		t.Error("Plain refs should not be rendered as links")
+	}
+}
+
+func TestSaveHTMLFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.html")
+
+	created := time.Date(2024, 6, 1, 9, 30, 0, 0, time.Local)
+	completed := created.Add(2 * time.Hour)
+	past := created.AddDate(0, 0, 1)
+	future := time.Now().AddDate(1, 0, 0)
+	tasks := []todo.Task{
+		{ID: 1, Description: "Late", Priority: todo.PriorityHigh, DueDate: &past, Tags: []string{"home", "urgent"}, Pinned: true, CreatedAt: created},
+		{ID: 2, Description: "Later", Priority: todo.PriorityLow, DueDate: &future},
+		{ID: 3, Description: "Done late", Done: true, Priority: todo.PriorityMedium, DueDate: &past, CreatedAt: created, CompletedAt: &completed},
+	}
+	if err := SaveHTML(path, tasks); err != nil {
+		t.Fatalf("SaveHTML failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	page := string(data)
+
+	for _, header := range []string{"Priority", "Tags", "Due", "Pinned", "Created", "Completed"} {
+		if !strings.Contains(page, "<th>"+header+"</th>") {
+			t.Errorf("Expected %s column in page", header)
+		}
+	}
+	for _, cell := range []string{
+		`<td class="priority-high">high</td>`,
+		"<td>home, urgent</td>",
+		`<td class="overdue">2024-06-02 09:30 (overdue)</td>`,
+		"<td>2024-06-01 09:30</td>",
+		"<td>2024-06-01 11:30</td>",
+		`class="pending pinned"`,
+	} {
+		if !strings.Contains(page, cell) {
+			t.Errorf("Expected %q in page:\n%s", cell, page)
+		}
+	}
+	// Only the pending task past its due date is overdue
+	if count := strings.Count(page, "(overdue)"); count != 1 {
+		t.Errorf("Expected 1 overdue task, got %d", count)
+	}
+}
+
+func TestCSVStreamNoHeader(t *testing.T) {
+	tasks := []todo.Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+	}
+
+	var withHeader, withoutHeader bytes.Buffer
+	if err := SaveCSVStream(&withHeader, tasks); err != nil {
+		t.Fatalf("SaveCSVStream failed: %v", err)
+	}
+	if err := SaveCSVStreamNoHeader(&withoutHeader, tasks); err != nil {
+		t.Fatalf("SaveCSVStreamNoHeader failed: %v", err)
+	}
+
+	header, records, _ := strings.Cut(withHeader.String(), "\n")
+	if header != "ID,Description,Done,Ref,Pinned,Priority,DueDate,CreatedAt,CompletedAt,Tags" {
+		t.Errorf("Unexpected header: %q", header)
+	}
+	if withoutHeader.String() != records {
+		t.Errorf("Expected the same records without header, got:\n%s", withoutHeader.String())
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.yaml")
+
+	created := time.Date(2024, 5, 20, 10, 15, 0, 0, time.UTC)
+	due := time.Date(2024, 6, 1, 23, 59, 59, 0, time.UTC)
+	tasks := []todo.Task{
+		{ID: 1, Description: "Задача с русскими буквами", Done: false, Priority: todo.PriorityMedium, CreatedAt: created},
+		{ID: 2, Description: "Task with emoji 🚀 and symbols ©®", Done: true, Ref: "JIRA-1", Priority: todo.PriorityHigh, DueDate: &due, Tags: []string{"work", "go"}},
+		{ID: 3, Description: "key: value # not a comment\nsecond line", Pinned: true, Priority: todo.PriorityLow},
+	}
+	if err := SaveYAML(path, tasks); err != nil {
+		t.Fatalf("SaveYAML failed: %v", err)
+	}
+
+	loaded, err := LoadYAML(path)
+	if err != nil {
+		t.Fatalf("LoadYAML failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected %+v, got %+v", tasks, loaded)
+	}
+
+	// A missing priority loads as medium, like in JSON
+	if err := os.WriteFile(path, []byte("- id: 1\n  description: Old task\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	loaded, err = LoadYAML(path)
+	if err != nil {
+		t.Fatalf("LoadYAML failed: %v", err)
+	}
+	if len(loaded) != 1 || loaded[0].Priority != todo.PriorityMedium {
+		t.Errorf("Expected one medium priority task, got %+v", loaded)
+	}
+
+	// Missing and empty files load as an empty list
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	for _, p := range []string{path, filepath.Join(t.TempDir(), "missing.yml")} {
+		loaded, err := LoadYAML(p)
+		if err != nil || len(loaded) != 0 {
+			t.Errorf("Expected empty list for %s, got %+v, %v", p, loaded, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("- id: [\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := LoadYAML(path); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+func TestTSVRoundTripWithTabs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.tsv")
+
+	tasks := []todo.Task{
+		{ID: 1, Description: "Column\twith\ttabs", Done: false, Priority: todo.PriorityMedium},
+		{ID: 2, Description: "Quote \" and, comma", Done: true, Ref: "JIRA-1", Priority: todo.PriorityHigh},
+		{ID: 3, Description: "Задача с\tтабуляцией", Done: false, Pinned: true, Priority: todo.PriorityMedium},
+	}
+	if err := SaveTSV(path, tasks); err != nil {
+		t.Fatalf("SaveTSV failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "ID\tDescription\tDone") {
+		t.Errorf("Expected tab-separated header, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "\"Column\twith\ttabs\"") {
+		t.Errorf("Expected description with tabs to be quoted, got:\n%s", data)
+	}
+
+	loaded, err := LoadTSV(path)
+	if err != nil {
+		t.Fatalf("LoadTSV failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, tasks) {
+		t.Errorf("Expected %+v, got %+v", tasks, loaded)
+	}
+}
+
+// Test that every data format round-trips tasks and treats a missing file as empty
+func TestNewStore(t *testing.T) {
+	due := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+	tasks := []todo.Task{
+		{ID: 1, Description: "First", Done: true, Priority: todo.PriorityHigh, Tags: []string{"home"}},
+		{ID: 2, Description: "Second, with comma", DueDate: &due, Priority: todo.PriorityMedium},
+	}
+
+	for _, format := range DataFormats {
+		store, err := NewStore(format)
+		if err != nil {
+			t.Fatalf("NewStore(%s) failed: %v", format, err)
+		}
+		path := filepath.Join(t.TempDir(), "tasks."+format)
+
+		loaded, err := store.Load(path)
+		if err != nil || loaded == nil || len(loaded) != 0 {
+			t.Errorf("Expected empty task list for missing %s file, got %v, %v", format, loaded, err)
+		}
+		if err := store.Save(path, tasks); err != nil {
+			t.Fatalf("Save %s failed: %v", format, err)
+		}
+		loaded, err = store.Load(path)
+		if err != nil {
+			t.Fatalf("Load %s failed: %v", format, err)
+		}
+		if len(loaded) != 2 || !loaded[0].Done || loaded[1].Description != tasks[1].Description || !loaded[1].DueDate.Equal(due) {
+			t.Errorf("Expected %+v after %s round trip, got %+v", tasks, format, loaded)
+		}
+	}
+
+	if _, err := NewStore("xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+
+	// CSV data files are read strictly, so a save can't drop skipped records
+	store, _ := NewStore("csv")
+	path := filepath.Join(t.TempDir(), "tasks.csv")
+	if err := os.WriteFile(path, []byte("ID,Description,Done\n1,First,false\nx,Bad,false\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test file: %v", err)
+	}
+	if _, err := store.Load(path); err == nil {
+		t.Error("Expected error for an invalid record in a CSV data file")
+	}
+}
@@ -0,0 +1,106 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// DataFormats are the formats the primary data file can be stored in.
+var DataFormats = []string{"json", "yaml", "csv", "tsv"}
+
+// Store loads and saves the primary data file in a single format.
+// Both functions accept a file that doesn't exist yet: Load returns
+// an empty task slice and Save creates it.
+// Write saves like Save but without taking the lock, see SaveLocked.
+type Store struct {
+	Format string
+	Load   func(path string) ([]todo.Task, error)
+	Save   func(path string, tasks []todo.Task) error
+	Write  func(path string, tasks []todo.Task) error
+}
+
+// SaveLocked saves tasks to path like Save, while the caller holds lock,
+// the lock for path, e.g. across a whole load, modify, save cycle.
+// Save would wait for that lock; SaveLocked reenters it instead.
+// Returns an error if lock guards another file or was already released.
+func (s Store) SaveLocked(lock *FileLock, path string, tasks []todo.Task) error {
+	if lock.path != LockPath(path) {
+		return fmt.Errorf("cannot save %s under lock %s", path, lock.path)
+	}
+	reentered, err := lock.Reacquire()
+	if err != nil {
+		return fmt.Errorf("cannot save %s: %w", path, err)
+	}
+	defer reentered.Release()
+
+	return s.Write(path, tasks)
+}
+
+// NewStore returns the Store for format, one of DataFormats.
+// Returns an error if the format is unknown.
+func NewStore(format string) (Store, error) {
+	switch format {
+	case "json":
+		return Store{Format: format, Load: LoadJSON, Save: SaveJSON, Write: WriteJSON}, nil
+	case "yaml":
+		return Store{Format: format, Load: LoadYAML, Save: SaveYAML, Write: WriteYAML}, nil
+	case "csv":
+		return Store{Format: format, Load: loadDataFile(','), Save: SaveCSV, Write: writeDataFile(',')}, nil
+	case "tsv":
+		return Store{Format: format, Load: loadDataFile('\t'), Save: SaveTSV, Write: writeDataFile('\t')}, nil
+	default:
+		return Store{}, fmt.Errorf("unknown data format '%s', expected %s", format, strings.Join(DataFormats, ", "))
+	}
+}
+
+// loadDataFile returns a loader for a delimiter-separated data file.
+// Unlike LoadCSV it fails on any malformed or invalid record, since saving
+// the loaded tasks would otherwise silently drop the skipped records.
+// A missing file loads as an empty task slice.
+func loadDataFile(comma rune) func(path string) ([]todo.Task, error) {
+	return func(path string) ([]todo.Task, error) {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			logger.Info("Data file %s does not exist, returning empty task list", path)
+			return []todo.Task{}, nil
+		}
+		tasks, _, err := loadDelimited(path, comma, true)
+		if err != nil {
+			return nil, err
+		}
+		if tasks == nil {
+			tasks = []todo.Task{}
+		}
+		return tasks, nil
+	}
+}
+
+// writeDataFile returns a writer for a delimiter-separated data file,
+// like SaveCSV or SaveTSV but without locking.
+func writeDataFile(comma rune) func(path string, tasks []todo.Task) error {
+	return func(path string, tasks []todo.Task) error {
+		return writeDelimited(path, tasks, comma)
+	}
+}
+
+// IsSidecar reports whether path is one of the files kept next to a data
+// file rather than a data file itself: the list state, event log, templates,
+// backup, checksum or lock file. They are matched by the suffixes the path
+// functions append, e.g. StatePath, so a pattern like "*.json" can skip them.
+func IsSidecar(path string) bool {
+	if filepath.Base(path) == TemplatesFileName {
+		return true
+	}
+	for _, suffix := range []string{StatePath(""), EventsPath(""), BackupPath(""), ChecksumPath(""), ".lock"} {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// TemplatesFileName is the file, next to the data file, that holds task templates.
+const TemplatesFileName = "templates.json"
+
+// ErrTemplateNotFound is returned by LoadTemplate if no template has the given name.
+var ErrTemplateNotFound = errors.New("template not found")
+
+// TemplatesPath returns the templates file that belongs to a data file.
+func TemplatesPath(dataPath string) string {
+	return filepath.Join(filepath.Dir(dataPath), TemplatesFileName)
+}
+
+// LoadTemplates reads all templates from a templates file.
+// Returns an empty slice if the file doesn't exist yet.
+// Returns an error if file reading or JSON parsing fails.
+func LoadTemplates(path string) ([]todo.Template, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		logger.Debug("Templates file %s does not exist, returning no templates", path)
+		return []todo.Template{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot read templates file %s: %w", path, err)
+	}
+
+	var templates []todo.Template
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return nil, fmt.Errorf("cannot parse templates from %s: %w", path, err)
+	}
+	return templates, nil
+}
+
+// LoadTemplate reads the named template from a templates file.
+// Returns an error wrapping ErrTemplateNotFound if the template doesn't
+// exist, or another error if loading fails.
+func LoadTemplate(path, name string) (todo.Template, error) {
+	templates, err := LoadTemplates(path)
+	if err != nil {
+		return todo.Template{}, err
+	}
+	for _, tmpl := range templates {
+		if tmpl.Name == name {
+			return tmpl, nil
+		}
+	}
+	return todo.Template{}, fmt.Errorf("%w: '%s'", ErrTemplateNotFound, name)
+}
+
+// SaveTemplate stores a template in a templates file, replacing an existing
+// template with the same name. Templates are kept sorted by name.
+// The file is locked for the whole read-modify-write cycle and replaced
+// atomically (temp file + rename), so concurrent saves don't lose templates.
+// Returns an error if the template is invalid, the lock cannot be acquired,
+// or reading or writing the file fails.
+func SaveTemplate(path string, tmpl todo.Template) error {
+	if err := todo.ValidateTemplate(tmpl); err != nil {
+		return err
+	}
+	tmpl.Tags = todo.NormalizeTags(tmpl.Tags)
+
+	lock, err := AcquireLock(path)
+	if err != nil {
+		return fmt.Errorf("cannot acquire lock for %s: %w", path, err)
+	}
+	defer lock.Release()
+
+	templates, err := LoadTemplates(path)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range templates {
+		if templates[i].Name == tmpl.Name {
+			templates[i] = tmpl
+			replaced = true
+		}
+	}
+	if !replaced {
+		templates = append(templates, tmpl)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	if err := writeJSONFile(path, templates); err != nil {
+		return fmt.Errorf("cannot write templates file %s: %w", path, err)
+	}
+	logger.Info("Saved template '%s' to %s", tmpl.Name, path)
+	return nil
+}
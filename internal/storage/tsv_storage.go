@@ -0,0 +1,54 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"io"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+)
+
+// LoadTSV reads tasks from a tab-separated file.
+// It uses the same columns and record validation as LoadCSV.
+// Returns an error if file reading fails.
+func LoadTSV(path string) ([]todo.Task, error) {
+	tasks, _, err := LoadTSVWithResult(path)
+	return tasks, err
+}
+
+// LoadTSVWithResult works like LoadTSV and additionally returns
+// an ImportResult describing imported and skipped records.
+func LoadTSVWithResult(path string) ([]todo.Task, ImportResult, error) {
+	return loadDelimited(path, '\t', false)
+}
+
+// SaveTSV writes tasks to a tab-separated file with a header row.
+// Fields containing tabs, quotes, or newlines are quoted like in CSV.
+// Uses atomic write and file locking like SaveCSV.
+// Returns an error if file creation or writing fails.
+func SaveTSV(path string, tasks []todo.Task) error {
+	return saveDelimited(path, tasks, '\t')
+}
+
+// SaveTSVStream writes tasks as TSV with a header row directly to w.
+// Returns an error if writing fails.
+func SaveTSVStream(w io.Writer, tasks []todo.Task) error {
+	successCount, err := writeCSV(w, tasks, true, '\t')
+	if err != nil {
+		return err
+	}
+	logger.Info("Successfully streamed %d/%d tasks as TSV", successCount, len(tasks))
+	return nil
+}
+
+// SaveTSVStreamNoHeader writes tasks as TSV records without a header row to w.
+// Returns an error if writing fails.
+func SaveTSVStreamNoHeader(w io.Writer, tasks []todo.Task) error {
+	successCount, err := writeCSV(w, tasks, false, '\t')
+	if err != nil {
+		return err
+	}
+	logger.Info("Successfully streamed %d/%d tasks as TSV without header", successCount, len(tasks))
+	return nil
+}
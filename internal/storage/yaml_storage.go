@@ -0,0 +1,117 @@
+// Package storage provides persistence functionality for tasks
+// in various formats including JSON and CSV.
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"todo-app/internal/todo"
+
+	"github.com/ZeRg0912/logger"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadYAML reads tasks from a YAML file with logging.
+// A missing priority is read as todo.PriorityMedium, like in JSON files.
+// Returns an empty task slice if the file doesn't exist or is empty.
+// Returns an error if file reading or YAML parsing fails.
+func LoadYAML(path string) ([]todo.Task, error) {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		logger.Info("YAML file %s does not exist, returning empty task list", path)
+		return []todo.Task{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unexpected error accessing path %s: %w", path, err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read file %s: %w", path, err)
+	}
+
+	if len(data) >= 3 && data[0] == 0xEF && data[1] == 0xBB && data[2] == 0xBF {
+		data = data[3:]
+		logger.Debug("Removed UTF-8 BOM from YAML file")
+	}
+
+	var tasks []todo.Task
+	if err := yaml.Unmarshal(data, &tasks); err != nil {
+		return nil, fmt.Errorf("cannot parse YAML from %s: %w", path, err)
+	}
+	if tasks == nil {
+		logger.Info("YAML file %s is empty, returning empty task list", path)
+		return []todo.Task{}, nil
+	}
+	for i := range tasks {
+		if tasks[i].Priority == "" {
+			tasks[i].Priority = todo.PriorityMedium
+		}
+	}
+
+	logger.Info("Successfully loaded %d tasks from YAML file: %s", len(tasks), path)
+	return tasks, nil
+}
+
+// SaveYAML writes tasks to a YAML file with logging.
+// Uses atomic write (temp file + rename) to protect data from corruption.
+// Uses file locking to prevent concurrent access conflicts.
+// Returns an error if YAML marshaling or file writing fails.
+func SaveYAML(path string, tasks []todo.Task) error {
+	lock, err := AcquireLock(path)
+	if err != nil {
+		return fmt.Errorf("cannot acquire lock for %s: %w", path, err)
+	}
+	defer lock.Release()
+
+	return WriteYAML(path, tasks)
+}
+
+// WriteYAML writes tasks to a YAML file like SaveYAML, but without locking.
+// The caller must already hold the lock for path, see Store.SaveLocked.
+// Returns an error if YAML marshaling or file writing fails.
+func WriteYAML(path string, tasks []todo.Task) error {
+	dir := filepath.Dir(path)
+	if dir == "." {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("cannot get absolute path for %s: %w", path, err)
+		}
+		dir = filepath.Dir(absPath)
+	}
+	tmpFile, err := os.CreateTemp(dir, filepath.Base(path)+".tmp.*")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file for %s: %w", path, err)
+	}
+	tmpPath := tmpFile.Name()
+
+	defer func() {
+		tmpFile.Close()
+		if _, err := os.Stat(tmpPath); err == nil {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	data, err := yaml.Marshal(tasks)
+	if err != nil {
+		return fmt.Errorf("cannot marshal tasks to YAML: %w", err)
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		return fmt.Errorf("cannot write to temporary file %s: %w", tmpPath, err)
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		return fmt.Errorf("cannot sync temporary YAML file %s: %w", tmpPath, err)
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("cannot close temporary YAML file %s: %w", tmpPath, err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("cannot rename temporary file to %s: %w", path, err)
+	}
+
+	logger.Info("Successfully saved %d tasks to YAML file: %s", len(tasks), path)
+	return nil
+}
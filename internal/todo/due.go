@@ -0,0 +1,70 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Layouts accepted by ParseDueDate.
+const (
+	DueDateLayout     = "2006-01-02"
+	DueDateTimeLayout = "2006-01-02T15:04"
+)
+
+// ParseDueDate parses a due date like "2024-06-01" or "2024-06-01T15:00"
+// in the local time zone. A date without a time means the end of that day,
+// so a task due today is not overdue until midnight.
+// Returns an error wrapping ErrInvalidDueDate if the value matches neither layout.
+func ParseDueDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if due, err := time.ParseInLocation(DueDateTimeLayout, value, time.Local); err == nil {
+		return due, nil
+	}
+	day, err := time.ParseInLocation(DueDateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: '%s' (use %s or %s)", ErrInvalidDueDate, value, DueDateLayout, DueDateTimeLayout)
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Second), nil
+}
+
+// SetDueDate sets the due date of a task by its ID.
+// A nil due clears the due date.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+// Returns an updated copy of the task slice on success.
+func SetDueDate(tasks []Task, id int, due *time.Time) ([]Task, error) {
+	if err := ValidateID(id); err != nil {
+		return tasks, err
+	}
+	index := findTaskByID(tasks, id)
+	if index == -1 {
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	tasks = CloneTasks(tasks)
+	if due != nil {
+		copied := *due
+		due = &copied
+	}
+	tasks[index].DueDate = due
+	return tasks, nil
+}
+
+// IsOverdue reports whether a pending task is past its due date at now.
+// Tasks without a due date and completed tasks are never overdue.
+func IsOverdue(task Task, now time.Time) bool {
+	if task.DueDate == nil || task.Done {
+		return false
+	}
+	return now.After(*task.DueDate)
+}
+
+// Overdue returns a Predicate matching pending tasks past their due date.
+// The current time is read once, from the package clock, when it is called.
+func Overdue() Predicate {
+	at := now()
+	return func(task Task) bool {
+		return IsOverdue(task, at)
+	}
+}
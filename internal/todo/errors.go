@@ -0,0 +1,22 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import "errors"
+
+// Sentinel errors returned (wrapped) by task operations.
+// Callers can branch on them with errors.Is.
+var (
+	ErrTaskNotFound       = errors.New("task not found")
+	ErrInvalidID          = errors.New("invalid task ID")
+	ErrEmptyDescription   = errors.New("task description cannot be empty")
+	ErrDescriptionTooLong = errors.New("task description is too long")
+	ErrInvalidRef         = errors.New("invalid task reference")
+	ErrNoMatch            = errors.New("no matching task")
+	ErrAmbiguousMatch     = errors.New("ambiguous task match")
+	ErrInvalidPriority    = errors.New("invalid task priority")
+	ErrInvalidDueDate     = errors.New("invalid due date")
+	ErrInvalidPattern     = errors.New("invalid search pattern")
+	ErrDuplicateTask      = errors.New("duplicate task")
+	ErrInvalidPosition    = errors.New("invalid task position")
+)
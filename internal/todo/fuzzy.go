@@ -0,0 +1,149 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MinFuzzyScore is the lowest score a description needs to count as a match.
+	MinFuzzyScore = 0.6
+	// FuzzyAmbiguityMargin is how close the runner-up score may come to the best
+	// one before a match is considered ambiguous.
+	FuzzyAmbiguityMargin = 0.1
+)
+
+// FuzzyScore rates how well text matches query, from 0 (no match) to 1.
+// Both are compared word by word, ignoring case and punctuation:
+// each query word scores 1 for an exact word, 0.9 for a word prefix,
+// and otherwise its edit-distance similarity to the closest word in text.
+// Numbers only match the same number exactly, so "4" never matches "42".
+// The result is the average over all query words.
+func FuzzyScore(query, text string) float64 {
+	queryWords := fuzzyWords(query)
+	textWords := fuzzyWords(text)
+	if len(queryWords) == 0 || len(textWords) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, q := range queryWords {
+		best := 0.0
+		for _, w := range textWords {
+			if score := wordSimilarity(q, w); score > best {
+				best = score
+			}
+		}
+		total += best
+	}
+	return total / float64(len(queryWords))
+}
+
+// MatchPending finds the single pending task whose description best matches query.
+// A pending task whose whole description equals query (ignoring case)
+// wins outright, so "42" picks the task described as "42" over "Task 42".
+// Returns an error wrapping ErrNoMatch if no task scores at least MinFuzzyScore,
+// or ErrAmbiguousMatch if another task scores within FuzzyAmbiguityMargin of the best.
+func MatchPending(tasks []Task, query string) (Task, error) {
+	type candidate struct {
+		task  Task
+		score float64
+	}
+
+	pending := List(tasks, "pending")
+	var exact []Task
+	for _, task := range pending {
+		if strings.EqualFold(strings.TrimSpace(task.Description), strings.TrimSpace(query)) {
+			exact = append(exact, task)
+		}
+	}
+	if len(exact) == 1 {
+		return exact[0], nil
+	}
+
+	var candidates []candidate
+	for _, task := range pending {
+		if score := FuzzyScore(query, task.Description); score >= MinFuzzyScore {
+			candidates = append(candidates, candidate{task: task, score: score})
+		}
+	}
+	if len(candidates) == 0 {
+		return Task{}, fmt.Errorf("%w: no pending task matches '%s'", ErrNoMatch, query)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) > 1 && candidates[0].score-candidates[1].score < FuzzyAmbiguityMargin {
+		var ids []string
+		for _, c := range candidates {
+			if candidates[0].score-c.score < FuzzyAmbiguityMargin {
+				ids = append(ids, strconv.Itoa(c.task.ID))
+			}
+		}
+		return Task{}, fmt.Errorf("%w: '%s' matches tasks %s", ErrAmbiguousMatch, query, strings.Join(ids, ", "))
+	}
+	return candidates[0].task, nil
+}
+
+// fuzzyWords splits s into lower-case words of letters and digits.
+func fuzzyWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// wordSimilarity compares two lower-case words.
+func wordSimilarity(query, word string) float64 {
+	if query == word {
+		return 1
+	}
+	if isNumber(query) || isNumber(word) {
+		return 0
+	}
+	if strings.HasPrefix(word, query) {
+		return 0.9
+	}
+	q, w := []rune(query), []rune(word)
+	longest := len(q)
+	if len(w) > longest {
+		longest = len(w)
+	}
+	return 1 - float64(levenshtein(q, w))/float64(longest)
+}
+
+// isNumber reports whether s consists of digits only.
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// levenshtein returns the edit distance between two rune slices.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
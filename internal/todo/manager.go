@@ -3,32 +3,157 @@
 package todo
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// now returns the current time for task timestamps.
+// It is a variable so tests can use a fixed clock.
+var now = time.Now
+
+// IDStrategy picks the ID of a new task from the current tasks,
+// e.g. NextID or LowestFreeID.
+type IDStrategy func(tasks []Task) int
+
 const (
 	MinID                = 1
 	MaxDescriptionLength = 1000
+	LastIDToken          = "last"
 )
 
 // Add creates a new task and appends it to the task list.
 // Generates a unique ID by finding the maximum existing ID and incrementing it.
-// Returns an error if description validation fails.
-// Returns the updated task slice on success.
-func Add(tasks []Task, desc string) ([]Task, error) {
+// The task's CreatedAt is set to the current time.
+// Returns an error if description or priority validation fails.
+// Returns the updated task slice and the created task on success.
+func Add(tasks []Task, desc, priority string) ([]Task, Task, error) {
+	return addWithID(tasks, desc, priority, NextID)
+}
+
+// addWithID implements Add with the given ID strategy.
+func addWithID(tasks []Task, desc, priority string, ids IDStrategy) ([]Task, Task, error) {
 	if err := ValidateDescription(desc); err != nil {
-		return tasks, err
+		return tasks, Task{}, err
+	}
+	if err := ValidatePriority(priority); err != nil {
+		return tasks, Task{}, err
 	}
 	newTask := Task{
-		ID:          generateID(tasks),
+		ID:          ids(tasks),
 		Description: desc,
 		Done:        false,
+		Priority:    priority,
+		CreatedAt:   now(),
+	}
+	return append(tasks, newTask), newTask, nil
+}
+
+// AddUnique creates a new task like Add, unless a task with the same
+// description, ignoring case and surrounding whitespace, already exists.
+// Returns an error wrapping ErrDuplicateTask for a duplicate description.
+func AddUnique(tasks []Task, desc, priority string) ([]Task, Task, error) {
+	return addUnique(tasks, desc, priority, NextID)
+}
+
+// addUnique implements AddUnique with the given ID strategy.
+func addUnique(tasks []Task, desc, priority string, ids IDStrategy) ([]Task, Task, error) {
+	key := strings.ToLower(strings.TrimSpace(desc))
+	for _, task := range tasks {
+		if strings.ToLower(strings.TrimSpace(task.Description)) == key {
+			return tasks, Task{}, fmt.Errorf("%w: '%s' already exists as task %d", ErrDuplicateTask, strings.TrimSpace(desc), task.ID)
+		}
+	}
+	return addWithID(tasks, desc, priority, ids)
+}
+
+// AddMany creates a task for every description in descs, in order,
+// each with its own ID picked by ids and PriorityMedium.
+// Descriptions that fail validation are skipped, the rest are still added.
+// Returns the updated task slice, the created tasks, and one error per
+// skipped description naming its position in descs.
+func AddMany(tasks []Task, descs []string, ids IDStrategy) ([]Task, []Task, []error) {
+	return addMany(tasks, descs, ids, addWithID)
+}
+
+// AddManyUnique is AddMany with AddUnique: descriptions that duplicate an
+// existing task, or an earlier description in descs, are skipped with an
+// error wrapping ErrDuplicateTask.
+func AddManyUnique(tasks []Task, descs []string, ids IDStrategy) ([]Task, []Task, []error) {
+	return addMany(tasks, descs, ids, addUnique)
+}
+
+// addMany implements AddMany and AddManyUnique with the given add function.
+func addMany(tasks []Task, descs []string, ids IDStrategy, add func([]Task, string, string, IDStrategy) ([]Task, Task, error)) ([]Task, []Task, []error) {
+	var created []Task
+	var errs []error
+	for i, desc := range descs {
+		var task Task
+		var err error
+		tasks, task, err = add(tasks, desc, PriorityMedium, ids)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("description #%d: %w", i+1, err))
+			continue
+		}
+		created = append(created, task)
+	}
+	return tasks, created, errs
+}
+
+// Merge appends imported tasks to tasks, giving each imported task a new
+// ID picked by ids, so it cannot collide with an existing one.
+// All other fields are kept.
+// Imported tasks that duplicate a task already in the list, i.e. have the
+// same description and done status, are skipped, so importing the same
+// file twice adds its tasks only once.
+// Returns the merged task slice and the number of skipped duplicates.
+func Merge(tasks, imported []Task, ids IDStrategy) ([]Task, int) {
+	type key struct {
+		description string
+		done        bool
+	}
+	seen := make(map[key]bool, len(tasks)+len(imported))
+	for _, task := range tasks {
+		seen[key{task.Description, task.Done}] = true
+	}
+
+	skipped := 0
+	for _, task := range imported {
+		k := key{task.Description, task.Done}
+		if seen[k] {
+			skipped++
+			continue
+		}
+		seen[k] = true
+		task.ID = ids(tasks)
+		tasks = append(tasks, task)
+	}
+	return tasks, skipped
+}
+
+// AppendRenumbered appends all imported tasks to tasks in their original
+// order, numbering them sequentially from the highest existing ID plus one.
+// The imported IDs are ignored entirely and no duplicates are skipped.
+// Neither input slice is modified.
+func AppendRenumbered(tasks, imported []Task) []Task {
+	next := NextID(tasks)
+	result := make([]Task, 0, len(tasks)+len(imported))
+	result = append(result, CloneTasks(tasks)...)
+	for _, task := range CloneTasks(imported) {
+		task.ID = next
+		next++
+		result = append(result, task)
 	}
-	return append(tasks, newTask), nil
+	return result
 }
 
 // List filters tasks based on the specified criteria.
-// Supported filters: "all", "done", "pending".
+// Supported filters: "all", "done", "pending", "untagged" (tasks without tags).
 // Returns a slice containing only tasks that match the filter.
 func List(tasks []Task, filter string) []Task {
 	switch filter {
@@ -48,6 +173,14 @@ func List(tasks []Task, filter string) []Task {
 			}
 		}
 		return result
+	case "untagged":
+		var result []Task
+		for _, task := range tasks {
+			if len(task.Tags) == 0 {
+				result = append(result, task)
+			}
+		}
+		return result
 	case "all":
 		return tasks
 	default:
@@ -55,41 +188,437 @@ func List(tasks []Task, filter string) []Task {
 	}
 }
 
+// TaskStats holds task counts by completion status.
+type TaskStats struct {
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Pending int `json:"pending"`
+}
+
+// Stats counts tasks by completion status.
+func Stats(tasks []Task) TaskStats {
+	stats := TaskStats{Total: len(tasks)}
+	for _, task := range tasks {
+		if task.Done {
+			stats.Done++
+		}
+	}
+	stats.Pending = stats.Total - stats.Done
+	return stats
+}
+
+// Add returns the sum of two sets of counts, e.g. to aggregate several files.
+func (s TaskStats) Add(other TaskStats) TaskStats {
+	return TaskStats{
+		Total:   s.Total + other.Total,
+		Done:    s.Done + other.Done,
+		Pending: s.Pending + other.Pending,
+	}
+}
+
+// Predicate reports whether a task should be kept by Filter.
+type Predicate func(Task) bool
+
+// Filter returns the tasks that satisfy every predicate, in order.
+// With no predicates all tasks are returned.
+func Filter(tasks []Task, predicates ...Predicate) []Task {
+	var result []Task
+	for _, task := range tasks {
+		keep := true
+		for _, predicate := range predicates {
+			if !predicate(task) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// IDRange returns a predicate matching task IDs from after to before,
+// both inclusive. A bound of 0 leaves that end of the range open.
+func IDRange(after, before int) Predicate {
+	return func(task Task) bool {
+		return (after == 0 || task.ID >= after) && (before == 0 || task.ID <= before)
+	}
+}
+
+// Since returns tasks created after t, by CreatedAt, so it doesn't
+// depend on how IDs were assigned or renumbered.
+// A zero t returns all tasks; tasks without CreatedAt are only
+// returned then, since their creation time is unknown.
+func Since(tasks []Task, t time.Time) []Task {
+	var result []Task
+	for _, task := range tasks {
+		if t.IsZero() || task.CreatedAt.After(t) {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// DefaultMaxAge is how long a pending task may exist before IsStale
+// reports it, 30 days.
+const DefaultMaxAge = 30 * 24 * time.Hour
+
+// IsStale reports whether a pending task was created more than maxAge
+// before now. Completed tasks, tasks without CreatedAt and a maxAge of 0
+// are never stale.
+func IsStale(task Task, maxAge time.Duration, now time.Time) bool {
+	if task.Done || task.CreatedAt.IsZero() || maxAge <= 0 {
+		return false
+	}
+	return now.Sub(task.CreatedAt) > maxAge
+}
+
+// Newest returns the most recently created task, by CreatedAt.
+// Tasks with the same or no CreatedAt are ordered by ID, the highest
+// counting as newest. Reports false if tasks is empty.
+func Newest(tasks []Task) (Task, bool) {
+	if len(tasks) == 0 {
+		return Task{}, false
+	}
+	newest := tasks[0]
+	for _, task := range tasks[1:] {
+		if task.CreatedAt.After(newest.CreatedAt) || (task.CreatedAt.Equal(newest.CreatedAt) && task.ID > newest.ID) {
+			newest = task
+		}
+	}
+	return newest, true
+}
+
+// GroupKeys are the keys GroupBy supports.
+var GroupKeys = []string{"status", "priority", "tag"}
+
+// UntaggedGroup is the group GroupBy puts tasks without tags in for the
+// "tag" key. The parentheses keep it apart from a tag named "untagged".
+const UntaggedGroup = "(untagged)"
+
+// GroupBy splits tasks into groups keyed by the value of the given field.
+// Supported keys: "status" (groups "done" and "pending"), "priority"
+// (a task without a priority counts as PriorityMedium) and "tag", where
+// a task is in the group of each of its tags, or in UntaggedGroup.
+// Returns an error if the key is not supported.
+func GroupBy(tasks []Task, key string) (map[string][]Task, error) {
+	if err := ValidateGroupKey(key); err != nil {
+		return nil, err
+	}
+	groups := make(map[string][]Task)
+	for _, task := range tasks {
+		var names []string
+		switch key {
+		case "status":
+			names = []string{"pending"}
+			if task.Done {
+				names = []string{"done"}
+			}
+		case "priority":
+			names = []string{PriorityMedium}
+			if task.Priority != "" {
+				names = []string{task.Priority}
+			}
+		case "tag":
+			names = task.Tags
+			if len(names) == 0 {
+				names = []string{UntaggedGroup}
+			}
+		}
+		for _, name := range names {
+			groups[name] = append(groups[name], task)
+		}
+	}
+	return groups, nil
+}
+
+// Sort returns a sorted copy of tasks, leaving the input unchanged.
+// Supported keys: "id", "order" (the stored order, see Move),
+// "description" (case-insensitive), "status" (pending before done)
+// and "random", which shuffles the tasks with a
+// Fisher-Yates shuffle seeded by seed, so the same seed gives the same order.
+// Sorting is stable and seed is ignored for every other key.
+// Returns an error if the key is not supported.
+func Sort(tasks []Task, key string, seed int64) ([]Task, error) {
+	if err := ValidateSortKey(key); err != nil {
+		return nil, err
+	}
+	sorted := append([]Task(nil), tasks...)
+
+	switch key {
+	case "id":
+		sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	case "description":
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return strings.ToLower(sorted[i].Description) < strings.ToLower(sorted[j].Description)
+		})
+	case "status":
+		sort.SliceStable(sorted, func(i, j int) bool { return !sorted[i].Done && sorted[j].Done })
+	case "random":
+		rng := rand.New(rand.NewSource(seed))
+		for i := len(sorted) - 1; i > 0; i-- {
+			j := rng.Intn(i + 1)
+			sorted[i], sorted[j] = sorted[j], sorted[i]
+		}
+	}
+	return sorted, nil
+}
+
 // Complete marks a task as done by its ID.
-// Returns an error if ID is invalid or no task with the given ID is found.
-// Returns the updated task slice on success.
+// CompletedAt is set when the task becomes done; completing a task that
+// is already done keeps its original completion time.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+// Returns an updated copy of the task slice on success.
 func Complete(tasks []Task, id int) ([]Task, error) {
 	if err := ValidateID(id); err != nil {
 		return tasks, err
 	}
 	index := findTaskByID(tasks, id)
 	if index == -1 {
-		return tasks, fmt.Errorf("task with ID %d not found", id)
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	tasks = CloneTasks(tasks)
+	if !tasks[index].Done {
+		completedAt := now()
+		tasks[index].CompletedAt = &completedAt
 	}
 	tasks[index].Done = true
 	return tasks, nil
 }
 
+// Uncomplete marks a task as pending again by its ID and clears CompletedAt.
+// Uncompleting a task that is already pending is not an error.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+// Returns an updated copy of the task slice on success.
+func Uncomplete(tasks []Task, id int) ([]Task, error) {
+	if err := ValidateID(id); err != nil {
+		return tasks, err
+	}
+	index := findTaskByID(tasks, id)
+	if index == -1 {
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	tasks = CloneTasks(tasks)
+	tasks[index].Done = false
+	tasks[index].CompletedAt = nil
+	return tasks, nil
+}
+
+// SetRef sets the external reference of a task by its ID.
+// An empty ref clears the reference.
+// Returns an error wrapping ErrInvalidID, ErrTaskNotFound or ErrInvalidRef on failure.
+// Returns an updated copy of the task slice on success.
+func SetRef(tasks []Task, id int, ref string) ([]Task, error) {
+	if err := ValidateID(id); err != nil {
+		return tasks, err
+	}
+	if err := ValidateRef(ref); err != nil {
+		return tasks, err
+	}
+	index := findTaskByID(tasks, id)
+	if index == -1 {
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	tasks = CloneTasks(tasks)
+	tasks[index].Ref = ref
+	return tasks, nil
+}
+
+// SetPriority sets the priority of a task by its ID.
+// Returns an error wrapping ErrInvalidID, ErrTaskNotFound or ErrInvalidPriority on failure.
+// Returns an updated copy of the task slice on success.
+func SetPriority(tasks []Task, id int, priority string) ([]Task, error) {
+	if err := ValidateID(id); err != nil {
+		return tasks, err
+	}
+	if err := ValidatePriority(priority); err != nil {
+		return tasks, err
+	}
+	index := findTaskByID(tasks, id)
+	if index == -1 {
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	tasks = CloneTasks(tasks)
+	tasks[index].Priority = priority
+	return tasks, nil
+}
+
+// SetPinned pins or unpins a task by its ID.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+// Returns an updated copy of the task slice on success.
+func SetPinned(tasks []Task, id int, pinned bool) ([]Task, error) {
+	if err := ValidateID(id); err != nil {
+		return tasks, err
+	}
+	index := findTaskByID(tasks, id)
+	if index == -1 {
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	tasks = CloneTasks(tasks)
+	tasks[index].Pinned = pinned
+	return tasks, nil
+}
+
+// PinnedFirst returns a new slice with pinned tasks moved to the front.
+// The relative order within pinned and within unpinned tasks is preserved,
+// so it can be applied after any sort.
+func PinnedFirst(tasks []Task) []Task {
+	result := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Pinned {
+			result = append(result, task)
+		}
+	}
+	for _, task := range tasks {
+		if !task.Pinned {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// Renumber returns a copy of tasks with IDs made contiguous from MinID.
+// Tasks keep their position in the slice and the relative order of their
+// IDs, so the lowest ID becomes MinID, the next one MinID+1, and so on.
+// A list without gaps is returned unchanged.
+func Renumber(tasks []Task) []Task {
+	ids := make([]int, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	sort.Ints(ids)
+	newIDs := make(map[int]int, len(ids))
+	for i, id := range ids {
+		newIDs[id] = MinID + i
+	}
+
+	result := append([]Task(nil), tasks...)
+	for i := range result {
+		result[i].ID = newIDs[result[i].ID]
+	}
+	return result
+}
+
+// priorityRank orders priorities from most to least urgent.
+// An unknown or empty priority ranks like PriorityMedium.
+var priorityRank = map[string]int{PriorityHigh: 0, PriorityMedium: 1, PriorityLow: 2}
+
+// Next returns up to count pending tasks in the order they should be worked on:
+// higher priority first, then the earliest due date, with tasks that have
+// a due date before those without, then the lowest ID.
+// If count exceeds the number of pending tasks, all of them are returned.
+// The input slice is left unchanged.
+func Next(tasks []Task, count int) []Task {
+	rank := func(task Task) int {
+		if r, ok := priorityRank[task.Priority]; ok {
+			return r
+		}
+		return priorityRank[PriorityMedium]
+	}
+
+	pending := List(tasks, "pending")
+	sort.SliceStable(pending, func(i, j int) bool {
+		a, b := pending[i], pending[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		if (a.DueDate == nil) != (b.DueDate == nil) {
+			return a.DueDate != nil
+		}
+		if a.DueDate != nil && !a.DueDate.Equal(*b.DueDate) {
+			return a.DueDate.Before(*b.DueDate)
+		}
+		return a.ID < b.ID
+	})
+	if count < 0 {
+		count = 0
+	}
+	if count < len(pending) {
+		pending = pending[:count]
+	}
+	return pending
+}
+
 // Delete removes a task from the list by its ID.
-// Returns an error if ID is invalid or no task with the given ID is found.
-// Returns the updated task slice on success.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+// Returns an updated copy of the task slice on success.
 func Delete(tasks []Task, id int) ([]Task, error) {
 	if err := ValidateID(id); err != nil {
 		return tasks, err
 	}
 	index := findTaskByID(tasks, id)
 	if index == -1 {
-		return tasks, fmt.Errorf("task with ID %d not found", id)
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
 	}
-
+	tasks = CloneTasks(tasks)
 	return append(tasks[:index], tasks[index+1:]...), nil
 }
 
-// generateID creates a new unique ID for a task.
-// It finds the maximum ID in the existing tasks and increments it by 1.
+// Move relocates the task with the given ID to a 1-based position in the
+// list, keeping the relative order of all other tasks. IDs are unchanged,
+// only the order of the slice is. Positions outside 1..len(tasks) are
+// rejected rather than clamped, so a typo can't silently move a task to an end.
+// Returns an error wrapping ErrInvalidID, ErrTaskNotFound or ErrInvalidPosition on failure.
+// Returns an updated copy of the task slice on success.
+func Move(tasks []Task, id, position int) ([]Task, error) {
+	if err := ValidateID(id); err != nil {
+		return tasks, err
+	}
+	index := findTaskByID(tasks, id)
+	if index == -1 {
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	if position < 1 || position > len(tasks) {
+		return tasks, fmt.Errorf("%w: %d, must be between 1 and %d", ErrInvalidPosition, position, len(tasks))
+	}
+	tasks = CloneTasks(tasks)
+	task := tasks[index]
+	tasks = append(tasks[:index], tasks[index+1:]...)
+	tasks = append(tasks[:position-1], append([]Task{task}, tasks[position-1:]...)...)
+	return tasks, nil
+}
+
+// ClearCompleted removes all completed tasks, keeping the order of the rest.
+// Returns the remaining tasks and the number of removed tasks; when no task
+// is completed, the original slice is returned unchanged.
+func ClearCompleted(tasks []Task) ([]Task, int) {
+	pending := List(tasks, "pending")
+	removed := len(tasks) - len(pending)
+	if removed == 0 {
+		return tasks, 0
+	}
+	if pending == nil {
+		pending = []Task{}
+	}
+	return pending, removed
+}
+
+// DeleteCompletedBefore removes completed tasks whose CompletedAt is before t,
+// keeping the order of the rest. Completed tasks without CompletedAt are kept,
+// since it is unknown when they were completed.
+// Returns the remaining tasks and the number of removed tasks; when no task
+// matches, the original slice is returned unchanged.
+func DeleteCompletedBefore(tasks []Task, t time.Time) ([]Task, int) {
+	remaining := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Done && task.CompletedAt != nil && task.CompletedAt.Before(t) {
+			continue
+		}
+		remaining = append(remaining, task.Clone())
+	}
+	removed := len(tasks) - len(remaining)
+	if removed == 0 {
+		return tasks, 0
+	}
+	return remaining, removed
+}
+
+// NextID is the default IDStrategy. It finds the maximum ID in the
+// existing tasks and increments it by 1.
 // Returns 1 if the task list is empty.
 // Optimized: uses single pass through tasks with early exit optimization.
-func generateID(tasks []Task) int {
+func NextID(tasks []Task) int {
 	if len(tasks) == 0 {
 		return MinID
 	}
@@ -103,27 +632,143 @@ func generateID(tasks []Task) int {
 	return maxID + 1
 }
 
+// LowestFreeID is an IDStrategy that reuses the smallest positive ID
+// that is not in use, e.g. 3 for IDs 1, 2 and 4, so IDs freed by
+// deleted tasks are filled first.
+// Returns MinID if the task list is empty.
+func LowestFreeID(tasks []Task) int {
+	used := make(map[int]bool, len(tasks))
+	for i := range tasks {
+		used[tasks[i].ID] = true
+	}
+	id := MinID
+	for used[id] {
+		id++
+	}
+	return id
+}
+
+// ResolveID converts a command line ID token into a task ID.
+// The token is either a numeric ID or LastIDToken, which resolves to
+// the newest task, see Newest, so it also works when IDs are reused.
+// Returns an error wrapping ErrInvalidID for malformed or out of range IDs,
+// or ErrTaskNotFound when "last" is used on an empty list.
+func ResolveID(tasks []Task, token string) (int, error) {
+	token = strings.TrimSpace(token)
+	if strings.EqualFold(token, LastIDToken) {
+		newest, ok := Newest(tasks)
+		if !ok {
+			return 0, fmt.Errorf("%w: cannot resolve '%s' in an empty list", ErrTaskNotFound, LastIDToken)
+		}
+		return newest.ID, nil
+	}
+
+	id, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%s' is not a number or '%s'", ErrInvalidID, token, LastIDToken)
+	}
+	if err := ValidateID(id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
 // ValidateID validates that a task ID is within acceptable range.
-// Returns an error if ID is less than MinID.
+// Returns an error wrapping ErrInvalidID if ID is less than MinID.
 func ValidateID(id int) error {
 	if id < MinID {
-		return fmt.Errorf("task ID must be at least %d, got %d", MinID, id)
+		return fmt.Errorf("%w: must be at least %d, got %d", ErrInvalidID, MinID, id)
 	}
 	return nil
 }
 
 // ValidateDescription validates that a task description is within acceptable limits.
-// Returns an error if description is empty or exceeds MaxDescriptionLength.
+// Returns ErrEmptyDescription if description is empty, or an error wrapping
+// ErrDescriptionTooLong if it exceeds MaxDescriptionLength.
 func ValidateDescription(desc string) error {
 	if desc == "" {
-		return fmt.Errorf("task description cannot be empty")
+		return ErrEmptyDescription
 	}
 	if len(desc) > MaxDescriptionLength {
-		return fmt.Errorf("task description cannot exceed %d characters, got %d", MaxDescriptionLength, len(desc))
+		return fmt.Errorf("%w: cannot exceed %d characters, got %d", ErrDescriptionTooLong, MaxDescriptionLength, len(desc))
+	}
+	return nil
+}
+
+// ValidateTasks checks a whole task list for problems that single-task
+// operations would reject later: invalid IDs, duplicate IDs, invalid
+// descriptions and malformed references.
+// Returns nil if the list is valid, otherwise all problems joined together.
+func ValidateTasks(tasks []Task) error {
+	var problems []error
+	seen := make(map[int]bool, len(tasks))
+	for i, task := range tasks {
+		if err := ValidateID(task.ID); err != nil {
+			problems = append(problems, fmt.Errorf("task #%d: %w", i+1, err))
+		} else if seen[task.ID] {
+			problems = append(problems, fmt.Errorf("task #%d: duplicate ID %d", i+1, task.ID))
+		}
+		seen[task.ID] = true
+		if err := ValidateDescription(task.Description); err != nil {
+			problems = append(problems, fmt.Errorf("task ID %d: %w", task.ID, err))
+		}
+		if err := ValidateRef(task.Ref); err != nil {
+			problems = append(problems, fmt.Errorf("task ID %d: %w", task.ID, err))
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// ValidateRef validates an external task reference.
+// Any text is accepted as a plain reference like "JIRA-42", but a ref
+// starting with http:// or https:// must be a URL with a host.
+// Returns an error wrapping ErrInvalidRef if the URL is malformed.
+func ValidateRef(ref string) error {
+	lower := strings.ToLower(ref)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return nil
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("%w: '%s' is not a valid URL", ErrInvalidRef, ref)
 	}
 	return nil
 }
 
+// ValidatePriority validates that a priority is one of PriorityLow,
+// PriorityMedium, or PriorityHigh.
+// Returns an error wrapping ErrInvalidPriority for any other value.
+func ValidatePriority(priority string) error {
+	switch priority {
+	case PriorityLow, PriorityMedium, PriorityHigh:
+		return nil
+	default:
+		return fmt.Errorf("%w: '%s' (use %s, %s or %s)", ErrInvalidPriority, priority, PriorityLow, PriorityMedium, PriorityHigh)
+	}
+}
+
+// ValidateSortKey validates that tasks can be sorted by the given key.
+// Returns an error if the key is not one of the supported sort keys.
+func ValidateSortKey(key string) error {
+	switch key {
+	case "id", "order", "description", "status", "random":
+		return nil
+	default:
+		return fmt.Errorf("unsupported sort key '%s'", key)
+	}
+}
+
+// ValidateGroupKey validates that tasks can be grouped by the given key.
+// Returns an error if the key is not one of the supported group keys.
+func ValidateGroupKey(key string) error {
+	switch key {
+	case "status", "priority", "tag":
+		return nil
+	default:
+		return fmt.Errorf("unsupported group key '%s', expected %s", key, strings.Join(GroupKeys, ", "))
+	}
+}
+
 // findTaskByID searches for a task by its ID in the task slice.
 // Returns the index of the task if found, or -1 if not found.
 func findTaskByID(tasks []Task, id int) int {
@@ -133,4 +778,4 @@ func findTaskByID(tasks []Task, id int) int {
 		}
 	}
 	return -1
-}
\ No newline at end of file
+}
@@ -1,18 +1,25 @@
 package todo
 
 import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAdd(t *testing.T) {
 	tasks := []Task{}
 
 	// Test adding first task
-	var err error
-	tasks, err = Add(tasks, "First task")
+	tasks, created, err := Add(tasks, "First task", PriorityMedium)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
+	if created.ID != 1 || created.Description != "First task" {
+		t.Errorf("Expected created task with ID 1, got %+v", created)
+	}
 	if len(tasks) != 1 {
 		t.Errorf("Expected 1 task, got %d", len(tasks))
 	}
@@ -27,10 +34,13 @@ func TestAdd(t *testing.T) {
 	}
 
 	// Test adding second task
-	tasks, err = Add(tasks, "Second task")
+	tasks, created, err = Add(tasks, "Second task", PriorityMedium)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
+	if created.ID != 2 {
+		t.Errorf("Expected created task with ID 2, got %d", created.ID)
+	}
 	if len(tasks) != 2 {
 		t.Errorf("Expected 2 tasks, got %d", len(tasks))
 	}
@@ -70,6 +80,19 @@ func TestList(t *testing.T) {
 		t.Error("Pending filter should return only not done tasks")
 	}
 
+	// Test "untagged" filter
+	tagged := []Task{
+		{ID: 1, Description: "Task 1", Tags: []string{"work"}},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3", Tags: []string{}},
+		{ID: 4, Description: "Task 4", Tags: []string{"home", "urgent"}, Done: true},
+		{ID: 5, Description: "Task 5", Done: true},
+	}
+	untagged := List(tagged, "untagged")
+	if len(untagged) != 3 || untagged[0].ID != 2 || untagged[1].ID != 3 || untagged[2].ID != 5 {
+		t.Errorf("Expected untagged tasks 2, 3 and 5, got %+v", untagged)
+	}
+
 	// Test unknown filter (should return all)
 	unknownTasks := List(tasks, "unknown")
 	if len(unknownTasks) != 3 {
@@ -102,6 +125,39 @@ func TestComplete(t *testing.T) {
 	}
 }
 
+func TestUncomplete(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1", Done: true},
+		{ID: 2, Description: "Task 2", Done: false},
+	}
+
+	// Тест: выполненная задача снова становится активной
+	result, err := Uncomplete(tasks, 1)
+	if err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if result[0].Done {
+		t.Error("Task should be marked as pending")
+	}
+
+	// Тест: повторный вызов для активной задачи не является ошибкой
+	result, err = Uncomplete(result, 2)
+	if err != nil {
+		t.Errorf("Should not error when uncompleting pending task: %v", err)
+	}
+	if result[1].Done {
+		t.Error("Task should remain pending")
+	}
+
+	// Тест: несуществующий и некорректный ID
+	if _, err := Uncomplete(tasks, 999); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+	if _, err := Uncomplete(tasks, 0); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	tasks := []Task{
 		{ID: 1, Description: "Task 1", Done: false},
@@ -128,10 +184,10 @@ func TestDelete(t *testing.T) {
 	}
 }
 
-func TestGenerateID(t *testing.T) {
+func TestNextID(t *testing.T) {
 	// Test empty tasks
 	emptyTasks := []Task{}
-	if id := generateID(emptyTasks); id != 1 {
+	if id := NextID(emptyTasks); id != 1 {
 		t.Errorf("Expected ID 1 for empty tasks, got %d", id)
 	}
 
@@ -141,11 +197,430 @@ func TestGenerateID(t *testing.T) {
 		{ID: 5, Description: "Task 5", Done: false}, // Gap in IDs
 		{ID: 3, Description: "Task 3", Done: false},
 	}
-	if id := generateID(tasks); id != 6 {
+	if id := NextID(tasks); id != 6 {
 		t.Errorf("Expected ID 6 (max+1), got %d", id)
 	}
 }
 
+func TestClone(t *testing.T) {
+	due := time.Date(2024, 6, 1, 23, 59, 59, 0, time.UTC)
+	completedAt := time.Date(2024, 5, 30, 12, 0, 0, 0, time.UTC)
+	original := Task{ID: 1, Description: "Task 1", Done: true, Tags: []string{"work"}, DueDate: &due, CompletedAt: &completedAt}
+
+	// Тест: изменение копии не затрагивает оригинал
+	clone := original.Clone()
+	clone.Tags[0] = "home"
+	*clone.DueDate = due.AddDate(0, 0, 1)
+	*clone.CompletedAt = completedAt.Add(time.Hour)
+	if original.Tags[0] != "work" || !original.DueDate.Equal(due) || !original.CompletedAt.Equal(completedAt) {
+		t.Errorf("Changing the clone modified the original: %+v", original)
+	}
+	if copies := CloneTasks(nil); copies != nil {
+		t.Errorf("Expected nil for nil slice, got %+v", copies)
+	}
+
+	// Тест: изменяющие функции не трогают переданный срез
+	tasks := []Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2", Tags: []string{"work"}},
+		{ID: 3, Description: "Task 3"},
+	}
+	if _, err := Complete(tasks, 1); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	if _, err := Delete(tasks, 1); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := SetDueDate(tasks, 3, &due); err != nil {
+		t.Fatalf("SetDueDate failed: %v", err)
+	}
+	if tasks[0].Done || tasks[0].ID != 1 || tasks[1].ID != 2 || tasks[2].ID != 3 || tasks[2].DueDate != nil {
+		t.Errorf("Expected input slice to stay unchanged, got %+v", tasks)
+	}
+
+	// Тест: общий указатель на срок не разделяется между задачами
+	result, _ := SetDueDate(tasks, 1, &due)
+	result, _ = SetDueDate(result, 3, &due)
+	*result[0].DueDate = due.AddDate(1, 0, 0)
+	if !result[2].DueDate.Equal(due) {
+		t.Errorf("Tasks must not share a due date pointer, got %v", result[2].DueDate)
+	}
+}
+
+func TestClearCompleted(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1", Done: true},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3", Done: true},
+		{ID: 4, Description: "Task 4"},
+	}
+
+	// Тест: удаляются только выполненные задачи, порядок сохраняется
+	remaining, removed := ClearCompleted(tasks)
+	if removed != 2 || len(remaining) != 2 || remaining[0].ID != 2 || remaining[1].ID != 4 {
+		t.Errorf("Expected tasks 2 and 4 to remain, got %+v (removed %d)", remaining, removed)
+	}
+
+	// Тест: без выполненных задач возвращается исходный срез
+	remaining, removed = ClearCompleted(remaining)
+	if removed != 0 || len(remaining) != 2 {
+		t.Errorf("Expected nothing to be removed, got %+v (removed %d)", remaining, removed)
+	}
+
+	// Тест: все задачи выполнены — пустой, но не nil список
+	remaining, removed = ClearCompleted([]Task{{ID: 1, Description: "Done", Done: true}})
+	if removed != 1 || remaining == nil || len(remaining) != 0 {
+		t.Errorf("Expected an empty list, got %#v (removed %d)", remaining, removed)
+	}
+}
+
+func TestDeleteCompletedBefore(t *testing.T) {
+	cutoff := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	before := cutoff.Add(-time.Second)
+	at := cutoff
+	tasks := []Task{
+		{ID: 1, Description: "Done before", Done: true, CompletedAt: &before},
+		{ID: 2, Description: "Done at cutoff", Done: true, CompletedAt: &at},
+		{ID: 3, Description: "Pending"},
+		{ID: 4, Description: "Done, time unknown", Done: true},
+	}
+
+	// Тест: удаляются только задачи, выполненные до даты
+	remaining, removed := DeleteCompletedBefore(tasks, cutoff)
+	if removed != 1 || len(remaining) != 3 || remaining[0].ID != 2 || remaining[1].ID != 3 || remaining[2].ID != 4 {
+		t.Errorf("Expected only task 1 to be removed, got %+v (removed %d)", remaining, removed)
+	}
+
+	// Тест: без подходящих задач возвращается исходный срез
+	remaining, removed = DeleteCompletedBefore(remaining, cutoff)
+	if removed != 0 || len(remaining) != 3 {
+		t.Errorf("Expected nothing to be removed, got %+v (removed %d)", remaining, removed)
+	}
+}
+
+func TestAddUnique(t *testing.T) {
+	tasks := []Task{{ID: 1, Description: "Buy milk", Priority: PriorityMedium}}
+
+	// Тест: дубликат без учёта регистра и пробелов
+	result, _, err := AddUnique(tasks, "  BUY MILK ", PriorityMedium)
+	if !errors.Is(err, ErrDuplicateTask) {
+		t.Errorf("Expected ErrDuplicateTask, got %v", err)
+	}
+	if len(result) != 1 {
+		t.Errorf("Expected no task to be added, got %d tasks", len(result))
+	}
+
+	// Тест: новое описание добавляется
+	result, task, err := AddUnique(tasks, "Buy bread", PriorityMedium)
+	if err != nil {
+		t.Fatalf("AddUnique failed: %v", err)
+	}
+	if len(result) != 2 || task.ID != 2 {
+		t.Errorf("Expected task 2 to be added, got %+v", result)
+	}
+
+	// Тест: дубликаты внутри одного вызова AddManyUnique
+	result, created, errs := AddManyUnique(tasks, []string{"Call mom", "call mom", "Buy milk"}, NextID)
+	if len(created) != 1 || len(result) != 2 || len(errs) != 2 {
+		t.Errorf("Expected one created task and two duplicates, got %+v, %v", created, errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrDuplicateTask) {
+			t.Errorf("Expected ErrDuplicateTask, got %v", err)
+		}
+	}
+
+	// Тест: AddMany по-прежнему добавляет дубликаты
+	if _, created, _ := AddMany(tasks, []string{"Buy milk"}, NextID); len(created) != 1 {
+		t.Errorf("Expected AddMany to keep adding duplicates, got %+v", created)
+	}
+}
+
+func TestMove(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3"},
+		{ID: 4, Description: "Task 4"},
+	}
+	ids := func(tasks []Task) string {
+		var parts []string
+		for _, task := range tasks {
+			parts = append(parts, strconv.Itoa(task.ID))
+		}
+		return strings.Join(parts, ",")
+	}
+
+	tests := []struct {
+		id, position int
+		expected     string
+	}{
+		{3, 1, "3,1,2,4"},
+		{1, 4, "2,3,4,1"},
+		{2, 3, "1,3,2,4"},
+		{4, 2, "1,4,2,3"},
+		{2, 2, "1,2,3,4"},
+	}
+	for _, tc := range tests {
+		moved, err := Move(tasks, tc.id, tc.position)
+		if err != nil {
+			t.Fatalf("Move(%d, %d) failed: %v", tc.id, tc.position, err)
+		}
+		if got := ids(moved); got != tc.expected {
+			t.Errorf("Move(%d, %d): expected %s, got %s", tc.id, tc.position, tc.expected, got)
+		}
+	}
+
+	// Тест: исходный срез не изменяется
+	if got := ids(tasks); got != "1,2,3,4" {
+		t.Errorf("Move must not modify the input, got %s", got)
+	}
+
+	// Тест: позиция вне диапазона — ошибка, а не перенос в край списка
+	for _, position := range []int{0, 5, -1} {
+		if _, err := Move(tasks, 1, position); !errors.Is(err, ErrInvalidPosition) {
+			t.Errorf("Move to %d: expected ErrInvalidPosition, got %v", position, err)
+		}
+	}
+	if _, err := Move(tasks, 9, 1); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestFilterIDRange(t *testing.T) {
+	var tasks []Task
+	for i := 1; i <= 6; i++ {
+		tasks = append(tasks, Task{ID: i * 5, Description: "Task " + strconv.Itoa(i*5), Done: i%2 == 0})
+	}
+	ids := func(tasks []Task) string {
+		var parts []string
+		for _, task := range tasks {
+			parts = append(parts, strconv.Itoa(task.ID))
+		}
+		return strings.Join(parts, ",")
+	}
+
+	// Тест: полный диапазон, границы включительно
+	if got := ids(Filter(tasks, IDRange(10, 20))); got != "10,15,20" {
+		t.Errorf("Expected 10,15,20, got %s", got)
+	}
+
+	// Тест: открытые диапазоны
+	if got := ids(Filter(tasks, IDRange(20, 0))); got != "20,25,30" {
+		t.Errorf("Expected 20,25,30 for after only, got %s", got)
+	}
+	if got := ids(Filter(tasks, IDRange(0, 12))); got != "5,10" {
+		t.Errorf("Expected 5,10 for before only, got %s", got)
+	}
+
+	// Тест: условия комбинируются
+	done := func(task Task) bool { return task.Done }
+	if got := ids(Filter(tasks, IDRange(10, 25), done)); got != "10,20" {
+		t.Errorf("Expected done tasks 10,20, got %s", got)
+	}
+	if got := Filter(tasks); len(got) != len(tasks) {
+		t.Errorf("Expected all tasks without predicates, got %d", len(got))
+	}
+}
+
+func TestStats(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1", Done: true},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3"},
+	}
+
+	// Тест: подсчёт по статусу
+	stats := Stats(tasks)
+	if stats != (TaskStats{Total: 3, Done: 1, Pending: 2}) {
+		t.Errorf("Expected 3 total, 1 done, 2 pending, got %+v", stats)
+	}
+
+	// Тест: пустой список и суммирование
+	if empty := Stats(nil); empty != (TaskStats{}) {
+		t.Errorf("Expected zero stats for no tasks, got %+v", empty)
+	}
+	if sum := stats.Add(stats); sum != (TaskStats{Total: 6, Done: 2, Pending: 4}) {
+		t.Errorf("Expected doubled stats, got %+v", sum)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Buy milk", Priority: PriorityMedium},
+		{ID: 5, Description: "Call mom", Done: true, Priority: PriorityMedium},
+	}
+	imported := []Task{
+		{ID: 1, Description: "Buy milk", Priority: PriorityMedium},                          // Дубликат
+		{ID: 2, Description: "Call mom", Priority: PriorityHigh},                            // Другой статус
+		{ID: 5, Description: "Write report", Priority: PriorityLow, Tags: []string{"work"}}, // Конфликт ID
+		{ID: 7, Description: "Write report", Priority: PriorityLow},                         // Дубликат внутри импорта
+	}
+
+	merged, skipped := Merge(tasks, imported, NextID)
+
+	// Тест: дубликаты пропущены
+	if skipped != 2 {
+		t.Errorf("Expected 2 skipped duplicates, got %d", skipped)
+	}
+	if len(merged) != 4 {
+		t.Fatalf("Expected 4 tasks, got %d: %+v", len(merged), merged)
+	}
+
+	// Тест: импортированные задачи получают новые ID и сохраняют остальные поля
+	if merged[2].ID != 6 || merged[2].Description != "Call mom" || merged[2].Done || merged[2].Priority != PriorityHigh {
+		t.Errorf("Unexpected first merged task: %+v", merged[2])
+	}
+	if merged[3].ID != 7 || merged[3].Description != "Write report" || len(merged[3].Tags) != 1 {
+		t.Errorf("Unexpected second merged task: %+v", merged[3])
+	}
+	if err := ValidateTasks(merged); err != nil {
+		t.Errorf("Merged tasks must have unique IDs: %v", err)
+	}
+	if imported[2].ID != 5 {
+		t.Error("Merge should not modify the imported tasks")
+	}
+}
+
+func TestAppendRenumbered(t *testing.T) {
+	tasks := []Task{
+		{ID: 2, Description: "Buy milk"},
+		{ID: 7, Description: "Call mom", Done: true},
+	}
+	imported := []Task{
+		{ID: 7, Description: "Write report", Tags: []string{"work"}},
+		{ID: 1, Description: "Buy milk"},
+		{ID: 100, Description: "Pay bills", Done: true},
+	}
+
+	result := AppendRenumbered(tasks, imported)
+
+	// Тест: новые ID идут подряд после максимального, порядок и поля сохранены
+	expected := []Task{
+		{ID: 2, Description: "Buy milk"},
+		{ID: 7, Description: "Call mom", Done: true},
+		{ID: 8, Description: "Write report", Tags: []string{"work"}},
+		{ID: 9, Description: "Buy milk"},
+		{ID: 10, Description: "Pay bills", Done: true},
+	}
+	if len(result) != len(expected) {
+		t.Fatalf("Expected %d tasks, got %d: %+v", len(expected), len(result), result)
+	}
+	for i := range expected {
+		if result[i].ID != expected[i].ID || result[i].Description != expected[i].Description || result[i].Done != expected[i].Done {
+			t.Errorf("Task %d: expected %+v, got %+v", i, expected[i], result[i])
+		}
+	}
+
+	// Тест: входные срезы не изменяются
+	if imported[0].ID != 7 || len(tasks) != 2 {
+		t.Error("AppendRenumbered should not modify its inputs")
+	}
+
+	// Тест: пустой текущий список начинается с MinID
+	if result := AppendRenumbered(nil, imported); result[0].ID != MinID || result[2].ID != MinID+2 {
+		t.Errorf("Expected IDs from %d, got %+v", MinID, result)
+	}
+}
+
+func TestLowestFreeID(t *testing.T) {
+	// Тест: пустой список
+	if id := LowestFreeID([]Task{}); id != MinID {
+		t.Errorf("Expected ID %d for empty tasks, got %d", MinID, id)
+	}
+
+	// Тест: заполняется пропуск в ID
+	tasks := []Task{
+		{ID: 4, Description: "Task 4"},
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2"},
+	}
+	if id := LowestFreeID(tasks); id != 3 {
+		t.Errorf("Expected ID 3 (first gap), got %d", id)
+	}
+
+	// Тест: без пропусков — следующий после максимального
+	tasks = append(tasks, Task{ID: 3, Description: "Task 3"})
+	if id := LowestFreeID(tasks); id != 5 {
+		t.Errorf("Expected ID 5 (no gaps), got %d", id)
+	}
+
+	// Тест: AddMany и Merge используют переданную стратегию
+	tasks = []Task{{ID: 1, Description: "Task 1"}, {ID: 3, Description: "Task 3"}}
+	_, created, errs := AddMany(tasks, []string{"Task 2", "Task 4"}, LowestFreeID)
+	if len(errs) != 0 || len(created) != 2 || created[0].ID != 2 || created[1].ID != 4 {
+		t.Errorf("Expected reused ID 2 and then 4, got %+v, %v", created, errs)
+	}
+	if merged, _ := Merge(tasks, []Task{{ID: 9, Description: "Imported"}}, LowestFreeID); merged[2].ID != 2 {
+		t.Errorf("Expected imported task to get ID 2, got %+v", merged)
+	}
+	if _, task, _ := Add(tasks, "Task 4", PriorityMedium); task.ID != 4 {
+		t.Errorf("Expected ID 4 (max+1) from Add, got %d", task.ID)
+	}
+}
+
+func TestResolveLastIsNewest(t *testing.T) {
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tasks := []Task{
+		{ID: 1, Description: "Task 1", CreatedAt: base},
+		{ID: 2, Description: "Reused ID", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: 3, Description: "Task 3", CreatedAt: base.Add(time.Hour)},
+	}
+
+	// Тест: "last" — последняя созданная задача, а не наибольший ID
+	if id, err := ResolveID(tasks, LastIDToken); err != nil || id != 2 {
+		t.Errorf("Expected newest task 2, got %d, %v", id, err)
+	}
+
+	// Тест: без CreatedAt — наибольший ID
+	legacy := []Task{{ID: 4, Description: "A"}, {ID: 7, Description: "B"}, {ID: 5, Description: "C"}}
+	if id, err := ResolveID(legacy, LastIDToken); err != nil || id != 7 {
+		t.Errorf("Expected highest ID 7 without CreatedAt, got %d, %v", id, err)
+	}
+}
+
+func TestSince(t *testing.T) {
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tasks := []Task{
+		{ID: 1, Description: "Reused ID", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: 5, Description: "Old", CreatedAt: base},
+		{ID: 6, Description: "Legacy"},
+	}
+
+	// Тест: новые задачи определяются по CreatedAt, а не по ID
+	since := Since(tasks, base.Add(time.Hour))
+	if len(since) != 1 || since[0].ID != 1 {
+		t.Errorf("Expected only task 1, got %+v", since)
+	}
+
+	// Тест: нулевая метка — все задачи, включая задачи без CreatedAt
+	if since := Since(tasks, time.Time{}); len(since) != 3 {
+		t.Errorf("Expected all 3 tasks for zero time, got %+v", since)
+	}
+}
+
+func TestIsStale(t *testing.T) {
+	at := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	maxAge := DefaultMaxAge
+
+	// Тест: задачи по обе стороны от порога
+	older := Task{ID: 1, Description: "Old", CreatedAt: at.Add(-maxAge - time.Minute)}
+	younger := Task{ID: 2, Description: "Recent", CreatedAt: at.Add(-maxAge + time.Minute)}
+	if !IsStale(older, maxAge, at) {
+		t.Error("Expected a task older than max age to be stale")
+	}
+	if IsStale(younger, maxAge, at) {
+		t.Error("Expected a task younger than max age not to be stale")
+	}
+
+	// Тест: выполненные задачи, задачи без CreatedAt и нулевой порог
+	done := older
+	done.Done = true
+	if IsStale(done, maxAge, at) || IsStale(Task{ID: 3, Description: "Legacy"}, maxAge, at) || IsStale(older, 0, at) {
+		t.Error("Expected done, legacy and disabled checks not to be stale")
+	}
+}
+
 func TestCompleteEdgeCases(t *testing.T) {
 	tasks := []Task{
 		{ID: 1, Description: "Task 1", Done: false},
@@ -237,14 +712,14 @@ func TestAddValidation(t *testing.T) {
 	tasks := []Task{}
 
 	// Тест: добавление с пустым описанием
-	_, err := Add(tasks, "")
+	_, _, err := Add(tasks, "", PriorityMedium)
 	if err == nil {
 		t.Error("Expected error for empty description")
 	}
 
 	// Тест: добавление с описанием превышающим максимальную длину
 	tooLongDesc := string(make([]byte, MaxDescriptionLength+1))
-	_, err = Add(tasks, tooLongDesc)
+	_, _, err = Add(tasks, tooLongDesc, PriorityMedium)
 	if err == nil {
 		t.Error("Expected error for description exceeding max length")
 	}
@@ -296,4 +771,974 @@ func TestDeleteValidation(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for negative ID")
 	}
-}
\ No newline at end of file
+}
+func TestGroupBy(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 2, Description: "Task 2", Done: true},
+		{ID: 3, Description: "Task 3", Done: false},
+	}
+
+	// Тест: группировка по статусу
+	groups, err := GroupBy(tasks, "status")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups["pending"]) != 2 {
+		t.Errorf("Expected 2 pending tasks, got %d", len(groups["pending"]))
+	}
+	if len(groups["done"]) != 1 {
+		t.Errorf("Expected 1 done task, got %d", len(groups["done"]))
+	}
+
+	// Тест: пустой список не создает групп
+	groups, err = GroupBy([]Task{}, "status")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("Expected no groups for empty list, got %d", len(groups))
+	}
+
+	// Тест: группировка по приоритету, без приоритета — medium
+	tasks = []Task{
+		{ID: 1, Description: "Task 1", Priority: PriorityHigh, Tags: []string{"work", "urgent"}},
+		{ID: 2, Description: "Task 2", Tags: []string{"work"}},
+		{ID: 3, Description: "Task 3", Priority: PriorityLow},
+	}
+	groups, err = GroupBy(tasks, "priority")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups) != 3 || len(groups[PriorityHigh]) != 1 || len(groups[PriorityMedium]) != 1 || len(groups[PriorityLow]) != 1 {
+		t.Errorf("Expected one task per priority, got %v", groups)
+	}
+
+	// Тест: задача с несколькими тегами входит в группу каждого тега
+	groups, err = GroupBy(tasks, "tag")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(groups) != 3 || len(groups["work"]) != 2 || len(groups["urgent"]) != 1 || len(groups[UntaggedGroup]) != 1 || groups[UntaggedGroup][0].ID != 3 {
+		t.Errorf("Expected work: 2, urgent: 1, %s: 1, got %v", UntaggedGroup, groups)
+	}
+
+	// Тест: неподдерживаемый ключ
+	if _, err := GroupBy(tasks, "unknown"); err == nil {
+		t.Error("Expected error for unsupported group key")
+	}
+}
+
+func TestTaskLabel(t *testing.T) {
+	pending := Task{ID: 1, Description: "Task 1", Done: false}
+	done := Task{ID: 2, Description: "Task 2", Done: true}
+
+	// Тест: по умолчанию описание не меняется
+	if label := done.Label(""); label != "Task 2" {
+		t.Errorf("Expected unchanged label by default, got '%s'", label)
+	}
+
+	// Тест: суффикс добавляется только к выполненным задачам
+	if label := done.Label(" (done)"); label != "Task 2 (done)" {
+		t.Errorf("Expected 'Task 2 (done)', got '%s'", label)
+	}
+	if label := pending.Label(" (done)"); label != "Task 1" {
+		t.Errorf("Pending task label should be unchanged, got '%s'", label)
+	}
+
+	// Тест: исходное описание не изменяется
+	if done.Description != "Task 2" {
+		t.Errorf("Label should not modify description, got '%s'", done.Description)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Buy milk", Done: false},
+		{ID: 2, Description: "Learn Go", Done: true},
+		{ID: 3, Description: "Write tests", Done: false},
+	}
+
+	// Тест: выборка и проекция
+	results, err := Query(tasks, ".[] | select(.done==false) | .id")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(results, ",") != "1,3" {
+		t.Errorf("Expected IDs 1,3, got %v", results)
+	}
+
+	// Тест: строки выводятся без кавычек
+	results, err = Query(tasks, `.[] | select(.id!=2) | .description`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(results, ",") != "Buy milk,Write tests" {
+		t.Errorf("Expected raw descriptions, got %v", results)
+	}
+
+	// Тест: выборка по строковому значению без проекции
+	results, err = Query(tasks, `select(.description=="Learn Go")`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0] != `{"id":2,"description":"Learn Go","done":true}` {
+		t.Errorf("Expected single task as JSON, got %v", results)
+	}
+
+	// Тест: | и != внутри строкового литерала не разделяют выражение
+	piped := []Task{
+		{ID: 1, Description: "a|b", Done: false},
+		{ID: 2, Description: `x!="y"`, Done: false},
+		{ID: 3, Description: "plain", Done: false},
+	}
+	results, err = Query(piped, `.[] | select(.description=="a|b") | .id`)
+	if err != nil || strings.Join(results, ",") != "1" {
+		t.Errorf("Expected ID 1 for a pipe in a literal, got %v, %v", results, err)
+	}
+	results, err = Query(piped, `.[] | select(.description=="x!=\"y\"") | .id`)
+	if err != nil || strings.Join(results, ",") != "2" {
+		t.Errorf("Expected ID 2 for != and escaped quotes in a literal, got %v, %v", results, err)
+	}
+	results, err = Query(piped, `.[] | select(.description!="a|b") | .id`)
+	if err != nil || strings.Join(results, ",") != "2,3" {
+		t.Errorf("Expected IDs 2,3 for != with a pipe in the literal, got %v, %v", results, err)
+	}
+
+	// Тест: некорректные выражения
+	invalid := []string{"", ".unknown", ".id | .done", "select(.done)", "select(.done==maybe)", "length"}
+	for _, expr := range invalid {
+		if _, err := Query(tasks, expr); err == nil {
+			t.Errorf("Expected error for expression '%s'", expr)
+		}
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1", Done: false},
+	}
+
+	// Тест: несуществующая задача
+	if _, err := Complete(tasks, 999); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Complete: expected ErrTaskNotFound, got %v", err)
+	}
+	if _, err := Delete(tasks, 999); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Delete: expected ErrTaskNotFound, got %v", err)
+	}
+
+	// Тест: невалидный ID
+	if _, err := Complete(tasks, 0); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Complete: expected ErrInvalidID, got %v", err)
+	}
+	if _, err := Delete(tasks, -1); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Delete: expected ErrInvalidID, got %v", err)
+	}
+
+	// Тест: невалидное описание
+	if _, _, err := Add(tasks, "", PriorityMedium); !errors.Is(err, ErrEmptyDescription) {
+		t.Errorf("Add: expected ErrEmptyDescription, got %v", err)
+	}
+	tooLongDesc := string(make([]byte, MaxDescriptionLength+1))
+	if _, _, err := Add(tasks, tooLongDesc, PriorityMedium); !errors.Is(err, ErrDescriptionTooLong) {
+		t.Errorf("Add: expected ErrDescriptionTooLong, got %v", err)
+	}
+
+	// Тест: ошибки разных видов не путаются
+	if _, err := Complete(tasks, 999); errors.Is(err, ErrInvalidID) {
+		t.Error("Not found error should not match ErrInvalidID")
+	}
+}
+
+func TestResolveID(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1", Done: false},
+		{ID: 7, Description: "Task 7", Done: false},
+		{ID: 3, Description: "Task 3", Done: false},
+	}
+
+	// Тест: "last" указывает на задачу с максимальным ID
+	id, err := ResolveID(tasks, "last")
+	if err != nil || id != 7 {
+		t.Errorf("Expected ID 7 for 'last', got %d (err: %v)", id, err)
+	}
+
+	// Тест: числовой ID
+	id, err = ResolveID(tasks, "3")
+	if err != nil || id != 3 {
+		t.Errorf("Expected ID 3, got %d (err: %v)", id, err)
+	}
+
+	// Тест: "last" в пустом списке
+	if _, err := ResolveID([]Task{}, "last"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound for 'last' on empty list, got %v", err)
+	}
+
+	// Тест: некорректные значения
+	for _, token := range []string{"abc", "0", "-2", ""} {
+		if _, err := ResolveID(tasks, token); !errors.Is(err, ErrInvalidID) {
+			t.Errorf("Expected ErrInvalidID for '%s', got %v", token, err)
+		}
+	}
+}
+
+func TestSetRef(t *testing.T) {
+	tasks := []Task{{ID: 1, Description: "Task 1"}}
+
+	// Тест: ссылка на тикет сохраняется
+	tasks, err := SetRef(tasks, 1, "https://tracker.example.com/issues/42")
+	if err != nil {
+		t.Fatalf("SetRef failed: %v", err)
+	}
+	if tasks[0].Ref != "https://tracker.example.com/issues/42" {
+		t.Errorf("Expected ref to be set, got '%s'", tasks[0].Ref)
+	}
+
+	// Тест: ключ задачи без схемы принимается как есть
+	if _, err := SetRef(tasks, 1, "JIRA-42"); err != nil {
+		t.Errorf("Expected plain issue key to be accepted, got %v", err)
+	}
+
+	// Тест: некорректный URL отклоняется
+	for _, ref := range []string{"http://", "https://", "http://%zz"} {
+		if _, err := SetRef(tasks, 1, ref); !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("Expected ErrInvalidRef for '%s', got %v", ref, err)
+		}
+	}
+
+	// Тест: несуществующая задача
+	if _, err := SetRef(tasks, 99, "JIRA-1"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestValidateTasks(t *testing.T) {
+	// Тест: корректный список
+	valid := []Task{{ID: 1, Description: "Task 1"}, {ID: 2, Description: "Task 2", Ref: "JIRA-1"}}
+	if err := ValidateTasks(valid); err != nil {
+		t.Errorf("Expected valid list, got %v", err)
+	}
+
+	// Тест: все проблемы собираются в одну ошибку
+	invalid := []Task{
+		{ID: 0, Description: "Zero"},
+		{ID: 2, Description: ""},
+		{ID: 2, Description: "Duplicate"},
+		{ID: 3, Description: "Bad ref", Ref: "https://"},
+	}
+	err := ValidateTasks(invalid)
+	if err == nil {
+		t.Fatal("Expected validation error")
+	}
+	for _, target := range []error{ErrInvalidID, ErrEmptyDescription, ErrInvalidRef} {
+		if !errors.Is(err, target) {
+			t.Errorf("Expected error to wrap %v, got %v", target, err)
+		}
+	}
+	if !strings.Contains(err.Error(), "duplicate ID 2") {
+		t.Errorf("Expected duplicate ID to be reported, got %v", err)
+	}
+}
+
+func TestMatchPending(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Buy milk", Done: false},
+		{ID: 2, Description: "Buy bread", Done: false},
+		{ID: 3, Description: "Call mom", Done: false},
+		{ID: 4, Description: "Write report", Done: true},
+	}
+
+	// Тест: однозначное совпадение, регистр и опечатки не мешают
+	for _, query := range []string{"buy milk", "Buy mlk", "mom"} {
+		task, err := MatchPending(tasks, query)
+		if err != nil {
+			t.Errorf("MatchPending(%q) failed: %v", query, err)
+			continue
+		}
+		expected := 1
+		if query == "mom" {
+			expected = 3
+		}
+		if task.ID != expected {
+			t.Errorf("MatchPending(%q): expected task %d, got %d", query, expected, task.ID)
+		}
+	}
+
+	// Тест: несколько одинаково близких задач
+	if _, err := MatchPending(tasks, "buy"); !errors.Is(err, ErrAmbiguousMatch) {
+		t.Errorf("Expected ErrAmbiguousMatch, got %v", err)
+	}
+
+	// Тест: нет совпадений, выполненные задачи не учитываются
+	for _, query := range []string{"xyz", "write report"} {
+		if _, err := MatchPending(tasks, query); !errors.Is(err, ErrNoMatch) {
+			t.Errorf("MatchPending(%q): expected ErrNoMatch, got %v", query, err)
+		}
+	}
+}
+
+func TestTaskLeanJSON(t *testing.T) {
+	// Тест: для минимальной задачи пишутся только обязательные поля
+	data, err := json.Marshal(Task{ID: 1, Description: "Buy milk"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"id":1,"description":"Buy milk"}` {
+		t.Errorf("Unexpected lean JSON: %s", data)
+	}
+
+	// Тест: описание обязательно даже пустое
+	data, _ = json.Marshal(Task{ID: 2})
+	if string(data) != `{"id":2,"description":""}` {
+		t.Errorf("Expected description to be kept, got %s", data)
+	}
+
+	// Тест: заполненные поля сохраняются
+	data, _ = json.Marshal(Task{ID: 3, Description: "Ship", Done: true, Ref: "JIRA-1"})
+	if string(data) != `{"id":3,"description":"Ship","done":true,"ref":"JIRA-1"}` {
+		t.Errorf("Unexpected full JSON: %s", data)
+	}
+
+	// Тест: старые файлы со всеми полями читаются как прежде
+	var legacy []Task
+	if err := json.Unmarshal([]byte(`[{"id":1,"description":"Old","done":false},{"id":2,"description":"Older","done":true}]`), &legacy); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(legacy) != 2 || legacy[0].Done || !legacy[1].Done {
+		t.Errorf("Unexpected legacy tasks: %+v", legacy)
+	}
+
+	// Тест: запросы по опущенным полям работают
+	results, err := Query([]Task{{ID: 1, Description: "Lean"}}, `select(.ref=="") | .done`)
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if strings.Join(results, ",") != "false" {
+		t.Errorf("Expected omitted fields to query as zero values, got %v", results)
+	}
+}
+
+func TestPinnedFirst(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Task 1"},
+		{ID: 2, Description: "Task 2"},
+		{ID: 3, Description: "Task 3"},
+		{ID: 4, Description: "Task 4"},
+	}
+
+	// Тест: закрепление и открепление
+	tasks, err := SetPinned(tasks, 4, true)
+	if err != nil {
+		t.Fatalf("SetPinned failed: %v", err)
+	}
+	tasks, _ = SetPinned(tasks, 2, true)
+	if _, err := SetPinned(tasks, 99, true); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+
+	// Тест: закреплённые задачи идут первыми, порядок внутри групп сохраняется
+	var ids []string
+	for _, task := range PinnedFirst(tasks) {
+		ids = append(ids, strconv.Itoa(task.ID))
+	}
+	if strings.Join(ids, ",") != "2,4,1,3" {
+		t.Errorf("Expected order 2,4,1,3, got %v", ids)
+	}
+
+	// Тест: исходный срез не меняется
+	if tasks[0].ID != 1 {
+		t.Errorf("PinnedFirst must not reorder the input, got %+v", tasks)
+	}
+
+	tasks, _ = SetPinned(tasks, 2, false)
+	if PinnedFirst(tasks)[0].ID != 4 {
+		t.Errorf("Expected only task 4 to stay pinned, got %+v", PinnedFirst(tasks))
+	}
+}
+
+func TestSort(t *testing.T) {
+	tasks := []Task{
+		{ID: 3, Description: "charlie", Done: true},
+		{ID: 1, Description: "Bravo", Done: false},
+		{ID: 5, Description: "alpha", Done: true},
+		{ID: 2, Description: "echo", Done: false},
+		{ID: 4, Description: "delta", Done: false},
+	}
+	ids := func(tasks []Task) string {
+		var parts []string
+		for _, task := range tasks {
+			parts = append(parts, strconv.Itoa(task.ID))
+		}
+		return strings.Join(parts, ",")
+	}
+
+	tests := map[string]string{
+		"id":          "1,2,3,4,5",
+		"order":       "3,1,5,2,4",
+		"description": "5,1,3,4,2",
+		"status":      "1,2,4,3,5",
+	}
+	for key, expected := range tests {
+		sorted, err := Sort(tasks, key, 0)
+		if err != nil {
+			t.Fatalf("Sort(%s) failed: %v", key, err)
+		}
+		if got := ids(sorted); got != expected {
+			t.Errorf("Sort(%s): expected %s, got %s", key, expected, got)
+		}
+	}
+
+	// Тест: случайный порядок воспроизводим при одинаковом seed
+	first, err := Sort(tasks, "random", 42)
+	if err != nil {
+		t.Fatalf("Sort(random) failed: %v", err)
+	}
+	second, _ := Sort(tasks, "random", 42)
+	if ids(first) != "1,4,5,2,3" || ids(second) != ids(first) {
+		t.Errorf("Expected deterministic order 1,4,5,2,3 for seed 42, got %s and %s", ids(first), ids(second))
+	}
+
+	// Тест: исходный срез не меняется
+	if ids(tasks) != "3,1,5,2,4" {
+		t.Errorf("Sort must not modify the input, got %s", ids(tasks))
+	}
+
+	if _, err := Sort(tasks, "priority", 0); err == nil {
+		t.Error("Expected error for unsupported sort key")
+	}
+}
+
+func TestFuzzyScoreNumbers(t *testing.T) {
+	// Тест: числа совпадают только целиком
+	if score := FuzzyScore("42", "42"); score != 1 {
+		t.Errorf("Expected exact number match to score 1, got %f", score)
+	}
+	for _, text := range []string{"421", "43", "Task 4"} {
+		if score := FuzzyScore("42", text); score >= MinFuzzyScore {
+			t.Errorf("Expected '42' not to match '%s', got %f", text, score)
+		}
+	}
+	if score := FuzzyScore("4", "42"); score != 0 {
+		t.Errorf("Expected '4' not to match '42' as a prefix, got %f", score)
+	}
+
+	// Тест: точное совпадение всего описания побеждает
+	tasks := []Task{{ID: 42, Description: "Task 42"}, {ID: 43, Description: "42"}}
+	task, err := MatchPending(tasks, "42")
+	if err != nil || task.ID != 43 {
+		t.Errorf("Expected exact description match to pick task 43, got %+v, %v", task, err)
+	}
+}
+
+func TestAddPriority(t *testing.T) {
+	tasks := []Task{}
+
+	// Тест: приоритет сохраняется в новой задаче
+	tasks, created, err := Add(tasks, "Urgent task", PriorityHigh)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if created.Priority != PriorityHigh || tasks[0].Priority != PriorityHigh {
+		t.Errorf("Expected priority '%s', got %+v", PriorityHigh, tasks[0])
+	}
+
+	// Тест: неизвестный приоритет отклоняется
+	for _, priority := range []string{"", "urgent", "HIGH"} {
+		if _, _, err := Add(tasks, "Task", priority); !errors.Is(err, ErrInvalidPriority) {
+			t.Errorf("Expected ErrInvalidPriority for '%s', got %v", priority, err)
+		}
+	}
+
+	// Тест: задача без поля priority в JSON получает средний приоритет
+	var legacy Task
+	if err := json.Unmarshal([]byte(`{"id":1,"description":"Old task"}`), &legacy); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if legacy.Priority != PriorityMedium {
+		t.Errorf("Expected default priority '%s', got '%s'", PriorityMedium, legacy.Priority)
+	}
+}
+
+func TestIsOverdue(t *testing.T) {
+	due, err := ParseDueDate("2024-06-01T15:00")
+	if err != nil {
+		t.Fatalf("ParseDueDate failed: %v", err)
+	}
+	task := Task{ID: 1, Description: "Report", DueDate: &due}
+
+	// Тест: задача просрочена только после срока
+	if IsOverdue(task, due.Add(-time.Minute)) {
+		t.Error("Task should not be overdue before its due date")
+	}
+	if !IsOverdue(task, due.Add(time.Minute)) {
+		t.Error("Task should be overdue after its due date")
+	}
+
+	// Тест: выполненная задача и задача без срока не просрочены
+	task.Done = true
+	if IsOverdue(task, due.Add(time.Hour)) {
+		t.Error("Completed task should never be overdue")
+	}
+	if IsOverdue(Task{ID: 2, Description: "No due date"}, time.Now()) {
+		t.Error("Task without due date should never be overdue")
+	}
+
+	// Тест: дата без времени означает конец дня
+	day, err := ParseDueDate("2024-06-01")
+	if err != nil {
+		t.Fatalf("ParseDueDate failed: %v", err)
+	}
+	task = Task{ID: 3, Description: "Today", DueDate: &day}
+	if IsOverdue(task, due) {
+		t.Error("Task due on a date should not be overdue during that day")
+	}
+	if !IsOverdue(task, time.Date(2024, 6, 2, 0, 0, 1, 0, time.Local)) {
+		t.Error("Task due on a date should be overdue the next day")
+	}
+
+	// Тест: некорректная дата
+	for _, value := range []string{"", "tomorrow", "2024-13-01", "01.06.2024"} {
+		if _, err := ParseDueDate(value); !errors.Is(err, ErrInvalidDueDate) {
+			t.Errorf("Expected ErrInvalidDueDate for '%s', got %v", value, err)
+		}
+	}
+}
+
+func TestAddMany(t *testing.T) {
+	tasks := []Task{{ID: 1, Description: "Existing"}}
+
+	// Тест: все описания корректны
+	tasks, created, errs := AddMany(tasks, []string{"First", "Second"}, NextID)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if len(created) != 2 || created[0].ID != 2 || created[1].ID != 3 {
+		t.Errorf("Expected tasks 2 and 3 to be created, got %+v", created)
+	}
+	if len(tasks) != 3 || tasks[2].Description != "Second" || tasks[2].Priority != PriorityMedium {
+		t.Errorf("Unexpected task list: %+v", tasks)
+	}
+
+	// Тест: некорректные описания пропускаются, остальные добавляются
+	tasks, created, errs = AddMany(tasks, []string{"", "Third", strings.Repeat("a", MaxDescriptionLength+1)}, NextID)
+	if len(created) != 1 || created[0].ID != 4 || created[0].Description != "Third" {
+		t.Errorf("Expected only task 4 to be created, got %+v", created)
+	}
+	if len(tasks) != 4 {
+		t.Errorf("Expected 4 tasks, got %d", len(tasks))
+	}
+	if len(errs) != 2 || !errors.Is(errs[0], ErrEmptyDescription) || !errors.Is(errs[1], ErrDescriptionTooLong) {
+		t.Fatalf("Expected empty and too long description errors, got %v", errs)
+	}
+	if !strings.Contains(errs[1].Error(), "#3") {
+		t.Errorf("Expected error to name description #3, got %v", errs[1])
+	}
+}
+
+func TestNext(t *testing.T) {
+	soon := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	later := soon.AddDate(0, 0, 7)
+	tasks := []Task{
+		{ID: 1, Description: "Low", Priority: PriorityLow},
+		{ID: 2, Description: "Medium later", Priority: PriorityMedium, DueDate: &later},
+		{ID: 3, Description: "High done", Priority: PriorityHigh, Done: true},
+		{ID: 4, Description: "Medium", Priority: PriorityMedium},
+		{ID: 5, Description: "Medium soon", Priority: PriorityMedium, DueDate: &soon},
+		{ID: 6, Description: "High", Priority: PriorityHigh},
+	}
+	ids := func(tasks []Task) string {
+		var parts []string
+		for _, task := range tasks {
+			parts = append(parts, strconv.Itoa(task.ID))
+		}
+		return strings.Join(parts, ",")
+	}
+
+	// Тест: одна рекомендация — задача с наивысшим приоритетом
+	if got := ids(Next(tasks, 1)); got != "6" {
+		t.Errorf("Expected task 6, got %s", got)
+	}
+
+	// Тест: при равном приоритете раньше идут задачи с ближайшим сроком
+	if got := ids(Next(tasks, 3)); got != "6,5,2" {
+		t.Errorf("Expected tasks 6,5,2, got %s", got)
+	}
+
+	// Тест: N больше числа активных задач — возвращаются все активные
+	if got := ids(Next(tasks, 10)); got != "6,5,2,4,1" {
+		t.Errorf("Expected all pending tasks 6,5,2,4,1, got %s", got)
+	}
+	if tasks[0].ID != 1 || tasks[5].ID != 6 {
+		t.Error("Next should not reorder the input slice")
+	}
+
+	// Тест: при равном приоритете и сроке порядок хранения (move) не важен, меньший ID первый
+	moved, _ := Move(tasks, 6, 1)
+	moved, _ = SetPriority(moved, 4, PriorityHigh)
+	if got := ids(Next(moved, 2)); got != "4,6" {
+		t.Errorf("Expected ID order 4,6 among equal tasks, got %s", got)
+	}
+}
+
+func TestApplyTemplate(t *testing.T) {
+	task := Task{ID: 1, Description: "Crash on start", Priority: PriorityMedium, Tags: []string{"ui"}}
+	tmpl := Template{Name: "bug", Priority: PriorityHigh, Tags: []string{"Bug", "ui", "urgent"}}
+
+	// Тест: приоритет шаблона заменяет приоритет задачи, теги объединяются
+	got := ApplyTemplate(task, tmpl)
+	if got.Priority != PriorityHigh || strings.Join(got.Tags, ",") != "ui,bug,urgent" {
+		t.Errorf("Expected high priority and tags ui,bug,urgent, got %+v", got)
+	}
+	if got.ID != 1 || got.Description != "Crash on start" {
+		t.Errorf("Expected ID and description to be kept, got %+v", got)
+	}
+
+	// Тест: исходная задача не изменяется
+	if task.Priority != PriorityMedium || strings.Join(task.Tags, ",") != "ui" {
+		t.Errorf("ApplyTemplate should not modify its input, got %+v", task)
+	}
+
+	// Тест: шаблон без приоритета оставляет приоритет задачи
+	if got := ApplyTemplate(task, Template{Name: "tagged", Tags: []string{"x"}}); got.Priority != PriorityMedium {
+		t.Errorf("Expected medium priority, got %s", got.Priority)
+	}
+
+	// Тест: проверка шаблона
+	if err := ValidateTemplate(Template{Name: " "}); err == nil {
+		t.Error("Expected error for empty template name")
+	}
+	if err := ValidateTemplate(Template{Name: "bug", Priority: "urgent"}); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Buy milk"},
+		{ID: 2, Description: "Call mom", Done: true},
+		{ID: 3, Description: "Buy MILK powder"},
+	}
+
+	// Тест: поиск подстроки без учёта регистра
+	if got := Search(tasks, "milk"); len(got) != 2 || got[0].ID != 1 || got[1].ID != 3 {
+		t.Errorf("Expected tasks 1 and 3, got %+v", got)
+	}
+
+	// Тест: выполненные задачи тоже ищутся
+	if got := Search(tasks, "MOM"); len(got) != 1 || got[0].ID != 2 {
+		t.Errorf("Expected task 2, got %+v", got)
+	}
+
+	// Тест: нет совпадений
+	if got := Search(tasks, "bread"); len(got) != 0 {
+		t.Errorf("Expected no tasks, got %+v", got)
+	}
+
+	// Тест: регулярное выражение
+	got, err := SearchRegex(tasks, `^Buy \w+$`)
+	if err != nil {
+		t.Fatalf("SearchRegex failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("Expected task 1, got %+v", got)
+	}
+
+	// Тест: некорректное регулярное выражение
+	if _, err := SearchRegex(tasks, "(milk"); !errors.Is(err, ErrInvalidPattern) {
+		t.Errorf("Expected ErrInvalidPattern, got %v", err)
+	}
+}
+
+func TestOverdue(t *testing.T) {
+	clock := time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC)
+	origNow := now
+	now = func() time.Time { return clock }
+	defer func() { now = origNow }()
+
+	yesterday := clock.AddDate(0, 0, -1)
+	tomorrow := clock.AddDate(0, 0, 1)
+	tasks := []Task{
+		{ID: 1, Description: "Due yesterday", DueDate: &yesterday},
+		{ID: 2, Description: "Due tomorrow", DueDate: &tomorrow},
+		{ID: 3, Description: "No due date"},
+		{ID: 4, Description: "Done late", Done: true, DueDate: &yesterday},
+	}
+
+	// Тест: только невыполненная задача с прошедшим сроком
+	overdue := Filter(tasks, Overdue())
+	if len(overdue) != 1 || overdue[0].ID != 1 {
+		t.Errorf("Expected only task 1 to be overdue, got %+v", overdue)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	clock := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	origNow := now
+	now = func() time.Time { return clock }
+	defer func() { now = origNow }()
+
+	// Тест: Add проставляет время создания
+	tasks, created, err := Add([]Task{}, "Task 1", PriorityMedium)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if !created.CreatedAt.Equal(clock) || created.CompletedAt != nil {
+		t.Errorf("Expected CreatedAt %v and no CompletedAt, got %+v", clock, created)
+	}
+
+	// Тест: Complete проставляет время выполнения один раз
+	clock = clock.Add(time.Hour)
+	tasks, err = Complete(tasks, 1)
+	if err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+	completedAt := clock
+	if tasks[0].CompletedAt == nil || !tasks[0].CompletedAt.Equal(completedAt) {
+		t.Fatalf("Expected CompletedAt %v, got %v", completedAt, tasks[0].CompletedAt)
+	}
+	clock = clock.Add(time.Hour)
+	tasks, _ = Complete(tasks, 1)
+	if !tasks[0].CompletedAt.Equal(completedAt) {
+		t.Errorf("Completing a done task should keep CompletedAt %v, got %v", completedAt, tasks[0].CompletedAt)
+	}
+
+	// Тест: Uncomplete очищает время выполнения
+	tasks, _ = Uncomplete(tasks, 1)
+	if tasks[0].CompletedAt != nil {
+		t.Errorf("Expected CompletedAt to be cleared, got %v", tasks[0].CompletedAt)
+	}
+
+	// Тест: старые задачи без меток времени читаются и записываются без них
+	var legacy Task
+	if err := json.Unmarshal([]byte(`{"id":1,"description":"Old task","done":true}`), &legacy); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !legacy.CreatedAt.IsZero() || legacy.CompletedAt != nil {
+		t.Errorf("Expected zero timestamps, got %+v", legacy)
+	}
+	data, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "created_at") || strings.Contains(string(data), "completed_at") {
+		t.Errorf("Expected zero timestamps to be omitted, got %s", data)
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	// Тест: список с пропусками перенумеровывается подряд с MinID
+	tasks := []Task{
+		{ID: 7, Description: "Task 7"},
+		{ID: 2, Description: "Task 2"},
+		{ID: 4, Description: "Task 4", Done: true},
+	}
+	result := Renumber(tasks)
+	expected := []int{3, 1, 2}
+	for i, task := range result {
+		if task.ID != expected[i] {
+			t.Errorf("Task %d: expected ID %d, got %d", i, expected[i], task.ID)
+		}
+	}
+	if result[2].Description != "Task 4" || !result[2].Done {
+		t.Errorf("Renumber should only change IDs, got %+v", result[2])
+	}
+	if tasks[0].ID != 7 {
+		t.Error("Renumber should not modify the input slice")
+	}
+
+	// Тест: список без пропусков не меняется
+	contiguous := []Task{{ID: 1, Description: "Task 1"}, {ID: 2, Description: "Task 2"}}
+	if result := Renumber(contiguous); result[0].ID != 1 || result[1].ID != 2 {
+		t.Errorf("Expected contiguous IDs to stay unchanged, got %+v", result)
+	}
+}
+
+func TestTags(t *testing.T) {
+	// Тест: теги приводятся к нижнему регистру и не повторяются
+	tags := NormalizeTags([]string{"Work", " urgent ", "work", "", "WORK"})
+	if strings.Join(tags, ",") != "work,urgent" {
+		t.Errorf("Expected tags work,urgent, got %v", tags)
+	}
+	if NormalizeTags([]string{" ", ""}) != nil {
+		t.Error("Expected nil for empty tags")
+	}
+
+	// Тест: разделитель тегов не может быть частью тега
+	if tags := NormalizeTags([]string{"home;Work", "work; ;garden"}); strings.Join(tags, ",") != "home,work,garden" {
+		t.Errorf("Expected tags home,work,garden, got %v", tags)
+	}
+
+	tasks := []Task{
+		{ID: 1, Description: "Report"},
+		{ID: 2, Description: "Groceries"},
+		{ID: 3, Description: "Review"},
+	}
+	tasks, err := SetTags(tasks, 1, []string{"Work"})
+	if err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	tasks, _ = SetTags(tasks, 2, []string{"home"})
+	tasks, _ = SetTags(tasks, 3, []string{"work", "urgent"})
+
+	// Тест: фильтрация по тегу без учёта регистра
+	filtered := FilterByTag(tasks, "WORK")
+	if len(filtered) != 2 || filtered[0].ID != 1 || filtered[1].ID != 3 {
+		t.Errorf("Expected tasks 1 and 3, got %+v", filtered)
+	}
+	if filtered := FilterByTag(tasks, "missing"); len(filtered) != 0 {
+		t.Errorf("Expected no tasks for unknown tag, got %+v", filtered)
+	}
+
+	// Тест: несуществующая задача
+	if _, err := SetTags(tasks, 99, []string{"work"}); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+}
+
+func TestAddRemoveTag(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "Report", Tags: []string{"work"}},
+		{ID: 2, Description: "Groceries", Done: true},
+		{ID: 3, Description: "Review", Tags: []string{"urgent"}},
+		{ID: 4, Description: "Call"},
+	}
+
+	// Тест: тег добавляется только выбранным задачам, повтор не считается
+	pending := List(tasks, "pending")
+	ids := []int{pending[0].ID, pending[1].ID, pending[2].ID}
+	tagged, changed := AddTag(tasks, ids, "urgent")
+	if changed != 2 {
+		t.Errorf("Expected 2 changed tasks, got %d", changed)
+	}
+	if strings.Join(tagged[0].Tags, ",") != "work,urgent" || strings.Join(tagged[2].Tags, ",") != "urgent" || strings.Join(tagged[3].Tags, ",") != "urgent" {
+		t.Errorf("Expected pending tasks tagged urgent once, got %+v", tagged)
+	}
+	if tagged[1].Tags != nil {
+		t.Errorf("Expected done task 2 untouched, got %+v", tagged[1])
+	}
+	if tasks[0].Tags[0] != "work" || len(tasks[0].Tags) != 1 || tasks[3].Tags != nil {
+		t.Errorf("Expected input slice unchanged, got %+v", tasks)
+	}
+
+	// Тест: удаление тега у выбранных задач
+	removed, changed := RemoveTag(tagged, []int{1, 2, 3}, "urgent")
+	if changed != 2 {
+		t.Errorf("Expected 2 changed tasks, got %d", changed)
+	}
+	if strings.Join(removed[0].Tags, ",") != "work" || removed[2].Tags != nil || strings.Join(removed[3].Tags, ",") != "urgent" {
+		t.Errorf("Expected urgent removed from tasks 1 and 3 only, got %+v", removed)
+	}
+}
+
+func TestTaskList(t *testing.T) {
+	tasks := []Task{
+		{ID: 1, Description: "First"},
+		{ID: 2, Description: "Second"},
+		{ID: 3, Description: "Third"},
+	}
+	list := NewTaskList(tasks)
+
+	// Тест: пакетное выполнение и удаление по ID
+	for _, id := range []int{1, 3} {
+		if err := list.Complete(id); err != nil {
+			t.Fatalf("Complete(%d) failed: %v", id, err)
+		}
+	}
+	if err := list.Delete(2); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	result := list.Tasks()
+	if len(result) != 2 || result[0].ID != 1 || result[1].ID != 3 || !result[0].Done || !result[1].Done || result[0].CompletedAt == nil {
+		t.Errorf("Expected completed tasks 1 and 3, got %+v", result)
+	}
+	if tasks[0].Done || len(tasks) != 3 {
+		t.Errorf("Expected the original slice to stay unchanged, got %+v", tasks)
+	}
+
+	// Тест: ошибки совпадают с функциями Complete и Delete
+	if err := list.Complete(2); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound for a deleted task, got %v", err)
+	}
+	if err := list.Delete(0); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Expected ErrInvalidID, got %v", err)
+	}
+	if _, ok := list.Find(2); ok {
+		t.Error("Expected Find to miss the deleted task")
+	}
+	if task, ok := list.Find(3); !ok || task.Description != "Third" {
+		t.Errorf("Expected to find task 3, got %+v, %v", task, ok)
+	}
+
+	// Тест: пакетная установка полей, ошибки как у функций Set
+	due := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	list = NewTaskList(tasks)
+	if err := list.SetPriority(1, PriorityHigh); err != nil {
+		t.Fatalf("SetPriority failed: %v", err)
+	}
+	if err := list.SetRef(1, "JIRA-1"); err != nil {
+		t.Fatalf("SetRef failed: %v", err)
+	}
+	if err := list.SetDueDate(2, &due); err != nil {
+		t.Fatalf("SetDueDate failed: %v", err)
+	}
+	if err := list.SetTags(3, []string{" Work ", "work"}); err != nil {
+		t.Fatalf("SetTags failed: %v", err)
+	}
+	due = due.AddDate(1, 0, 0)
+	result = list.Tasks()
+	if result[0].Priority != PriorityHigh || result[0].Ref != "JIRA-1" || result[1].DueDate == nil || result[1].DueDate.Year() != 2024 || strings.Join(result[2].Tags, ",") != "work" {
+		t.Errorf("Expected fields set on tasks 1, 2 and 3, got %+v", result)
+	}
+	if err := list.SetPriority(1, "urgent"); !errors.Is(err, ErrInvalidPriority) {
+		t.Errorf("Expected ErrInvalidPriority, got %v", err)
+	}
+	if err := list.SetTags(9, nil); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+
+	// Тест: повторяющийся ID относится к первой задаче, после удаления — к следующей
+	list = NewTaskList([]Task{{ID: 1, Description: "A"}, {ID: 1, Description: "B"}})
+	list.Delete(1)
+	if task, ok := list.Find(1); !ok || task.Description != "B" {
+		t.Errorf("Expected the second task with ID 1 after delete, got %+v, %v", task, ok)
+	}
+}
+
+// benchmarkTasks returns n pending tasks with IDs 1..n.
+func benchmarkTasks(n int) []Task {
+	tasks := make([]Task, n)
+	for i := range tasks {
+		tasks[i] = Task{ID: i + 1, Description: "Benchmark task " + strconv.Itoa(i+1)}
+	}
+	return tasks
+}
+
+// BenchmarkCompleteEach completes every task one call at a time, O(n·m).
+func BenchmarkCompleteEach(b *testing.B) {
+	tasks := benchmarkTasks(2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result := tasks
+		for id := 1; id <= len(tasks); id++ {
+			var err error
+			if result, err = Complete(result, id); err != nil {
+				b.Fatalf("Complete failed: %v", err)
+			}
+		}
+	}
+}
+
+// BenchmarkTaskListComplete completes every task through one TaskList, O(n+m).
+func BenchmarkTaskListComplete(b *testing.B) {
+	tasks := benchmarkTasks(2000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		list := NewTaskList(tasks)
+		for id := 1; id <= len(tasks); id++ {
+			if err := list.Complete(id); err != nil {
+				b.Fatalf("Complete failed: %v", err)
+			}
+		}
+		_ = list.Tasks()
+	}
+}
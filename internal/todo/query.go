@@ -0,0 +1,238 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// queryStage is a single step of a parsed query pipeline.
+// A select stage keeps tasks whose field compares equal (or not equal) to value.
+// A project stage replaces each task with the value of one field.
+type queryStage struct {
+	kind   string // "select" or "project"
+	field  string
+	value  any
+	negate bool
+}
+
+// Query evaluates a minimal jq-style expression over the task list
+// and returns one output value per line.
+//
+// Supported syntax is a pipeline of stages separated by "|":
+//   - .[]                  iterate over tasks (optional, must come first)
+//   - select(.field==v)    keep tasks where field equals the JSON literal v
+//   - select(.field!=v)    keep tasks where field differs from v
+//   - .field               output the field value (must come last)
+//
+// Fields use the JSON names of Task. Strings are printed raw,
+// other values and whole tasks are printed as compact JSON.
+// Returns an error if the expression cannot be parsed.
+func Query(tasks []Task, expr string) ([]string, error) {
+	stages, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []string
+	for _, task := range tasks {
+		fields, err := taskFields(task)
+		if err != nil {
+			return nil, err
+		}
+
+		var output any = task
+		keep := true
+		for _, stage := range stages {
+			switch stage.kind {
+			case "select":
+				equal := reflect.DeepEqual(fields[stage.field], stage.value)
+				if equal == stage.negate {
+					keep = false
+				}
+			case "project":
+				output = fields[stage.field]
+			}
+			if !keep {
+				break
+			}
+		}
+		if !keep {
+			continue
+		}
+
+		line, err := formatQueryValue(output)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, line)
+	}
+	return results, nil
+}
+
+// parseQuery splits a query expression into validated stages.
+func parseQuery(expr string) ([]queryStage, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, fmt.Errorf("query expression cannot be empty")
+	}
+
+	var stages []queryStage
+	parts := splitOutsideQuotes(expr, "|")
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == ".[]" || part == ".":
+			if i != 0 {
+				return nil, fmt.Errorf("'%s' is only allowed at the start of a query", part)
+			}
+		case strings.HasPrefix(part, "select(") && strings.HasSuffix(part, ")"):
+			stage, err := parseSelect(part[len("select(") : len(part)-1])
+			if err != nil {
+				return nil, err
+			}
+			stages = append(stages, stage)
+		case strings.HasPrefix(part, "."):
+			if i != len(parts)-1 {
+				return nil, fmt.Errorf("field projection '%s' must be the last stage", part)
+			}
+			field := part[1:]
+			if err := validateQueryField(field); err != nil {
+				return nil, err
+			}
+			stages = append(stages, queryStage{kind: "project", field: field})
+		default:
+			return nil, fmt.Errorf("unsupported query stage '%s'", part)
+		}
+	}
+	return stages, nil
+}
+
+// splitOutsideQuotes splits s around every sep that is not inside a
+// double-quoted JSON string, so a literal like "a|b" stays in one part.
+func splitOutsideQuotes(s, sep string) []string {
+	var parts []string
+	for {
+		i := indexOutsideQuotes(s, sep)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+len(sep):]
+	}
+}
+
+// indexOutsideQuotes returns the index of the first substr in s that is not
+// inside a double-quoted JSON string, or -1 if there is none.
+// Backslash escapes inside strings are skipped, so \" doesn't end a string.
+func indexOutsideQuotes(s, substr string) int {
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case inString && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			inString = !inString
+		case !inString && strings.HasPrefix(s[i:], substr):
+			return i
+		}
+	}
+	return -1
+}
+
+// parseSelect parses the condition inside select(...), e.g. ".done==false".
+// The operator is looked up outside quotes, so a string value may contain
+// == or != itself.
+func parseSelect(cond string) (queryStage, error) {
+	op := "=="
+	negate := false
+	eq, ne := indexOutsideQuotes(cond, "=="), indexOutsideQuotes(cond, "!=")
+	if ne >= 0 && (eq < 0 || ne < eq) {
+		op = "!="
+		negate = true
+	}
+
+	index := indexOutsideQuotes(cond, op)
+	if index < 0 {
+		return queryStage{}, fmt.Errorf("select condition '%s' must use == or !=", cond)
+	}
+	left, right := cond[:index], cond[index+len(op):]
+
+	left = strings.TrimSpace(left)
+	if !strings.HasPrefix(left, ".") {
+		return queryStage{}, fmt.Errorf("select condition must start with a field, got '%s'", left)
+	}
+	field := left[1:]
+	if err := validateQueryField(field); err != nil {
+		return queryStage{}, err
+	}
+
+	right = strings.TrimSpace(right)
+	var value any
+	if err := json.Unmarshal([]byte(right), &value); err != nil {
+		return queryStage{}, fmt.Errorf("invalid value '%s' in select: use a JSON literal like 1, true or \"text\"", right)
+	}
+
+	return queryStage{kind: "select", field: field, value: value, negate: negate}, nil
+}
+
+// validateQueryField checks that field is a JSON field name of Task.
+func validateQueryField(field string) error {
+	typ := reflect.TypeOf(Task{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if name == field {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown task field '%s'", field)
+}
+
+// taskFields converts a task into a map keyed by its JSON field names.
+// Fields left out by omitempty are filled with their JSON zero value,
+// so select(.done==false) matches pending tasks.
+func taskFields(task Task) (map[string]any, error) {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode task %d: %w", task.ID, err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("cannot decode task %d: %w", task.ID, err)
+	}
+	for name, zero := range taskZeroFields {
+		if _, ok := fields[name]; !ok {
+			fields[name] = zero
+		}
+	}
+	return fields, nil
+}
+
+// taskZeroFields maps each JSON field name of Task to its decoded zero value.
+var taskZeroFields = func() map[string]any {
+	zero := map[string]any{}
+	typ := reflect.TypeOf(Task{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		data, _ := json.Marshal(reflect.Zero(typ.Field(i).Type).Interface())
+		var value any
+		json.Unmarshal(data, &value)
+		zero[name] = value
+	}
+	return zero
+}()
+
+// formatQueryValue renders a query result: strings raw, everything else as JSON.
+func formatQueryValue(v any) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode query result: %w", err)
+	}
+	return string(data), nil
+}
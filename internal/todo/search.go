@@ -0,0 +1,40 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Search returns the tasks whose description contains query, ignoring case.
+// The order of tasks is preserved.
+func Search(tasks []Task, query string) []Task {
+	query = strings.ToLower(query)
+	var result []Task
+	for _, task := range tasks {
+		if strings.Contains(strings.ToLower(task.Description), query) {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// SearchRegex returns the tasks whose description matches the regular
+// expression pattern. Matching is case-sensitive unless the pattern starts
+// with the (?i) flag. The order of tasks is preserved.
+// Returns an error wrapping ErrInvalidPattern if pattern does not compile.
+func SearchRegex(tasks []Task, pattern string) ([]Task, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPattern, err)
+	}
+	var result []Task
+	for _, task := range tasks {
+		if re.MatchString(task.Description) {
+			result = append(result, task)
+		}
+	}
+	return result, nil
+}
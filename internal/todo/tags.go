@@ -0,0 +1,126 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TagSeparator separates tags where they are stored in a single field,
+// e.g. in CSV files, so it can never be part of a tag.
+const TagSeparator = ";"
+
+// NormalizeTags lower-cases and trims tags, drops empty ones and removes
+// duplicates, keeping the first occurrence of each tag in order.
+// A tag containing TagSeparator is split into separate tags, the same way
+// a stored field would be split on reload.
+// Returns nil if no tag is left.
+func NormalizeTags(tags []string) []string {
+	var result []string
+	seen := make(map[string]bool, len(tags))
+	for _, field := range tags {
+		for _, tag := range strings.Split(field, TagSeparator) {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || seen[tag] {
+				continue
+			}
+			seen[tag] = true
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// SetTags replaces the tags of a task by its ID with the normalized tags.
+// An empty tags slice clears the tags.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+// Returns an updated copy of the task slice on success.
+func SetTags(tasks []Task, id int, tags []string) ([]Task, error) {
+	if err := ValidateID(id); err != nil {
+		return tasks, err
+	}
+	index := findTaskByID(tasks, id)
+	if index == -1 {
+		return tasks, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	tasks = CloneTasks(tasks)
+	tasks[index].Tags = NormalizeTags(tags)
+	return tasks, nil
+}
+
+// HasTag reports whether the task carries tag, ignoring case.
+func (t Task) HasTag(tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, own := range t.Tags {
+		if own == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterByTag returns the tasks that carry tag, ignoring case.
+// The order of tasks is preserved.
+func FilterByTag(tasks []Task, tag string) []Task {
+	var result []Task
+	for _, task := range tasks {
+		if task.HasTag(tag) {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// AddTag adds tag to every task whose ID is in ids, e.g. the IDs of a
+// selection made with List. The tag is expected to be normalized, see
+// NormalizeTags. Tasks that already carry the tag are left unchanged.
+// Returns an updated copy of the task slice and the number of changed tasks.
+func AddTag(tasks []Task, ids []int, tag string) ([]Task, int) {
+	return updateTags(tasks, ids, func(task Task) ([]string, bool) {
+		if task.HasTag(tag) {
+			return nil, false
+		}
+		return append(append([]string(nil), task.Tags...), tag), true
+	})
+}
+
+// RemoveTag removes tag from every task whose ID is in ids.
+// Tasks without the tag are left unchanged.
+// Returns an updated copy of the task slice and the number of changed tasks.
+func RemoveTag(tasks []Task, ids []int, tag string) ([]Task, int) {
+	return updateTags(tasks, ids, func(task Task) ([]string, bool) {
+		if !task.HasTag(tag) {
+			return nil, false
+		}
+		var kept []string
+		for _, own := range task.Tags {
+			if own != tag {
+				kept = append(kept, own)
+			}
+		}
+		return kept, true
+	})
+}
+
+// updateTags implements AddTag and RemoveTag: update returns the new tags
+// of a selected task, or false if the task stays as it is.
+func updateTags(tasks []Task, ids []int, update func(task Task) ([]string, bool)) ([]Task, int) {
+	selected := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		selected[id] = true
+	}
+
+	tasks = CloneTasks(tasks)
+	changed := 0
+	for i := range tasks {
+		if !selected[tasks[i].ID] {
+			continue
+		}
+		if tags, ok := update(tasks[i]); ok {
+			tasks[i].Tags = tags
+			changed++
+		}
+	}
+	return tasks, changed
+}
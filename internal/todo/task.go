@@ -2,12 +2,114 @@
 // CRUD operations, filtering, and import/export capabilities.
 package todo
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// Task priorities accepted by Add and ValidatePriority.
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
 // Task represents a single todo item in the system.
 // ID is a unique auto-generated identifier.
 // Description contains the task text content.
 // Done indicates whether the task has been completed.
+// Ref is an optional external reference such as a ticket URL or issue key.
+// Pinned keeps the task at the top of list output.
+// Priority is one of PriorityLow, PriorityMedium, or PriorityHigh.
+// DueDate is an optional deadline, see IsOverdue.
+// Tags are lower-case labels such as a project name, see NormalizeTags.
+// CreatedAt is set by Add and CompletedAt by Complete; tasks created
+// before timestamps were tracked keep the zero time and nil.
+//
+// ID and Description are always serialized. Every other field is omitted
+// while it holds its zero value, which keeps data files compact; a missing
+// field decodes back to the zero value, so files written with all fields
+// present keep loading unchanged. Priority is the exception on decode:
+// a missing priority means PriorityMedium, so files written before
+// priorities existed load as medium-priority tasks.
 type Task struct {
-	ID          int    `json:"id"`
-	Description string `json:"description"`
-	Done        bool   `json:"done"`
+	ID          int        `json:"id" yaml:"id"`
+	Description string     `json:"description" yaml:"description"`
+	Done        bool       `json:"done,omitempty" yaml:"done,omitempty"`
+	Ref         string     `json:"ref,omitempty" yaml:"ref,omitempty"`
+	Pinned      bool       `json:"pinned,omitempty" yaml:"pinned,omitempty"`
+	Priority    string     `json:"priority,omitempty" yaml:"priority,omitempty"`
+	DueDate     *time.Time `json:"due_date,omitempty" yaml:"due_date,omitempty"`
+	Tags        []string   `json:"tags,omitempty" yaml:"tags,omitempty"`
+	CreatedAt   time.Time  `json:"created_at,omitempty" yaml:"created_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" yaml:"completed_at,omitempty"`
+}
+
+// MarshalJSON encodes a task and omits a zero CreatedAt,
+// which the omitempty tag alone does not do for struct types.
+// CompletedAt is repeated so it stays after created_at in the output.
+func (t Task) MarshalJSON() ([]byte, error) {
+	type plainTask Task
+	encoded := struct {
+		plainTask
+		CreatedAt   *time.Time `json:"created_at,omitempty"`
+		CompletedAt *time.Time `json:"completed_at,omitempty"`
+	}{plainTask: plainTask(t), CompletedAt: t.CompletedAt}
+	if !t.CreatedAt.IsZero() {
+		encoded.CreatedAt = &t.CreatedAt
+	}
+	return json.Marshal(encoded)
+}
+
+// UnmarshalJSON decodes a task and defaults a missing priority to PriorityMedium.
+func (t *Task) UnmarshalJSON(data []byte) error {
+	type plainTask Task
+	decoded := plainTask{Priority: PriorityMedium}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*t = Task(decoded)
+	return nil
+}
+
+// Clone returns a deep copy of the task: Tags, DueDate and CompletedAt
+// are copied too, so changing the clone never affects the original.
+func (t Task) Clone() Task {
+	if t.Tags != nil {
+		t.Tags = append([]string(nil), t.Tags...)
+	}
+	if t.DueDate != nil {
+		due := *t.DueDate
+		t.DueDate = &due
+	}
+	if t.CompletedAt != nil {
+		completedAt := *t.CompletedAt
+		t.CompletedAt = &completedAt
+	}
+	return t
+}
+
+// CloneTasks returns a deep copy of tasks, see Task.Clone.
+// Functions that change tasks, such as Complete or Delete, work on such
+// a copy, so the slice passed to them is never modified.
+// Returns nil for a nil slice.
+func CloneTasks(tasks []Task) []Task {
+	if tasks == nil {
+		return nil
+	}
+	clones := make([]Task, len(tasks))
+	for i, task := range tasks {
+		clones[i] = task.Clone()
+	}
+	return clones
+}
+
+// Label returns the description to display for the task.
+// Completed tasks get doneSuffix appended, pending tasks are unchanged.
+// The stored Description is never modified, so an empty suffix is a no-op.
+func (t Task) Label(doneSuffix string) string {
+	if t.Done && doneSuffix != "" {
+		return t.Description + doneSuffix
+	}
+	return t.Description
 }
@@ -0,0 +1,166 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import (
+	"fmt"
+	"time"
+)
+
+// TaskList is a task slice with an ID to index map, for batch operations.
+// Complete, Delete and the Set functions look up and copy the whole slice
+// on every call, so applying them to m IDs costs O(n·m); a TaskList copies
+// the tasks once and finds each ID in constant time.
+// Like findTaskByID, an ID that occurs more than once refers to its first task.
+type TaskList struct {
+	tasks   []Task
+	index   map[int]int
+	deleted []bool
+	removed int
+	// duplicates is set if some ID occurs more than once
+	duplicates bool
+}
+
+// NewTaskList builds a TaskList from a copy of tasks,
+// so the given slice is never modified.
+func NewTaskList(tasks []Task) *TaskList {
+	list := &TaskList{
+		tasks:   CloneTasks(tasks),
+		index:   make(map[int]int, len(tasks)),
+		deleted: make([]bool, len(tasks)),
+	}
+	for i, task := range list.tasks {
+		if _, ok := list.index[task.ID]; ok {
+			list.duplicates = true
+		} else {
+			list.index[task.ID] = i
+		}
+	}
+	return list
+}
+
+// Tasks returns the current tasks in their original order.
+// The returned slice is a copy, later changes to the list don't affect it.
+func (l *TaskList) Tasks() []Task {
+	tasks := make([]Task, 0, len(l.tasks)-l.removed)
+	for i, task := range l.tasks {
+		if !l.deleted[i] {
+			tasks = append(tasks, task.Clone())
+		}
+	}
+	return tasks
+}
+
+// Find returns the task with the given ID.
+// Reports false if there is no such task.
+func (l *TaskList) Find(id int) (Task, bool) {
+	index, ok := l.index[id]
+	if !ok {
+		return Task{}, false
+	}
+	return l.tasks[index].Clone(), true
+}
+
+// Complete marks a task as done by its ID, like the Complete function.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+func (l *TaskList) Complete(id int) error {
+	index, err := l.lookup(id)
+	if err != nil {
+		return err
+	}
+	if !l.tasks[index].Done {
+		completedAt := now()
+		l.tasks[index].CompletedAt = &completedAt
+	}
+	l.tasks[index].Done = true
+	return nil
+}
+
+// Delete removes a task by its ID, like the Delete function.
+// A later task with the same ID, if any, becomes the one the ID refers to.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+func (l *TaskList) Delete(id int) error {
+	index, err := l.lookup(id)
+	if err != nil {
+		return err
+	}
+	l.deleted[index] = true
+	l.removed++
+	delete(l.index, id)
+	for i := index + 1; l.duplicates && i < len(l.tasks); i++ {
+		if l.tasks[i].ID == id && !l.deleted[i] {
+			l.index[id] = i
+			break
+		}
+	}
+	return nil
+}
+
+// SetPriority sets the priority of a task by its ID, like the SetPriority function.
+// Returns an error wrapping ErrInvalidID, ErrTaskNotFound or ErrInvalidPriority on failure.
+func (l *TaskList) SetPriority(id int, priority string) error {
+	index, err := l.lookup(id)
+	if err != nil {
+		return err
+	}
+	if err := ValidatePriority(priority); err != nil {
+		return err
+	}
+	l.tasks[index].Priority = priority
+	return nil
+}
+
+// SetRef sets the external reference of a task by its ID, like the SetRef function.
+// Returns an error wrapping ErrInvalidID, ErrTaskNotFound or ErrInvalidRef on failure.
+func (l *TaskList) SetRef(id int, ref string) error {
+	index, err := l.lookup(id)
+	if err != nil {
+		return err
+	}
+	if err := ValidateRef(ref); err != nil {
+		return err
+	}
+	l.tasks[index].Ref = ref
+	return nil
+}
+
+// SetDueDate sets the due date of a task by its ID, like the SetDueDate function.
+// A nil due clears the due date.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+func (l *TaskList) SetDueDate(id int, due *time.Time) error {
+	index, err := l.lookup(id)
+	if err != nil {
+		return err
+	}
+	if due != nil {
+		copied := *due
+		due = &copied
+	}
+	l.tasks[index].DueDate = due
+	return nil
+}
+
+// SetTags replaces the tags of a task by its ID with the normalized tags,
+// like the SetTags function.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+func (l *TaskList) SetTags(id int, tags []string) error {
+	index, err := l.lookup(id)
+	if err != nil {
+		return err
+	}
+	l.tasks[index].Tags = NormalizeTags(tags)
+	return nil
+}
+
+// lookup returns the index of the task with the given ID.
+// Returns an error wrapping ErrInvalidID or ErrTaskNotFound on failure.
+func (l *TaskList) lookup(id int) (int, error) {
+	if err := ValidateID(id); err != nil {
+		return -1, err
+	}
+	index, ok := l.index[id]
+	if !ok {
+		return -1, fmt.Errorf("%w: ID %d", ErrTaskNotFound, id)
+	}
+	return index, nil
+}
@@ -0,0 +1,44 @@
+// Package todo provides task management functionality including
+// CRUD operations, filtering, and import/export capabilities.
+package todo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Template holds task fields that are reused across many new tasks,
+// e.g. the priority and tags of every bug report.
+// An empty Priority leaves the task's priority unchanged.
+type Template struct {
+	Name     string   `json:"name"`
+	Priority string   `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// ValidateTemplate validates a template before it is saved.
+// Returns an error if the name is empty or the priority is invalid.
+func ValidateTemplate(tmpl Template) error {
+	if strings.TrimSpace(tmpl.Name) == "" {
+		return fmt.Errorf("template name cannot be empty")
+	}
+	if tmpl.Priority != "" {
+		if err := ValidatePriority(tmpl.Priority); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyTemplate returns a copy of task with the template's fields merged in:
+// the template's priority replaces the task's one if set, and the template's
+// tags are added after the task's own tags.
+// The task passed in is not modified.
+func ApplyTemplate(task Task, tmpl Template) Task {
+	if tmpl.Priority != "" {
+		task.Priority = tmpl.Priority
+	}
+	tags := append(append([]string(nil), task.Tags...), tmpl.Tags...)
+	task.Tags = NormalizeTags(tags)
+	return task
+}